@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/api"
+	"github.com/orchard9/watch-now/internal/config"
+	"github.com/orchard9/watch-now/internal/core"
+)
+
+// runDaemonMode runs without any terminal rendering for service managers:
+// the API is the only interface, logs are structured, and systemd is told
+// the service is ready once the first cycle has reported. When restart is
+// set on the way out, systemd is told the service is reloading rather than
+// stopping, as the re-executed process will report ready again.
+func runDaemonMode(ctx context.Context, engine *core.Engine, cfg *config.Config, restart *atomic.Bool) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	// Route the log package through slog too, so every line shares a format
+	slog.SetDefault(logger)
+
+	if !cfg.API.Enabled {
+		logger.Warn("API is disabled; enabling it since daemon mode has no other output")
+		cfg.API.Enabled = true
+	}
+	apiServer, err := api.NewServer(engine, cfg.API, buildInfo())
+	if err != nil {
+		// The API is the daemon's only output, so it can't run without it
+		logger.Error("API server failed", "error", err)
+		os.Exit(1)
+	}
+	go func() {
+		if err := apiServer.Start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("API server failed", "error", err)
+		}
+	}()
+	defer func() { _ = apiServer.Stop() }()
+
+	engine.State().OnTransition(func(t core.Transition) {
+		logger.Info("status changed",
+			"monitor", t.Name,
+			"old", string(t.Old),
+			"new", string(t.New),
+			"message", t.Result.Message,
+			"flapping", t.Flapping,
+		)
+	})
+
+	logger.Info("watch-now started", "version", version, "monitors", engine.MonitorCount(), "interval", cfg.Interval, "api", apiServer.Addr())
+
+	done := make(chan error, 1)
+	go func() {
+		done <- engine.Start(ctx)
+	}()
+
+	waitForResults(ctx, engine, 60*time.Second, false)
+	if ctx.Err() == nil {
+		if err := sdNotify("READY=1"); err != nil {
+			logger.Warn("systemd readiness notification failed", "error", err)
+		}
+		logger.Info("first check cycle complete", "overall", string(engine.State().Overall(engine.State().GetAll())))
+	}
+
+	if err := <-done; err != nil && ctx.Err() == nil {
+		logger.Error("engine stopped", "error", err)
+		os.Exit(1)
+	}
+	if restart.Load() {
+		_ = sdNotify("RELOADING=1")
+		logger.Info("watch-now restarting with the new config")
+		return
+	}
+	_ = sdNotify("STOPPING=1")
+	logger.Info("watch-now stopped")
+}
+
+// sdNotify sends a state string to systemd over $NOTIFY_SOCKET. It does
+// nothing when not started by systemd with Type=notify.
+func sdNotify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// A leading @ names a socket in the abstract namespace
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
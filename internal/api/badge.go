@@ -0,0 +1,73 @@
+package api
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+
+	"github.com/orchard9/watch-now/internal/monitors"
+)
+
+// badgeColors follows the shields.io palette
+var badgeColors = map[string]string{
+	string(monitors.StatusOK):   "#4c1",
+	string(monitors.StatusWarn): "#dfb317",
+	string(monitors.StatusFail): "#e05d44",
+}
+
+const badgeTemplate = `<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="20" role="img" aria-label="%[3]s: %[4]s">
+<title>%[3]s: %[4]s</title>
+<linearGradient id="s" x2="0" y2="100%%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>
+<clipPath id="r"><rect width="%[1]d" height="20" rx="3" fill="#fff"/></clipPath>
+<g clip-path="url(#r)"><rect width="%[2]d" height="20" fill="#555"/><rect x="%[2]d" width="%[6]d" height="20" fill="%[5]s"/><rect width="%[1]d" height="20" fill="url(#s)"/></g>
+<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+<text x="%[7]d" y="14">%[3]s</text><text x="%[8]d" y="14">%[4]s</text>
+</g>
+</svg>
+`
+
+// handleBadge renders the overall status, or one monitor's with ?name=, as
+// an SVG badge for embedding in wikis and READMEs
+func (s *Server) handleBadge(w http.ResponseWriter, r *http.Request) {
+	label := "watch-now"
+	var status string
+	code := http.StatusOK
+	if name := r.URL.Query().Get("name"); name != "" {
+		label = name
+		if result := s.engine.State().Get(name); result != nil {
+			status = string(result.Status)
+		} else {
+			status = "unknown"
+			code = http.StatusNotFound
+		}
+	} else {
+		status = string(s.engine.State().Overall(s.engine.State().GetAll()))
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "max-age=30")
+	w.WriteHeader(code)
+	_, _ = w.Write(renderBadge(label, status))
+}
+
+// renderBadge lays out a two-part badge, estimating text width from the
+// character count since there is no font metrics to hand
+func renderBadge(label, value string) []byte {
+	color, ok := badgeColors[value]
+	if !ok {
+		color = "#9f9f9f"
+	}
+
+	labelWidth := textWidth(label)
+	valueWidth := textWidth(value)
+	return []byte(fmt.Sprintf(badgeTemplate,
+		labelWidth+valueWidth, labelWidth,
+		html.EscapeString(label), html.EscapeString(value),
+		color, valueWidth,
+		labelWidth/2, labelWidth+valueWidth/2,
+	))
+}
+
+func textWidth(s string) int {
+	return len([]rune(s))*7 + 10
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/core"
+	"github.com/orchard9/watch-now/internal/monitors"
+)
+
+// atomFeed and atomEntry cover the parts of RFC 4287 a reader needs
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID      string `xml:"id"`
+	Title   string `xml:"title"`
+	Updated string `xml:"updated"`
+	Summary string `xml:"summary"`
+}
+
+// handleFeed renders recent incidents and recoveries as an Atom feed,
+// newest first
+func (s *Server) handleFeed(w http.ResponseWriter, r *http.Request) {
+	incidents := s.engine.State().Incidents()
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	self := fmt.Sprintf("%s://%s%s", scheme, r.Host, r.URL.Path)
+
+	feed := atomFeed{
+		ID:      self,
+		Title:   "watch-now incidents",
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Link:    atomLink{Href: self, Rel: "self"},
+		Author:  atomAuthor{Name: "watch-now"},
+	}
+	for i := len(incidents) - 1; i >= 0; i-- {
+		t := incidents[i]
+		if len(feed.Entries) == 0 {
+			feed.Updated = t.Result.Timestamp.UTC().Format(time.RFC3339)
+		}
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      fmt.Sprintf("%s#%s-%d", self, t.Name, t.Result.Timestamp.UnixNano()),
+			Title:   incidentTitle(t),
+			Updated: t.Result.Timestamp.UTC().Format(time.RFC3339),
+			Summary: t.Result.Message,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(feed)
+}
+
+// incidentTitle summarizes a status change, e.g. "api down"
+func incidentTitle(t core.Transition) string {
+	switch t.New {
+	case monitors.StatusFail:
+		return t.Name + " down"
+	case monitors.StatusWarn:
+		return t.Name + " degraded"
+	case monitors.StatusOK:
+		if t.Downtime > 0 {
+			return fmt.Sprintf("%s recovered after %v", t.Name, t.Downtime.Round(time.Second))
+		}
+		return t.Name + " recovered"
+	default:
+		return fmt.Sprintf("%s is %s", t.Name, t.New)
+	}
+}
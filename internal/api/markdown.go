@@ -0,0 +1,70 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/monitors"
+)
+
+var statusEmoji = map[string]string{
+	string(monitors.StatusOK):   "✅",
+	string(monitors.StatusWarn): "⚠️",
+	string(monitors.StatusFail): "❌",
+	string(monitors.StatusInfo): "ℹ️",
+}
+
+// handleMarkdown renders the current status as Markdown tables, ready to
+// paste into a standup doc or issue
+func (s *Server) handleMarkdown(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	_, _ = w.Write([]byte(renderMarkdown(s.getStatusData())))
+}
+
+func renderMarkdown(status StatusResponse) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s watch-now: %s\n\n", emojiFor(status.Overall), strings.ToUpper(status.Overall))
+	fmt.Fprintf(&b, "_As of %s_\n", status.Timestamp)
+
+	writeMarkdownTable(&b, "Services", status.Services)
+	writeMarkdownTable(&b, "Checks", status.Checks)
+	return b.String()
+}
+
+func writeMarkdownTable(b *strings.Builder, title string, results []*monitors.Result) {
+	if len(results) == 0 {
+		return
+	}
+
+	fmt.Fprintf(b, "\n### %s\n\n", title)
+	b.WriteString("| | Monitor | Status | Message | Duration |\n")
+	b.WriteString("|---|---|---|---|---|\n")
+	for _, result := range results {
+		status := strings.ToUpper(string(result.Status))
+		if result.Acked {
+			status += " (acked)"
+		}
+		fmt.Fprintf(b, "| %s | %s | %s | %s | %s |\n",
+			emojiFor(string(result.Status)),
+			markdownCell(result.Name),
+			status,
+			markdownCell(result.Message),
+			result.Duration.Round(time.Millisecond))
+	}
+}
+
+func emojiFor(status string) string {
+	if emoji, ok := statusEmoji[status]; ok {
+		return emoji
+	}
+	return "❔"
+}
+
+// markdownCell keeps text on one table row: pipes would start a new
+// column and newlines a new paragraph
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/pprof"
+	"time"
+)
+
+// registerPProf adds the standard profiling endpoints to mux
+func registerPProf(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", longRunning(pprof.Profile))
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", longRunning(pprof.Trace))
+}
+
+// longRunning lifts the server's write timeout for handlers that sample
+// for ?seconds= (30 by default). pprof refuses durations beyond the
+// server's WriteTimeout, which it finds through the request context, so
+// the server is hidden from it too.
+func longRunning(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+		ctx := context.WithValue(r.Context(), http.ServerContextKey, nil)
+		handler(w, r.WithContext(ctx))
+	}
+}
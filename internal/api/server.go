@@ -4,20 +4,27 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 	"net"
 	"net/http"
+	"runtime"
 	"sort"
-	"strings"
+	"strconv"
+	"sync/atomic"
 	"time"
 
+	"github.com/orchard9/watch-now/internal/config"
 	"github.com/orchard9/watch-now/internal/core"
 	"github.com/orchard9/watch-now/internal/monitors"
 )
 
 type Server struct {
 	engine   *core.Engine
+	config   config.APIConfig
+	build    BuildInfo
 	server   *http.Server
 	listener net.Listener
+	sseConns atomic.Int32
 }
 
 type StatusResponse struct {
@@ -25,18 +32,78 @@ type StatusResponse struct {
 	Services  []*monitors.Result          `json:"services"`
 	Checks    []*monitors.Result          `json:"checks"`
 	Overall   string                      `json:"overall"`
+	Paused    bool                        `json:"paused"`
 	Results   map[string]*monitors.Result `json:"results"`
+
+	// Git is the commit the checks ran against, with git_context enabled
+	Git *core.GitContext `json:"git,omitempty"`
+
+	// DeployModeUntil is set while REST 5xx failures are downgraded to
+	// warnings for a deploy
+	DeployModeUntil *time.Time `json:"deploy_mode_until,omitempty"`
+
+	// Projects is keyed by project name when multi-project mode is used;
+	// monitors outside any project are listed under "".
+	Projects map[string]ProjectStatus `json:"projects,omitempty"`
 }
 
-func NewServer(engine *core.Engine, port int) *Server {
+// ProjectStatus is one project's slice of the overall status
+type ProjectStatus struct {
+	Services []*monitors.Result `json:"services"`
+	Checks   []*monitors.Result `json:"checks"`
+	Overall  string             `json:"overall"`
+}
+
+// BuildInfo identifies the running binary; the fields are set at link time
+// in package main.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"date"`
+	GoVersion string `json:"go_version"`
+}
+
+// MonitorInfo describes a configured monitor, whether or not it has run yet
+type MonitorInfo struct {
+	Name    string               `json:"name"`
+	Type    monitors.MonitorType `json:"type"`
+	Target  string               `json:"target"`
+	Timeout string               `json:"timeout"`
+	Project string               `json:"project,omitempty"`
+}
+
+// NewServer binds the configured address; it fails when the port is taken.
+func NewServer(engine *core.Engine, cfg config.APIConfig, build BuildInfo) (*Server, error) {
+	if build.GoVersion == "" {
+		build.GoVersion = runtime.Version()
+	}
 	s := &Server{
 		engine: engine,
+		config: cfg,
+		build:  build,
 	}
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/api/status", s.handleStatus)
+	mux.HandleFunc("/api/status.md", s.handleMarkdown)
 	mux.HandleFunc("/api/events", s.handleSSE)
+	mux.HandleFunc("/api/stream", s.handleStream)
 	mux.HandleFunc("/api/health", s.handleHealth)
+	mux.HandleFunc("/api/version", s.handleVersion)
+	mux.HandleFunc("/api/monitors", s.handleMonitors)
+	mux.HandleFunc("/api/trends", s.handleTrends)
+	mux.HandleFunc("/api/history", s.handleHistory)
+	mux.HandleFunc("/api/badge.svg", s.handleBadge)
+	mux.HandleFunc("/api/feed.atom", s.handleFeed)
+	mux.HandleFunc("/api/export", s.handleExport)
+	mux.HandleFunc("/api/import", s.handleImport)
+	mux.HandleFunc("/api/pause", s.handlePause)
+	mux.HandleFunc("/api/resume", s.handleResume)
+	mux.HandleFunc("/api/deploy-mode", s.handleDeployMode)
+	mux.HandleFunc("/api/ack", s.handleAck)
+	if cfg.PProf {
+		registerPProf(mux)
+	}
 
 	s.server = &http.Server{
 		Handler:      s.corsMiddleware(mux),
@@ -46,17 +113,17 @@ func NewServer(engine *core.Engine, port int) *Server {
 
 	// Create listener
 	var err error
-	addr := fmt.Sprintf(":%d", port)
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
 	s.listener, err = net.Listen("tcp", addr)
 	if err != nil {
-		log.Fatalf("Failed to create listener: %v", err)
+		return nil, fmt.Errorf("creating listener: %w", err)
 	}
 
-	return s
+	return s, nil
 }
 
 func (s *Server) Start() error {
-	log.Printf("API server starting on http://localhost:%d", s.listener.Addr().(*net.TCPAddr).Port)
+	log.Printf("API server starting on http://%s", s.Addr())
 	return s.server.Serve(s.listener)
 }
 
@@ -74,6 +141,14 @@ func (s *Server) Port() int {
 	return 0
 }
 
+// Addr returns the bound host:port
+func (s *Server) Addr() string {
+	if s.listener != nil {
+		return s.listener.Addr().String()
+	}
+	return ""
+}
+
 func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -91,133 +166,457 @@ func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+
+	response := map[string]interface{}{
 		"status":    "ok",
 		"timestamp": time.Now().Unix(),
-	})
+	}
+
+	if s.config.AggregateHealth {
+		overall := s.engine.State().Overall(s.engine.State().GetAll())
+		response["overall"] = overall
+		if overall == monitors.StatusFail {
+			response["status"] = "fail"
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}
+
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.build)
 }
 
 func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	results := s.engine.State().GetAll()
-	services, checks := groupAndSortResults(results)
+	response := s.getStatusData()
 
-	response := StatusResponse{
-		Timestamp: time.Now().Format("2006-01-02T15:04:05Z07:00"),
-		Services:  services,
-		Checks:    checks,
-		Overall:   string(s.getOverallStatus(results)),
-		Results:   results,
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func (s *Server) handleMonitors(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	infos := s.engine.Monitors()
+	list := make([]MonitorInfo, 0, len(infos))
+	for _, info := range infos {
+		list = append(list, MonitorInfo{
+			Name:    info.Name,
+			Type:    info.Type,
+			Target:  info.Target,
+			Timeout: info.Timeout.String(),
+			Project: s.engine.ProjectOf(info.Name),
+		})
+	}
+
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"monitors": list,
+	})
+}
+
+// defaultTrendPoints is how many buckets /api/trends returns without ?points
+const defaultTrendPoints = 50
+
+func (s *Server) handleTrends(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
 	}
 
+	points, ok := queryInt(w, r.URL.Query().Get("points"), "points", defaultTrendPoints, 1)
+	if !ok {
+		return
+	}
+
+	trend, found := s.engine.State().Trend(name, points)
+	if !found {
+		http.Error(w, fmt.Sprintf("no history for %s", name), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"name":   name,
+		"points": trend,
+	})
+}
+
+// History pages default to defaultHistoryLimit entries, at most maxHistoryLimit
+const (
+	defaultHistoryLimit = 100
+	maxHistoryLimit     = 1000
+)
+
+// HistoryResponse is one page of history. Next is the offset of the
+// following page and is only set when HasMore is true.
+type HistoryResponse struct {
+	Entries []core.HistoryEntry `json:"entries"`
+	Total   int                 `json:"total"`
+	HasMore bool                `json:"has_more"`
+	Next    int                 `json:"next,omitempty"`
+}
+
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var since time.Time
+	if raw := query.Get("since"); raw != "" {
+		t, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			http.Error(w, "since must be an RFC 3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		since = t
+	}
+	limit, ok := queryInt(w, query.Get("limit"), "limit", defaultHistoryLimit, 1)
+	if !ok {
+		return
+	}
+	offset, ok := queryInt(w, query.Get("offset"), "offset", 0, 0)
+	if !ok {
+		return
+	}
+	if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+
+	entries := s.engine.State().History(query.Get("name"), since)
+	response := HistoryResponse{Entries: []core.HistoryEntry{}, Total: len(entries)}
+	if offset < len(entries) {
+		end := offset + limit
+		if end > len(entries) {
+			end = len(entries)
+		}
+		response.Entries = entries[offset:end]
+		if end < len(entries) {
+			response.HasMore = true
+			response.Next = end
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(response)
 }
 
+// queryInt parses an optional integer parameter of at least min, writing a
+// 400 and reporting false when it is invalid
+func queryInt(w http.ResponseWriter, raw, param string, fallback, min int) (int, bool) {
+	if raw == "" {
+		return fallback, true
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < min {
+		http.Error(w, fmt.Sprintf("%s must be an integer of at least %d", param, min), http.StatusBadRequest)
+		return 0, false
+	}
+	return n, true
+}
+
+func (s *Server) handlePause(w http.ResponseWriter, r *http.Request) {
+	s.setPaused(w, r, true)
+}
+
+func (s *Server) handleResume(w http.ResponseWriter, r *http.Request) {
+	s.setPaused(w, r, false)
+}
+
+func (s *Server) setPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if paused {
+		s.engine.Pause()
+	} else {
+		s.engine.Resume()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"paused": s.engine.Paused(),
+	})
+}
+
+// handleDeployMode reports the deploy window on GET. POST with ?duration=5m
+// opens one, during which REST 5xx failures count as warnings; duration=0
+// closes it early.
+func (s *Server) handleDeployMode(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		duration, err := time.ParseDuration(r.URL.Query().Get("duration"))
+		if err != nil || duration < 0 {
+			http.Error(w, "duration must be a duration like 5m", http.StatusBadRequest)
+			return
+		}
+		var until time.Time
+		if duration > 0 {
+			until = time.Now().Add(duration)
+		}
+		s.engine.State().SetDeployMode(until)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	response := map[string]interface{}{"active": false}
+	if until, active := s.engine.State().DeployMode(); active {
+		response["active"] = true
+		response["until"] = until
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// handleAck acknowledges (POST) or unacknowledges (DELETE) the failure of
+// the monitor named by ?name
+func (s *Server) handleAck(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	if s.engine.State().Get(name) == nil {
+		http.Error(w, fmt.Sprintf("no result for %s", name), http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		if err := s.engine.Ack(name); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+	case http.MethodDelete:
+		s.engine.State().Unack(name)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.engine.State().Get(name))
+}
+
 func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request) {
-	// Set SSE headers
-	w.Header().Set("Content-Type", "text/event-stream")
+	s.serveStream(w, r, "text/event-stream", s.sendSSEEvent, true)
+}
+
+// transitionEvent is a status change as sent on /api/events
+type transitionEvent struct {
+	ID        uint64          `json:"id"`
+	Name      string          `json:"name"`
+	Old       monitors.Status `json:"old,omitempty"`
+	New       monitors.Status `json:"new"`
+	Message   string          `json:"message"`
+	Flapping  bool            `json:"flapping,omitempty"`
+	Acked     bool            `json:"acked,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// handleStream is /api/events without SSE framing: one status object per
+// line as application/x-ndjson, for clients that can't parse SSE
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	s.serveStream(w, r, "application/x-ndjson", writeJSONLine, false)
+}
+
+// serveStream pushes the status whenever state changes until the client
+// disconnects or stops reading. write frames one message; heartbeats and
+// transition events are only sent when the framing can tell them apart
+// from status updates.
+//
+// Events carry the number of the latest status change as their id. A
+// client reconnecting with Last-Event-ID first gets the changes it missed
+// that are still in the incident log.
+func (s *Server) serveStream(w http.ResponseWriter, r *http.Request, contentType string, write func(w http.ResponseWriter, id, event string, data interface{}) error, framed bool) {
+	if !s.acquireSSE() {
+		http.Error(w, "too many event streams", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.sseConns.Add(-1)
+
+	// Set streaming headers
+	w.Header().Set("Content-Type", contentType)
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 
-	// Create a channel to receive state updates
-	updates := make(chan map[string]*monitors.Result, 10)
-	s.engine.State().Subscribe(updates)
-	defer s.engine.State().Unsubscribe(updates)
+	// Changes coalesce while a slow client is mid-write, and each send
+	// reads the latest state, so the client never stays stale
+	updates := s.engine.State().SubscribeChanges()
+	defer s.engine.State().UnsubscribeChanges(updates)
+
+	// Each write gets its own deadline in place of the server-wide write
+	// timeout, so a stream lives as long as the client keeps reading
+	rc := http.NewResponseController(w)
+	send := func(id uint64, event string, data interface{}) bool {
+		_ = rc.SetWriteDeadline(time.Now().Add(s.config.SSEIdleTimeout))
+		if err := write(w, strconv.FormatUint(id, 10), event, data); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+
+	// lastSeq is the latest status change the client has seen
+	_, lastSeq := s.engine.State().IncidentsSince(math.MaxUint64)
+	if id, err := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64); err == nil && framed {
+		// A number beyond the log means the server restarted since
+		if id > lastSeq {
+			id = 0
+		}
+		lastSeq = id
+	}
+	sendTransitions := func() bool {
+		if !framed {
+			return true
+		}
+		var missed []core.Transition
+		missed, lastSeq = s.engine.State().IncidentsSince(lastSeq)
+		for _, t := range missed {
+			event := transitionEvent{
+				ID:        t.Seq,
+				Name:      t.Name,
+				Old:       t.Old,
+				New:       t.New,
+				Message:   t.Result.Message,
+				Flapping:  t.Flapping,
+				Acked:     t.Acked,
+				Timestamp: t.Result.Timestamp,
+			}
+			if !send(t.Seq, "transition", event) {
+				return false
+			}
+		}
+		return true
+	}
 
 	// Send initial state
-	s.sendSSEEvent(w, "status", s.getStatusData())
+	if !sendTransitions() || !send(lastSeq, "status", s.getStatusData()) {
+		return
+	}
 
-	// Set up ticker for periodic updates
-	ticker := time.NewTicker(5 * time.Second)
-	defer ticker.Stop()
+	// Heartbeats keep idle connections alive; they don't delay status
+	// events, which go out as soon as state changes
+	var heartbeats <-chan time.Time
+	if framed {
+		ticker := time.NewTicker(s.config.SSEHeartbeat)
+		defer ticker.Stop()
+		heartbeats = ticker.C
+	}
 
 	// Handle client disconnect
 	ctx := r.Context()
 
 	for {
+		ok := true
 		select {
 		case <-ctx.Done():
 			return
 		case <-updates:
-			// Send updated status when state changes
-			s.sendSSEEvent(w, "status", s.getStatusData())
-		case <-ticker.C:
+			// Send any status changes, then the updated status
+			ok = sendTransitions() && send(lastSeq, "status", s.getStatusData())
+		case <-heartbeats:
 			// Send periodic heartbeat
-			s.sendSSEEvent(w, "heartbeat", map[string]interface{}{
+			ok = send(lastSeq, "heartbeat", map[string]interface{}{
 				"timestamp": time.Now().Unix(),
 			})
 		}
-
-		// Flush the response
-		if flusher, ok := w.(http.Flusher); ok {
-			flusher.Flush()
+		if !ok {
+			// The client stopped reading; drop the stream and its subscription
+			return
 		}
 	}
 }
 
-func (s *Server) sendSSEEvent(w http.ResponseWriter, event string, data interface{}) {
+// acquireSSE reserves a stream slot, reporting false when the cap is reached
+func (s *Server) acquireSSE() bool {
+	n := s.sseConns.Add(1)
+	if s.config.SSEMaxConnections > 0 && int(n) > s.config.SSEMaxConnections {
+		s.sseConns.Add(-1)
+		return false
+	}
+	return true
+}
+
+// sendSSEEvent writes one event frame. Write errors, such as a broken pipe
+// from a client that vanished mid-write, are returned so serveStream drops
+// the stream and its subscription at once.
+func (s *Server) sendSSEEvent(w http.ResponseWriter, id, event string, data interface{}) error {
 	jsonData, err := json.Marshal(data)
 	if err != nil {
 		log.Printf("Error marshaling SSE data: %v", err)
-		return
+		return nil
 	}
 
-	fmt.Fprintf(w, "event: %s\n", event)
-	fmt.Fprintf(w, "data: %s\n\n", string(jsonData))
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", id, event, jsonData)
+	return err
+}
+
+// writeJSONLine writes data as a single NDJSON line; the id and event name
+// are not part of the stream
+func writeJSONLine(w http.ResponseWriter, _, _ string, data interface{}) error {
+	// Encode terminates each value with a newline
+	return json.NewEncoder(w).Encode(data)
 }
 
 func (s *Server) getStatusData() StatusResponse {
-	results := s.engine.State().GetAll()
-	services, checks := groupAndSortResults(results)
+	results := s.engine.State().WithHistory(s.engine.State().GetAll())
+	services, checks := s.groupAndSortResults(results)
 
-	return StatusResponse{
+	response := StatusResponse{
 		Timestamp: time.Now().Format("2006-01-02T15:04:05Z07:00"),
 		Services:  services,
 		Checks:    checks,
-		Overall:   string(s.getOverallStatus(results)),
+		Overall:   string(s.engine.State().Overall(results)),
+		Paused:    s.engine.Paused(),
 		Results:   results,
+		Projects:  s.projectStatuses(results),
+		Git:       s.engine.GitContext(),
+	}
+	if until, active := s.engine.State().DeployMode(); active {
+		response.DeployModeUntil = &until
+	}
+	return response
+}
+
+func (s *Server) projectStatuses(results map[string]*monitors.Result) map[string]ProjectStatus {
+	if len(s.engine.Projects()) == 0 {
+		return nil
+	}
+
+	projects := make(map[string]ProjectStatus)
+	for project, projectResults := range s.engine.ResultsByProject(results) {
+		services, checks := s.groupAndSortResults(projectResults)
+		projects[project] = ProjectStatus{
+			Services: services,
+			Checks:   checks,
+			Overall:  string(s.engine.State().Overall(projectResults)),
+		}
 	}
+	return projects
 }
 
-func groupAndSortResults(results map[string]*monitors.Result) (services []*monitors.Result, checks []*monitors.Result) {
+func (s *Server) groupAndSortResults(results map[string]*monitors.Result) (services []*monitors.Result, checks []*monitors.Result) {
 	for _, result := range results {
 		switch result.Type {
 		case monitors.TypeQuality:
 			checks = append(checks, result)
-		case monitors.TypeREST, monitors.TypeGRPC:
+		default:
 			services = append(services, result)
 		}
 	}
 
 	sort.Slice(services, func(i, j int) bool {
-		return strings.ToLower(services[i].Name) < strings.ToLower(services[j].Name)
+		return s.engine.DisplayLess(services[i].Name, services[j].Name)
 	})
 	sort.Slice(checks, func(i, j int) bool {
-		return strings.ToLower(checks[i].Name) < strings.ToLower(checks[j].Name)
+		return s.engine.DisplayLess(checks[i].Name, checks[j].Name)
 	})
 
 	return services, checks
 }
-
-func (s *Server) getOverallStatus(results map[string]*monitors.Result) monitors.Status {
-	if len(results) == 0 {
-		return monitors.StatusInfo
-	}
-
-	hasWarn := false
-	for _, result := range results {
-		if result.Status == monitors.StatusFail {
-			return monitors.StatusFail
-		}
-		if result.Status == monitors.StatusWarn {
-			hasWarn = true
-		}
-	}
-
-	if hasWarn {
-		return monitors.StatusWarn
-	}
-	return monitors.StatusOK
-}
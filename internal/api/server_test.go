@@ -0,0 +1,62 @@
+package api
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/config"
+	"github.com/orchard9/watch-now/internal/core"
+	"github.com/orchard9/watch-now/internal/monitors"
+)
+
+// A client that disconnects mid-stream must end its handler and release
+// the change subscription, however busy the state is.
+func TestEventsClientDisconnect(t *testing.T) {
+	engine := core.NewEngine(&config.Config{})
+	s := &Server{
+		engine: engine,
+		config: config.APIConfig{SSEHeartbeat: 10 * time.Millisecond, SSEIdleTimeout: time.Second},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(s.handleSSE))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "id: ") {
+		t.Fatalf("first line = %q, %v", line, err)
+	}
+	if n := engine.State().ChangeSubscribers(); n != 1 {
+		t.Fatalf("subscribers while streaming = %d, want 1", n)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				engine.State().Update(&monitors.Result{Name: "api", Status: monitors.StatusOK})
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}()
+	_ = resp.Body.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for s.sseConns.Load() != 0 || engine.State().ChangeSubscribers() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("handler still running after disconnect: streams=%d subscribers=%d",
+				s.sseConns.Load(), engine.State().ChangeSubscribers())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/orchard9/watch-now/internal/core"
+)
+
+// maxImportBytes bounds an uploaded snapshot
+const maxImportBytes = 32 << 20
+
+// handleExport returns every result and its history as a snapshot file
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	snapshot := s.engine.State().Snapshot()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q",
+		"watch-now-"+snapshot.ExportedAt.Format("20060102-150405")+".json"))
+	_ = json.NewEncoder(w).Encode(snapshot)
+}
+
+// handleImport replaces the state with an exported snapshot. Only a viewer
+// accepts it: a monitoring instance would mix it with live results.
+func (s *Server) handleImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.config.Viewer {
+		http.Error(w, "import is only available in viewer mode (--viewer)", http.StatusForbidden)
+		return
+	}
+
+	var snapshot core.Snapshot
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxImportBytes)).Decode(&snapshot); err != nil {
+		http.Error(w, fmt.Sprintf("invalid snapshot: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := s.engine.State().Restore(snapshot); err != nil {
+		http.Error(w, fmt.Sprintf("invalid snapshot: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"results":     len(snapshot.Results),
+		"exported_at": snapshot.ExportedAt,
+	})
+}
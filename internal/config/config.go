@@ -1,10 +1,21 @@
 package config
 
 import (
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
 	"time"
 
+	"github.com/orchard9/watch-now/internal/cron"
+	"github.com/orchard9/watch-now/internal/expr"
+	"github.com/orchard9/watch-now/internal/schema"
 	"gopkg.in/yaml.v3"
 )
 
@@ -13,19 +24,356 @@ type Config struct {
 	Checks   []CheckConfig   `yaml:"checks"`
 	Interval time.Duration   `yaml:"interval"`
 	API      APIConfig       `yaml:"api"`
+
+	// Discovery periodically runs a command that lists services to monitor
+	Discovery *DiscoveryConfig `yaml:"discovery"`
+
+	// OTel exports each check as a span, plus duration and status metrics,
+	// to an OpenTelemetry collector
+	OTel *OTelConfig `yaml:"otel"`
+
+	// Metrics pushes monitor metrics to a Prometheus Pushgateway, for
+	// setups where Prometheus can't scrape watch-now
+	Metrics MetricsConfig `yaml:"metrics"`
+
+	// Outputs receive every result as it is stored, e.g. to feed a log
+	// aggregator. Each has its own queue, so a slow one never delays
+	// checks or the other outputs.
+	Outputs []OutputConfig `yaml:"outputs"`
+
+	// Projects lets one instance monitor several repositories. Their
+	// services and checks are merged into Services and Checks at load,
+	// named "project/monitor".
+	Projects []ProjectConfig `yaml:"projects"`
+
+	Notifications NotificationsConfig `yaml:"notifications"`
+	Artifacts     ArtifactsConfig     `yaml:"artifacts"`
+	FlapDetection FlapConfig          `yaml:"flap_detection"`
+	Overall       OverallConfig       `yaml:"overall"`
+
+	// MaxCheckConcurrency and MaxServiceConcurrency cap how many checks
+	// and service monitors run at once, in separate pools so slow checks
+	// can't hold up cheap probes. 0 is unlimited.
+	MaxCheckConcurrency   int `yaml:"max_check_concurrency"`
+	MaxServiceConcurrency int `yaml:"max_service_concurrency"`
+
+	// OnTransition runs a local command whenever any monitor changes status.
+	// Commands only run when AllowCommands is explicitly enabled.
+	OnTransition  *HookConfig `yaml:"on_transition"`
+	AllowCommands bool        `yaml:"allow_commands"`
+
+	Display DisplayConfig `yaml:"display"`
+
+	// GitContext records HEAD's commit and branch at startup, and again on
+	// each --watch rerun, for /api/status.
+	GitContext bool `yaml:"git_context"`
+
+	// DisplayOrder lists monitors to show first, in this order; the rest
+	// follow alphabetically.
+	DisplayOrder []string `yaml:"display_order"`
+
+	// Profile selects the monitors tagged with it, as --use-profile does
+	// when the flag is not given. Empty runs every monitor.
+	Profile string `yaml:"profile"`
+
+	// Reload sets what happens when the config file changes while
+	// monitoring: "ignore" (default) keeps the loaded config, "exit" stops
+	// cleanly for a supervisor to restart, and "reload" restarts in place
+	// with the new config once it loads.
+	Reload string `yaml:"reload"`
+}
+
+// DisplayConfig customizes how statuses render in the terminal. Symbols and
+// Colors are keyed by status (ok, warn, fail, info). ASCII mode keeps the
+// output free of Unicode symbols.
+type DisplayConfig struct {
+	ASCII   bool              `yaml:"ascii"`
+	Symbols map[string]string `yaml:"symbols"`
+	Colors  map[string]string `yaml:"colors"`
+}
+
+var (
+	displayStatuses = map[string]bool{"ok": true, "warn": true, "fail": true, "info": true}
+	displayColors   = map[string]bool{"black": true, "red": true, "green": true, "yellow": true, "blue": true, "magenta": true, "cyan": true, "white": true}
+)
+
+// ProjectConfig is one monitored project. Checks run inside Dir.
+type ProjectConfig struct {
+	Name     string          `yaml:"name"`
+	Dir      string          `yaml:"dir"`
+	Services []ServiceConfig `yaml:"services"`
+	Checks   []CheckConfig   `yaml:"checks"`
 }
 
 type ServiceConfig struct {
 	Name    string            `yaml:"name"`
+	Project string            `yaml:"-"`
 	Type    string            `yaml:"type"`
 	URL     string            `yaml:"url"`
 	Health  string            `yaml:"health"`
 	Headers map[string]string `yaml:"headers"`
 	Timeout time.Duration     `yaml:"timeout"`
+
+	// Hosts fans the service out into one monitor per entry, named
+	// <name>-1, <name>-2, ..., each with its URL's host:port replaced by
+	// the entry, e.g. for identical replicas on ports 8080-8082.
+	Hosts []string `yaml:"hosts"`
+
+	// Labels are free-form tags (team, severity, ...) copied onto every
+	// result and notification for routing.
+	Labels map[string]string `yaml:"labels"`
+
+	// Critical: false keeps the service displayed but out of the overall
+	// status. Unset means critical.
+	Critical *bool `yaml:"critical"`
+
+	// HTTPVersion pins the protocol REST checks use: "1.1", "2" or
+	// "auto" (the default, negotiated per request).
+	HTTPVersion string `yaml:"http_version"`
+
+	// Proxy routes HTTP checks through this proxy URL instead of the one
+	// from HTTP_PROXY/HTTPS_PROXY; "none" connects directly.
+	Proxy string `yaml:"proxy"`
+
+	// Retries is how many extra attempts a failing check gets. Timeout
+	// bounds each attempt while Deadline bounds all attempts together.
+	Retries  int           `yaml:"retries"`
+	Deadline time.Duration `yaml:"deadline"`
+
+	// ConnectTimeout bounds establishing the TCP connection of REST and
+	// gRPC-web checks, so refused or unreachable hosts fail fast while a
+	// slow response still gets all of Timeout. Zero leaves it to Timeout.
+	ConnectTimeout time.Duration `yaml:"connect_timeout"`
+
+	// BodyTimeout bounds reading a REST response body, which only
+	// happens when assertions such as expect_json need it. A body still
+	// streaming by then is judged on what has arrived. Zero leaves it to
+	// Timeout. Without assertions, headers with a good status suffice.
+	BodyTimeout time.Duration `yaml:"body_timeout"`
+
+	// WarnEscalation reports the service as FAIL once it has stayed WARN
+	// for this long.
+	WarnEscalation time.Duration `yaml:"warn_escalation"`
+
+	// SLO judges the service on p95 latency across recent checks rather
+	// than on any single response.
+	SLO *SLOConfig `yaml:"slo"`
+
+	// ExpectJSON maps dotted field selectors (e.g. "checks.db.status" or
+	// "items.0.state") to the value expected in the JSON response body.
+	ExpectJSON map[string]string `yaml:"expect_json"`
+
+	// ExpectSchema is the path of a JSON Schema file the REST response
+	// body must conform to; see internal/schema for the keywords covered.
+	ExpectSchema string `yaml:"expect_schema"`
+
+	// MinBytes and MaxBytes bound the size of a REST response body, e.g.
+	// to catch an empty or truncated 200; 0 leaves that side unchecked.
+	MinBytes int64 `yaml:"min_bytes"`
+	MaxBytes int64 `yaml:"max_bytes"`
+
+	// SuccessExpr decides a REST check's status in place of the status
+	// code rules, e.g. `status_code == 200 && duration_ms < 500`. It sees
+	// the variables in SuccessExprVars and may return a boolean (OK or
+	// FAIL) or a status name such as "warn".
+	SuccessExpr string `yaml:"success_expr"`
+
+	// DetectChange makes a REST check report INFO "changed" whenever the
+	// response's ETag, or the body hash without one, differs from the
+	// previous check's, and OK while it stays the same.
+	DetectChange bool `yaml:"detect_change"`
+
+	// Trace records how long DNS, connect, TLS and the server took on
+	// each REST check, in metadata.timing.
+	Trace bool `yaml:"trace"`
+
+	// PreRequests run in order before each REST check, e.g. a login.
+	// Cookies they receive are sent on the later requests and on the
+	// health request; every check starts a new session.
+	PreRequests []PreRequestConfig `yaml:"pre_requests"`
+
+	// Probe is "readiness" (default), where a REST check needs a 2xx or
+	// 3xx, or "liveness", where any HTTP response means the service is up,
+	// e.g. a 503 from an app that is alive but not ready. Liveness results
+	// still report readiness in metadata.ready.
+	Probe string `yaml:"probe"`
+
+	// FollowRedirects: false reports a 3xx response as is rather than
+	// following it. Unset follows up to 10 redirects.
+	FollowRedirects *bool `yaml:"follow_redirects"`
+
+	// Expect is "open" (default) or "closed" for type: tcp; closed turns
+	// the check into a guardrail that fails when the port accepts connections.
+	Expect string `yaml:"expect"`
+
+	// Ports makes a tcp service probe each of these ports on the url's
+	// host in one check.
+	Ports []int `yaml:"ports"`
+
+	// Command and Args run a script for type: exec, which prints its
+	// result as JSON on stdout, or for type: metric, which prints a number.
+	Command string   `yaml:"command"`
+	Args    []string `yaml:"args"`
+
+	// WarnAbove, FailAbove, WarnBelow and FailBelow are the thresholds a
+	// type: metric or sql value is judged against; unset ones are not checked.
+	WarnAbove *float64 `yaml:"warn_above"`
+	FailAbove *float64 `yaml:"fail_above"`
+	WarnBelow *float64 `yaml:"warn_below"`
+	FailBelow *float64 `yaml:"fail_below"`
+
+	// ProbeQuery is the query a type: sql service runs against the
+	// PostgreSQL database in url. The first column of its first row must
+	// be a number, which the thresholds judge. Without a query the check
+	// only connects and runs SELECT 1.
+	ProbeQuery string `yaml:"probe_query"`
+
+	// Path is the file a type: log service follows. It is WARN once
+	// nothing was appended for WarnIdle and FAIL after MaxIdle, and FAIL
+	// when a line appended since the previous check matches ErrorPattern.
+	Path         string        `yaml:"path"`
+	WarnIdle     time.Duration `yaml:"warn_idle"`
+	MaxIdle      time.Duration `yaml:"max_idle"`
+	ErrorPattern string        `yaml:"error_pattern"`
+
+	// GRPCService is the service name sent to the gRPC health check
+	// (type: grpc-web); empty asks about the server as a whole.
+	GRPCService string `yaml:"grpc_service"`
+
+	// GRPCMethod probes servers without the health service by calling
+	// this unary method, e.g. "/users.v1.Users/Ping"; any response that
+	// isn't an error is OK. GRPCRequest is the request message as a JSON
+	// object, encoded using the types the server describes over gRPC
+	// reflection; empty or {} sends the message with every field unset
+	// and needs no reflection.
+	GRPCMethod  string `yaml:"grpc_method"`
+	GRPCRequest string `yaml:"grpc_request"`
+
+	// Kubeconfig, Namespace and either Deployment or Selector configure
+	// type: k8s. Kubeconfig defaults to $KUBECONFIG or ~/.kube/config and
+	// Namespace to the current context's.
+	Kubeconfig string `yaml:"kubeconfig"`
+	Namespace  string `yaml:"namespace"`
+	Selector   string `yaml:"selector"`
+	Deployment string `yaml:"deployment"`
+
+	// WarnBefore is how close to expiry a certificate starts warning (type: cert)
+	WarnBefore time.Duration `yaml:"warn_before"`
+
+	// ExpectFingerprint pins the SHA-256 fingerprint of the leaf
+	// certificate (type: cert), in hex with or without colons. A mismatch
+	// fails the check; a match stands in for chain verification, so
+	// self-signed certificates can be pinned.
+	ExpectFingerprint string `yaml:"expect_fingerprint"`
+
+	// Brokers and Topic configure type: kafka; Partitions optionally
+	// asserts the topic's partition count.
+	Brokers    []string `yaml:"brokers"`
+	Topic      string   `yaml:"topic"`
+	Partitions int      `yaml:"partitions"`
+
+	// Username and Password are SASL/PLAIN credentials for type: kafka and
+	// basic auth for type: rest. An Authorization entry in Headers takes
+	// precedence over basic auth.
+	Username string `yaml:"username"`
+	Password Secret `yaml:"password"`
+
+	// DependsOn names another monitor this service needs. While that
+	// monitor is FAIL, this one is not checked and reports INFO instead
+	// of a redundant failure.
+	DependsOn string `yaml:"depends_on"`
+
+	// Profiles limits the service to runs with one of these profiles
+	// selected; untagged services always run.
+	Profiles []string `yaml:"profiles"`
+
+	OnTransition *HookConfig `yaml:"on_transition"`
+}
+
+// NormalizeFingerprint strips the colons and spaces from a hex fingerprint
+// and lowercases it
+func NormalizeFingerprint(fingerprint string) string {
+	return strings.ToLower(strings.NewReplacer(":", "", " ", "").Replace(fingerprint))
 }
 
+// SuccessExprVars are the variables a success_expr can use: the response
+// status code, its duration in milliseconds, the body as a string and the
+// body decoded as JSON (null when it isn't JSON).
+var SuccessExprVars = []string{"status_code", "duration_ms", "body", "json"}
+
 type CheckConfig struct {
 	Name    string        `yaml:"name"`
+	Project string        `yaml:"-"`
+	Command string        `yaml:"command"`
+	Args    []string      `yaml:"args"`
+	Timeout time.Duration `yaml:"timeout"`
+	Dir     string        `yaml:"dir"` // Working directory, relative to where watch-now runs
+
+	// Retries reruns a command that exits non-zero, within what is left
+	// of Timeout; a timed-out run is not retried.
+	Retries int `yaml:"retries"`
+
+	// WarnDuration downgrades a passing run to WARN when it took longer,
+	// catching slowdowns well before they hit Timeout.
+	WarnDuration time.Duration `yaml:"warn_duration"`
+
+	// Shell runs Command as a script through sh -c (cmd /c on Windows),
+	// for pipelines and redirects. Off by default, so a command string is
+	// never interpreted by a shell unless asked.
+	Shell bool `yaml:"shell"`
+
+	// Labels are free-form tags copied onto every result and notification
+	Labels map[string]string `yaml:"labels"`
+
+	// Critical: false keeps the check displayed but out of the overall
+	// status. Unset means critical.
+	Critical *bool `yaml:"critical"`
+
+	// WatchPatterns limits which file changes rerun this check in --watch
+	// mode ("*.go", "web/**"). Interval runs are unaffected.
+	WatchPatterns []string `yaml:"watch_patterns"`
+
+	// SerializeGroup names a group of checks of which at most one runs at
+	// a time, e.g. checks sharing a test database. Waiting for the group
+	// does not count against Timeout, but it does stretch the cycle: when
+	// a group's runs take longer than the interval, missed ticks are
+	// skipped rather than queued.
+	SerializeGroup string `yaml:"serialize_group"`
+
+	// Schedule is a cron expression ("0 2 * * *", in local time) for
+	// checks too heavy to run every interval. The check then runs at the
+	// scheduled times only, not at startup or on file changes, and keeps
+	// its last result in between. --once still runs it.
+	Schedule string `yaml:"schedule"`
+
+	// Container runs the command inside a Docker image instead of on the
+	// host.
+	Container *ContainerConfig `yaml:"container"`
+
+	// CacheKeyFiles are patterns, relative to Dir and matched like
+	// WatchPatterns, for the files the check depends on. While none of
+	// them change, the last result is reused instead of running again.
+	// Patterns that match no files turn caching off rather than freezing
+	// the first result.
+	CacheKeyFiles []string `yaml:"cache_key_files"`
+
+	// OutputFile receives the full output of every run, overwritten each time
+	OutputFile string `yaml:"output_file"`
+
+	// WarnEscalation reports the check as FAIL once it has stayed WARN
+	// for this long.
+	WarnEscalation time.Duration `yaml:"warn_escalation"`
+
+	// Profiles limits the check to runs with one of these profiles
+	// selected; untagged checks always run.
+	Profiles []string `yaml:"profiles"`
+
+	OnTransition *HookConfig `yaml:"on_transition"`
+}
+
+// HookConfig is a command run on status transitions. It receives the
+// monitor name, type and statuses via WATCH_NOW_* environment variables.
+type HookConfig struct {
 	Command string        `yaml:"command"`
 	Args    []string      `yaml:"args"`
 	Timeout time.Duration `yaml:"timeout"`
@@ -34,6 +382,232 @@ type CheckConfig struct {
 type APIConfig struct {
 	Enabled bool `yaml:"enabled"`
 	Port    int  `yaml:"port"`
+
+	// Host is the interface to bind. It defaults to 127.0.0.1; use
+	// 0.0.0.0 to expose the API on every interface.
+	Host string `yaml:"host"`
+
+	// AggregateHealth makes /api/health return 503 when the overall
+	// status is failing instead of only reporting that the API is up.
+	AggregateHealth bool `yaml:"aggregate_health"`
+
+	// SSEMaxConnections caps concurrent /api/events streams (default 32);
+	// SSEIdleTimeout closes a stream whose client stops reading for that
+	// long (default 1m).
+	SSEMaxConnections int           `yaml:"sse_max_connections"`
+	SSEIdleTimeout    time.Duration `yaml:"sse_idle_timeout"`
+
+	// SSEHeartbeat is how often /api/events sends a heartbeat event
+	// (default 5s). Status events go out on every change regardless.
+	SSEHeartbeat time.Duration `yaml:"sse_heartbeat"`
+
+	// PProf serves the net/http/pprof profiles under /debug/pprof/. Off
+	// by default: profiles expose internals and cost CPU while running.
+	PProf bool `yaml:"pprof"`
+
+	// Viewer serves the API without running any monitor, for browsing a
+	// snapshot loaded with POST /api/import. Only a viewer accepts imports.
+	Viewer bool `yaml:"viewer"`
+}
+
+// ArtifactsConfig enables saving the full output of every check run to
+// Dir, named by check and timestamp. Older files beyond Keep per check, or
+// older than MaxAge, are pruned.
+type ArtifactsConfig struct {
+	Dir    string        `yaml:"dir"`
+	Keep   int           `yaml:"keep"`
+	MaxAge time.Duration `yaml:"max_age"`
+}
+
+// FlapConfig marks a monitor as flapping when its status changes more than
+// Threshold times within Window. Detection is off while Threshold is 0.
+type FlapConfig struct {
+	Threshold int           `yaml:"threshold"`
+	Window    time.Duration `yaml:"window"`
+}
+
+// OverallConfig sets how failures add up to the overall status. With
+// FailPercent set, it is FAIL only when more than that percentage of the
+// critical monitors fail, and WARN when fewer do; 0 makes any failure FAIL.
+type OverallConfig struct {
+	FailPercent float64 `yaml:"fail_percent"`
+}
+
+// DiscoveryConfig runs Command every Interval (default: the monitoring
+// interval). Its stdout is a JSON array of service entries using the same
+// keys as services in this file; monitors are added, updated and removed
+// to match each run's output.
+type DiscoveryConfig struct {
+	Command  string        `yaml:"command"`
+	Args     []string      `yaml:"args"`
+	Interval time.Duration `yaml:"interval"`
+	Timeout  time.Duration `yaml:"timeout"`
+}
+
+// ParseDiscoveredServices decodes discovery output and applies the same
+// defaults and validation as services in the config file.
+func ParseDiscoveredServices(data []byte) ([]ServiceConfig, error) {
+	// JSON is valid YAML, so the yaml tags apply to discovery output too
+	var services []ServiceConfig
+	if err := yaml.Unmarshal(data, &services); err != nil {
+		return nil, fmt.Errorf("parsing discovery output: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	for i := range services {
+		svc := &services[i]
+		if svc.Name == "" {
+			return nil, fmt.Errorf("discovered service %d has no name", i+1)
+		}
+		if seen[svc.Name] {
+			return nil, fmt.Errorf("duplicate discovered service %q", svc.Name)
+		}
+		seen[svc.Name] = true
+		svc.applyDefaults()
+		if err := svc.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return services, nil
+}
+
+// ContainerConfig runs a check through docker run --rm. Without Volumes
+// the check's directory is mounted at /src, which becomes the default
+// Workdir.
+type ContainerConfig struct {
+	Image   string   `yaml:"image"`
+	Volumes []string `yaml:"volumes"` // host:container[:ro], as for docker -v
+	Workdir string   `yaml:"workdir"`
+}
+
+// OTelConfig points at an OTLP/HTTP endpoint such as a collector on
+// http://localhost:4318. Spans and metrics are batched and sent every
+// Interval (default 10s).
+type OTelConfig struct {
+	Endpoint    string            `yaml:"endpoint"`
+	Headers     map[string]string `yaml:"headers"`
+	ServiceName string            `yaml:"service_name"` // Default: watch-now
+	Interval    time.Duration     `yaml:"interval"`
+}
+
+// MetricsConfig enables pushing after every check cycle when
+// PushgatewayURL is set. Each push replaces the previous one for Job
+// (default watch-now).
+type MetricsConfig struct {
+	PushgatewayURL string `yaml:"pushgateway_url"`
+	Job            string `yaml:"job"`
+}
+
+// OutputConfig is a destination for results, each encoded as one line of
+// JSON. type: file appends to Path; type: http POSTs batches of lines to
+// URL as application/x-ndjson, e.g. to Vector or Fluent Bit.
+type OutputConfig struct {
+	Type    string            `yaml:"type"` // file or http
+	Path    string            `yaml:"path"`
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+
+	// Buffer is how many results may wait for a slow output before new
+	// ones are dropped (default 1000).
+	Buffer int `yaml:"buffer"`
+}
+
+// PreRequestConfig is a request a REST check makes before its health
+// request. The service's headers and credentials apply to it as well.
+type PreRequestConfig struct {
+	Method  string            `yaml:"method"` // Default GET, or POST with a body
+	Path    string            `yaml:"path"`   // Relative to the service URL
+	Headers map[string]string `yaml:"headers"`
+	Body    Secret            `yaml:"body"`
+}
+
+// SLOConfig sets p95 latency budgets over a trailing window (default 10m).
+type SLOConfig struct {
+	Window  time.Duration `yaml:"window"`
+	P95Warn time.Duration `yaml:"p95_warn"`
+	P95Fail time.Duration `yaml:"p95_fail"`
+}
+
+type NotificationsConfig struct {
+	Channels []ChannelConfig `yaml:"channels"`
+
+	// MaxPerMinute caps digests across all channels, one budget shared
+	// by every channel; transitions beyond it are held and delivered
+	// together in the next digest. 0 means unlimited.
+	MaxPerMinute int `yaml:"max_per_minute"`
+	// CoalesceWindow batches transitions arriving within it into a
+	// single digest message. 0 sends each one immediately.
+	CoalesceWindow time.Duration `yaml:"coalesce_window"`
+
+	// Timeout bounds each delivery attempt (default 10s); Retries is how
+	// many extra attempts a failed delivery gets, with backoff.
+	Timeout time.Duration `yaml:"timeout"`
+	Retries int           `yaml:"retries"`
+
+	// NotifyOn picks which transitions are sent, by where they lead:
+	// "fail", "warn", "info" or "recovery" (back to OK). Empty sends all.
+	NotifyOn []string `yaml:"notify_on"`
+
+	// Notifications fire only when a status changes. ReminderInterval
+	// additionally repeats the alert for a monitor that stays FAIL this
+	// long, and again each interval after. 0 (the default) never reminds.
+	ReminderInterval time.Duration `yaml:"reminder_interval"`
+
+	// Routes pick the channels each transition goes to. Without routes
+	// every transition goes to every channel; with them, a transition
+	// goes to the channels of every route it matches, or nowhere.
+	Routes []RouteConfig `yaml:"routes"`
+}
+
+// RouteConfig sends the transitions it matches to Channels. It matches
+// when the transition leads to one of Status (fail, warn, info or
+// recovery) and the monitor has every label in Labels; an empty
+// condition matches anything.
+type RouteConfig struct {
+	Status   []string          `yaml:"status"`
+	Labels   map[string]string `yaml:"labels"`
+	Channels []string          `yaml:"channels"`
+}
+
+// ChannelConfig describes a notification destination. Template is a Go
+// text/template rendered with the transition event (.Name, .Type, .Old,
+// .New, .Message, .Duration, .Metadata, .Labels, .Timestamp).
+type ChannelConfig struct {
+	Name     string            `yaml:"name"`
+	Type     string            `yaml:"type"` // webhook or slack
+	URL      string            `yaml:"url"`
+	Headers  map[string]string `yaml:"headers"`
+	Template string            `yaml:"template"`
+}
+
+// templateSample stands in for notify.Event, which config can't import, so
+// validation can execute channel templates. Its fields must match
+// notify.Event's; a test keeps them in step.
+var templateSample = struct {
+	Name       string
+	Type       string
+	Old        string
+	New        string
+	Message    string
+	Duration   time.Duration
+	Metadata   map[string]interface{}
+	Labels     map[string]string
+	Timestamp  time.Time
+	Flapping   bool
+	Stabilized bool
+	Recovered  bool
+	Downtime   time.Duration
+	Reminder   bool
+}{
+	Name:      "api",
+	Type:      "rest",
+	Old:       "ok",
+	New:       "fail",
+	Message:   "HTTP 503 (server error) in 12ms",
+	Duration:  12 * time.Millisecond,
+	Metadata:  map[string]interface{}{"status_code": 503},
+	Labels:    map[string]string{"team": "platform"},
+	Timestamp: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
 }
 
 func Load(path string) (*Config, error) {
@@ -47,25 +621,653 @@ func Load(path string) (*Config, error) {
 		return nil, fmt.Errorf("parsing config: %w", err)
 	}
 
+	if err := config.expandProjects(); err != nil {
+		return nil, err
+	}
+	if err := config.expandHosts(); err != nil {
+		return nil, err
+	}
+	config.applyDefaults()
+
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// expandProjects merges each project's monitors into the top-level lists
+func (c *Config) expandProjects() error {
+	seen := make(map[string]bool)
+	for _, project := range c.Projects {
+		if project.Name == "" || strings.Contains(project.Name, "/") {
+			return fmt.Errorf("project name %q must be non-empty and contain no '/'", project.Name)
+		}
+		if seen[project.Name] {
+			return fmt.Errorf("duplicate project name %q", project.Name)
+		}
+		seen[project.Name] = true
+
+		for _, svc := range project.Services {
+			svc.Name = project.Name + "/" + svc.Name
+			svc.Project = project.Name
+			c.Services = append(c.Services, svc)
+		}
+		for _, check := range project.Checks {
+			check.Name = project.Name + "/" + check.Name
+			check.Project = project.Name
+			check.Dir = filepath.Join(project.Dir, check.Dir)
+			c.Checks = append(c.Checks, check)
+		}
+	}
+	return nil
+}
+
+// expandHosts replaces each service with a hosts list by one service per
+// host
+func (c *Config) expandHosts() error {
+	var services []ServiceConfig
+	for _, svc := range c.Services {
+		if len(svc.Hosts) == 0 {
+			services = append(services, svc)
+			continue
+		}
+		for i, host := range svc.Hosts {
+			if host == "" {
+				return fmt.Errorf("service %q: hosts entry %d is empty", svc.Name, i+1)
+			}
+			replica := svc
+			replica.Name = fmt.Sprintf("%s-%d", svc.Name, i+1)
+			replica.URL = replaceHost(svc.URL, host)
+			replica.Hosts = nil
+			services = append(services, replica)
+		}
+	}
+	c.Services = services
+	return nil
+}
+
+// replaceHost swaps the host:port of rawURL for host. A URL without a
+// scheme, like a tcp service's "localhost:6060", is replaced whole.
+func replaceHost(rawURL, host string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return host
+	}
+	u.Host = host
+	return u.String()
+}
+
+// ProjectNames lists configured projects in order, including "" first when
+// monitors are also defined outside any project. It is empty when the
+// config doesn't use projects.
+func (c *Config) ProjectNames() []string {
+	if len(c.Projects) == 0 {
+		return nil
+	}
+
+	var names []string
+	for _, svc := range c.Services {
+		if svc.Project == "" {
+			names = append(names, "")
+			break
+		}
+	}
+	if len(names) == 0 {
+		for _, check := range c.Checks {
+			if check.Project == "" {
+				names = append(names, "")
+				break
+			}
+		}
+	}
+	for _, project := range c.Projects {
+		names = append(names, project.Name)
+	}
+	return names
+}
+
+func (c *Config) applyDefaults() {
 	// Set defaults
-	if config.Interval == 0 {
-		config.Interval = 60 * time.Second
+	if c.Interval == 0 {
+		c.Interval = 60 * time.Second
 	}
-	if config.API.Port == 0 {
-		config.API.Port = 0 // Use ephemeral port
+	if c.Reload == "" {
+		c.Reload = "ignore"
+	}
+	c.API.applyDefaults()
+
+	if c.FlapDetection.Threshold > 0 && c.FlapDetection.Window == 0 {
+		c.FlapDetection.Window = 10 * c.Interval
+	}
+	if c.Discovery != nil {
+		c.Discovery.applyDefaults(c.Interval)
+	}
+	if c.OTel != nil {
+		if c.OTel.ServiceName == "" {
+			c.OTel.ServiceName = "watch-now"
+		}
+		if c.OTel.Interval == 0 {
+			c.OTel.Interval = 10 * time.Second
+		}
+	}
+	if c.Metrics.PushgatewayURL != "" && c.Metrics.Job == "" {
+		c.Metrics.Job = "watch-now"
+	}
+	if c.Notifications.Timeout == 0 {
+		c.Notifications.Timeout = 10 * time.Second
+	}
+	for i := range c.Outputs {
+		if c.Outputs[i].Buffer == 0 {
+			c.Outputs[i].Buffer = 1000
+		}
+	}
+	if c.Artifacts.Dir != "" && c.Artifacts.Keep == 0 {
+		c.Artifacts.Keep = 20
 	}
 
 	// Set default timeouts
-	for i := range config.Services {
-		if config.Services[i].Timeout == 0 {
-			config.Services[i].Timeout = 10 * time.Second
+	for i := range c.Services {
+		c.Services[i].applyDefaults()
+	}
+	for i := range c.Checks {
+		if c.Checks[i].Timeout == 0 {
+			c.Checks[i].Timeout = 30 * time.Second
+		}
+	}
+}
+
+func (d *DiscoveryConfig) applyDefaults(interval time.Duration) {
+	if d.Interval == 0 {
+		d.Interval = interval
+	}
+	if d.Timeout == 0 {
+		d.Timeout = 30 * time.Second
+	}
+}
+
+func (a *APIConfig) applyDefaults() {
+	if a.Port == 0 {
+		a.Port = 0 // Use ephemeral port
+	}
+	if a.Host == "" {
+		a.Host = "127.0.0.1"
+	}
+	if a.SSEMaxConnections == 0 {
+		a.SSEMaxConnections = 32
+	}
+	if a.SSEIdleTimeout == 0 {
+		a.SSEIdleTimeout = time.Minute
+	}
+	if a.SSEHeartbeat == 0 {
+		a.SSEHeartbeat = 5 * time.Second
+	}
+}
+
+func (s *ServiceConfig) applyDefaults() {
+	if s.Timeout == 0 {
+		s.Timeout = 10 * time.Second
+	}
+	if s.Deadline == 0 {
+		s.Deadline = s.Timeout * time.Duration(s.Retries+1)
+	}
+	if s.SLO != nil && s.SLO.Window == 0 {
+		s.SLO.Window = 10 * time.Minute
+	}
+	if s.Type == "cert" && s.WarnBefore == 0 {
+		s.WarnBefore = 14 * 24 * time.Hour
+	}
+}
+
+func (d DisplayConfig) validate() error {
+	for status := range d.Symbols {
+		if !displayStatuses[status] {
+			return fmt.Errorf("display.symbols: unknown status %q", status)
 		}
 	}
-	for i := range config.Checks {
-		if config.Checks[i].Timeout == 0 {
-			config.Checks[i].Timeout = 30 * time.Second
+	for status, name := range d.Colors {
+		if !displayStatuses[status] {
+			return fmt.Errorf("display.colors: unknown status %q", status)
+		}
+		if !displayColors[name] {
+			return fmt.Errorf("display.colors.%s: unknown color %q", status, name)
 		}
 	}
+	return nil
+}
 
-	return &config, nil
+// ApplyProfile drops the monitors tagged for other profiles. An empty
+// name falls back to the profile setting; with neither, nothing is
+// dropped. Naming a profile no monitor uses is an error, to catch typos.
+func (c *Config) ApplyProfile(name string) error {
+	if name == "" {
+		name = c.Profile
+	}
+	if name == "" {
+		return nil
+	}
+
+	known := false
+	inProfile := func(profiles []string) bool {
+		for _, p := range profiles {
+			if p == name {
+				known = true
+				return true
+			}
+		}
+		return len(profiles) == 0
+	}
+
+	var services []ServiceConfig
+	for _, svc := range c.Services {
+		if inProfile(svc.Profiles) {
+			services = append(services, svc)
+		}
+	}
+	var checks []CheckConfig
+	for _, check := range c.Checks {
+		if inProfile(check.Profiles) {
+			checks = append(checks, check)
+		}
+	}
+	if !known {
+		return fmt.Errorf("no service or check is tagged with profile %q", name)
+	}
+
+	// A dependency left out of the profile can't gate anything
+	active := make(map[string]bool)
+	for _, check := range checks {
+		active[check.Name] = true
+	}
+	for _, svc := range services {
+		active[svc.Name] = true
+	}
+	for i := range services {
+		if !active[services[i].DependsOn] {
+			services[i].DependsOn = ""
+		}
+	}
+
+	c.Profile = name
+	c.Services, c.Checks = services, checks
+	return nil
+}
+
+// Hooks returns the transition hook for each monitor, falling back to the
+// global hook when a monitor doesn't define its own.
+func (c *Config) Hooks() map[string]*HookConfig {
+	hooks := make(map[string]*HookConfig)
+	for _, svc := range c.Services {
+		if hook := firstHook(svc.OnTransition, c.OnTransition); hook != nil {
+			hooks[svc.Name] = hook
+		}
+	}
+	for _, check := range c.Checks {
+		if hook := firstHook(check.OnTransition, c.OnTransition); hook != nil {
+			hooks[check.Name] = hook
+		}
+	}
+	return hooks
+}
+
+func firstHook(hooks ...*HookConfig) *HookConfig {
+	for _, hook := range hooks {
+		if hook != nil && hook.Command != "" {
+			return hook
+		}
+	}
+	return nil
+}
+
+func (c *Config) validate() error {
+	if len(c.Hooks()) > 0 && !c.AllowCommands {
+		return fmt.Errorf("on_transition hooks run local commands; set allow_commands: true to enable them")
+	}
+	for i := range c.Services {
+		if err := c.Services[i].validate(); err != nil {
+			return err
+		}
+	}
+	if err := c.validateDependencies(); err != nil {
+		return err
+	}
+	for _, check := range c.Checks {
+		if check.Container != nil && check.Container.Image == "" {
+			return fmt.Errorf("check %q: container requires an image", check.Name)
+		}
+		if check.WarnDuration < 0 {
+			return fmt.Errorf("check %q: warn_duration must not be negative", check.Name)
+		}
+		if check.Shell && len(check.Args) > 0 {
+			return fmt.Errorf("check %q: with shell: true, put the whole command line in command instead of args", check.Name)
+		}
+		if check.Schedule != "" {
+			if _, err := cron.Parse(check.Schedule); err != nil {
+				return fmt.Errorf("check %q: invalid schedule: %w", check.Name, err)
+			}
+		}
+		for _, pattern := range check.CacheKeyFiles {
+			if strings.TrimSpace(pattern) == "" {
+				return fmt.Errorf("check %q: cache_key_files entries must not be empty", check.Name)
+			}
+		}
+	}
+	if c.Discovery != nil && c.Discovery.Command == "" {
+		return fmt.Errorf("discovery: command is required")
+	}
+	switch c.Reload {
+	case "ignore", "exit", "reload":
+	default:
+		return fmt.Errorf("reload must be ignore, exit or reload, got %q", c.Reload)
+	}
+	if c.MaxCheckConcurrency < 0 || c.MaxServiceConcurrency < 0 {
+		return fmt.Errorf("max_check_concurrency and max_service_concurrency must not be negative")
+	}
+	if c.API.SSEHeartbeat < 0 {
+		return fmt.Errorf("api: sse_heartbeat must not be negative")
+	}
+	if c.Overall.FailPercent < 0 || c.Overall.FailPercent >= 100 {
+		return fmt.Errorf("overall: fail_percent must be at least 0 and below 100, got %v", c.Overall.FailPercent)
+	}
+	if err := c.Display.validate(); err != nil {
+		return err
+	}
+	if c.OTel != nil && c.OTel.Endpoint == "" {
+		return fmt.Errorf("otel: endpoint is required")
+	}
+	if c.Metrics.PushgatewayURL != "" {
+		u, err := url.Parse(c.Metrics.PushgatewayURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("metrics: pushgateway_url must be an http or https URL, got %q", c.Metrics.PushgatewayURL)
+		}
+	}
+	for i, out := range c.Outputs {
+		switch out.Type {
+		case "file":
+			if out.Path == "" {
+				return fmt.Errorf("outputs[%d]: type file requires a path", i)
+			}
+		case "http":
+			u, err := url.Parse(out.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("outputs[%d]: url must be an http or https URL, got %q", i, out.URL)
+			}
+		default:
+			return fmt.Errorf("outputs[%d]: type must be file or http, got %q", i, out.Type)
+		}
+		if out.Buffer < 0 {
+			return fmt.Errorf("outputs[%d]: buffer must not be negative", i)
+		}
+	}
+	return c.Notifications.validate()
+}
+
+// validateDependencies checks that every depends_on names a configured
+// monitor and that no chain of them loops
+func (c *Config) validateDependencies() error {
+	names := make(map[string]bool)
+	for _, check := range c.Checks {
+		names[check.Name] = true
+	}
+	dependsOn := make(map[string]string)
+	for _, svc := range c.Services {
+		names[svc.Name] = true
+		if svc.DependsOn != "" {
+			dependsOn[svc.Name] = svc.DependsOn
+		}
+	}
+
+	for name, dep := range dependsOn {
+		if !names[dep] {
+			return fmt.Errorf("service %q: depends_on %q is not a configured monitor", name, dep)
+		}
+		// A chain is at most as long as there are dependencies
+		for next, steps := dep, 0; next != ""; next, steps = dependsOn[next], steps+1 {
+			if next == name || steps > len(dependsOn) {
+				return fmt.Errorf("service %q: depends_on forms a cycle", name)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *ServiceConfig) validate() error {
+	switch s.Expect {
+	case "", "open", "closed":
+	default:
+		return fmt.Errorf("service %q: expect must be open or closed, got %q", s.Name, s.Expect)
+	}
+	if len(s.Ports) > 0 && s.Type != "tcp" {
+		return fmt.Errorf("service %q: ports are only supported for type tcp", s.Name)
+	}
+	for _, port := range s.Ports {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("service %q: port %d out of range", s.Name, port)
+		}
+	}
+	if (s.Type == "exec" || s.Type == "metric") && s.Command == "" {
+		return fmt.Errorf("service %q: type %s requires a command", s.Name, s.Type)
+	}
+	if s.Type == "log" {
+		if s.Path == "" {
+			return fmt.Errorf("service %q: type log requires a path", s.Name)
+		}
+		if s.WarnIdle < 0 || s.MaxIdle < 0 || (s.MaxIdle > 0 && s.WarnIdle > s.MaxIdle) {
+			return fmt.Errorf("service %q: warn_idle and max_idle must be non-negative with warn_idle <= max_idle", s.Name)
+		}
+		if _, err := regexp.Compile(s.ErrorPattern); err != nil {
+			return fmt.Errorf("service %q: invalid error_pattern: %w", s.Name, err)
+		}
+	} else if s.Path != "" || s.WarnIdle != 0 || s.MaxIdle != 0 || s.ErrorPattern != "" {
+		return fmt.Errorf("service %q: path, warn_idle, max_idle and error_pattern are only supported for type log", s.Name)
+	}
+	if s.Type != "metric" && s.Type != "sql" && (s.WarnAbove != nil || s.FailAbove != nil || s.WarnBelow != nil || s.FailBelow != nil) {
+		return fmt.Errorf("service %q: warn_above, fail_above, warn_below and fail_below are only supported for types metric and sql", s.Name)
+	}
+	if s.Type == "sql" {
+		if err := s.validateSQL(); err != nil {
+			return fmt.Errorf("service %q: %w", s.Name, err)
+		}
+	} else if s.ProbeQuery != "" {
+		return fmt.Errorf("service %q: probe_query is only supported for type sql", s.Name)
+	}
+	if s.Type == "k8s" && s.Deployment == "" && s.Selector == "" {
+		return fmt.Errorf("service %q: type k8s requires a deployment or selector", s.Name)
+	}
+	if s.SuccessExpr != "" {
+		if s.Type != "rest" {
+			return fmt.Errorf("service %q: success_expr is only supported for type rest", s.Name)
+		}
+		if _, err := expr.Parse(s.SuccessExpr, SuccessExprVars...); err != nil {
+			return fmt.Errorf("service %q: invalid success_expr: %w", s.Name, err)
+		}
+	}
+	if s.ExpectFingerprint != "" {
+		if s.Type != "cert" {
+			return fmt.Errorf("service %q: expect_fingerprint is only supported for type cert", s.Name)
+		}
+		if _, err := hex.DecodeString(NormalizeFingerprint(s.ExpectFingerprint)); err != nil || len(NormalizeFingerprint(s.ExpectFingerprint)) != 64 {
+			return fmt.Errorf("service %q: expect_fingerprint must be a SHA-256 fingerprint (64 hex digits), got %q", s.Name, s.ExpectFingerprint)
+		}
+	}
+	if s.ExpectSchema != "" {
+		if s.Type != "rest" {
+			return fmt.Errorf("service %q: expect_schema is only supported for type rest", s.Name)
+		}
+		if _, err := schema.Load(s.ExpectSchema); err != nil {
+			return fmt.Errorf("service %q: expect_schema: %w", s.Name, err)
+		}
+	}
+	if (s.MinBytes != 0 || s.MaxBytes != 0) && s.Type != "rest" {
+		return fmt.Errorf("service %q: min_bytes and max_bytes are only supported for type rest", s.Name)
+	}
+	if s.MinBytes < 0 || s.MaxBytes < 0 || (s.MaxBytes > 0 && s.MinBytes > s.MaxBytes) {
+		return fmt.Errorf("service %q: min_bytes and max_bytes must be non-negative with min_bytes <= max_bytes", s.Name)
+	}
+	if s.GRPCMethod != "" || s.GRPCRequest != "" {
+		if s.Type != "grpc-web" {
+			return fmt.Errorf("service %q: grpc_method and grpc_request are only supported for type grpc-web", s.Name)
+		}
+		service, method, ok := strings.Cut(strings.TrimPrefix(s.GRPCMethod, "/"), "/")
+		if !strings.HasPrefix(s.GRPCMethod, "/") || !ok || service == "" || method == "" || strings.Contains(method, "/") {
+			return fmt.Errorf("service %q: grpc_method must look like /package.Service/Method, got %q", s.Name, s.GRPCMethod)
+		}
+		if s.GRPCService != "" {
+			return fmt.Errorf("service %q: grpc_service only applies to the health check, not grpc_method", s.Name)
+		}
+		if s.GRPCRequest != "" {
+			var request map[string]interface{}
+			if err := json.Unmarshal([]byte(s.GRPCRequest), &request); err != nil {
+				return fmt.Errorf("service %q: grpc_request must be a JSON object: %w", s.Name, err)
+			}
+		}
+	}
+	switch s.Probe {
+	case "", "readiness":
+	case "liveness":
+		if s.Type != "rest" {
+			return fmt.Errorf("service %q: probe is only supported for type rest", s.Name)
+		}
+		if s.SuccessExpr != "" {
+			return fmt.Errorf("service %q: probe liveness can't be combined with success_expr, which decides the status itself", s.Name)
+		}
+		// Body assertions only run on a ready response, so they would
+		// silently be skipped whenever the service is merely alive
+		if len(s.ExpectJSON) > 0 || s.ExpectSchema != "" || s.MinBytes != 0 || s.MaxBytes != 0 {
+			return fmt.Errorf("service %q: probe liveness can't be combined with expect_json, expect_schema, min_bytes or max_bytes", s.Name)
+		}
+	default:
+		return fmt.Errorf("service %q: probe must be liveness or readiness, got %q", s.Name, s.Probe)
+	}
+	if s.Trace && s.Type != "rest" {
+		return fmt.Errorf("service %q: trace is only supported for type rest", s.Name)
+	}
+	if len(s.PreRequests) > 0 && s.Type != "rest" {
+		return fmt.Errorf("service %q: pre_requests are only supported for type rest", s.Name)
+	}
+	for i, pre := range s.PreRequests {
+		if !strings.HasPrefix(pre.Path, "/") {
+			return fmt.Errorf("service %q: pre_requests[%d]: path must start with /, got %q", s.Name, i, pre.Path)
+		}
+		if strings.ContainsAny(pre.Method, " \t/") {
+			return fmt.Errorf("service %q: pre_requests[%d]: invalid method %q", s.Name, i, pre.Method)
+		}
+	}
+	if s.ConnectTimeout < 0 {
+		return fmt.Errorf("service %q: connect_timeout must not be negative", s.Name)
+	}
+	if s.BodyTimeout < 0 {
+		return fmt.Errorf("service %q: body_timeout must not be negative", s.Name)
+	}
+	switch s.HTTPVersion {
+	case "", "auto", "1.1":
+	case "2":
+		// Go's client only speaks HTTP/2 over TLS, where ALPN negotiates it
+		if strings.HasPrefix(s.URL, "http://") {
+			return fmt.Errorf("service %q: http_version 2 requires an https url", s.Name)
+		}
+	default:
+		return fmt.Errorf("service %q: http_version must be 1.1, 2 or auto, got %q", s.Name, s.HTTPVersion)
+	}
+	if s.Proxy != "" && s.Proxy != "none" {
+		u, err := url.Parse(s.Proxy)
+		if err != nil {
+			return fmt.Errorf("service %q: invalid proxy: %w", s.Name, err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return fmt.Errorf("service %q: proxy must be an http, https or socks5 url, or none, got %q", s.Name, s.Proxy)
+		}
+		if u.Host == "" {
+			return fmt.Errorf("service %q: proxy %q has no host", s.Name, s.Proxy)
+		}
+	}
+	return nil
+}
+
+// validateSQL checks a type: sql service's PostgreSQL url
+func (s *ServiceConfig) validateSQL() error {
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") || u.Host == "" {
+		return fmt.Errorf("type sql requires a postgres:// url, got %q", s.URL)
+	}
+	if u.User.Username() == "" && s.Username == "" {
+		return fmt.Errorf("type sql requires a user in the url or username")
+	}
+	switch mode := u.Query().Get("sslmode"); mode {
+	case "", "disable", "prefer", "require", "verify-full":
+	default:
+		return fmt.Errorf("sslmode must be disable, prefer, require or verify-full, got %q", mode)
+	}
+	return nil
+}
+
+func (n *NotificationsConfig) validate() error {
+	if n.MaxPerMinute < 0 {
+		return fmt.Errorf("notifications: max_per_minute must not be negative")
+	}
+	if n.CoalesceWindow < 0 {
+		return fmt.Errorf("notifications: coalesce_window must not be negative")
+	}
+	if n.Retries < 0 {
+		return fmt.Errorf("notifications: retries must not be negative")
+	}
+	if n.ReminderInterval < 0 {
+		return fmt.Errorf("notifications: reminder_interval must not be negative")
+	}
+	for _, kind := range n.NotifyOn {
+		switch kind {
+		case "fail", "warn", "info", "recovery":
+		default:
+			return fmt.Errorf("notifications: notify_on must list fail, warn, info or recovery, got %q", kind)
+		}
+	}
+	for i := range n.Channels {
+		ch := &n.Channels[i]
+		if ch.Name == "" {
+			ch.Name = fmt.Sprintf("%s-%d", ch.Type, i+1)
+		}
+		switch ch.Type {
+		case "webhook", "slack":
+		default:
+			return fmt.Errorf("notification channel %q: unknown type %q", ch.Name, ch.Type)
+		}
+		if ch.URL == "" {
+			return fmt.Errorf("notification channel %q: url is required", ch.Name)
+		}
+		if ch.Template != "" {
+			tmpl, err := template.New(ch.Name).Parse(ch.Template)
+			if err != nil {
+				return fmt.Errorf("notification channel %q: invalid template: %w", ch.Name, err)
+			}
+			// Parsing misses misspelled fields; they only fail on execution
+			if err := tmpl.Execute(io.Discard, templateSample); err != nil {
+				return fmt.Errorf("notification channel %q: invalid template: %w", ch.Name, err)
+			}
+		}
+	}
+
+	channels := make(map[string]bool, len(n.Channels))
+	for _, ch := range n.Channels {
+		channels[ch.Name] = true
+	}
+	for i, route := range n.Routes {
+		if len(route.Channels) == 0 {
+			return fmt.Errorf("notifications: routes[%d]: channels is required", i)
+		}
+		for _, name := range route.Channels {
+			if !channels[name] {
+				return fmt.Errorf("notifications: routes[%d]: unknown channel %q", i, name)
+			}
+		}
+		for _, kind := range route.Status {
+			switch kind {
+			case "fail", "warn", "info", "recovery":
+			default:
+				return fmt.Errorf("notifications: routes[%d]: status must list fail, warn, info or recovery, got %q", i, kind)
+			}
+		}
+	}
+	return nil
 }
@@ -0,0 +1,3 @@
+package config
+
+var TemplateSample = templateSample
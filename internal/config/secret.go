@@ -0,0 +1,26 @@
+package config
+
+// Secret holds a credential loaded from config. It reveals its value only
+// through Value so it is redacted whenever a config is printed or encoded.
+type Secret string
+
+const redacted = "********"
+
+func (s Secret) Value() string {
+	return string(s)
+}
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + s.String() + `"`), nil
+}
+
+func (s Secret) MarshalYAML() (interface{}, error) {
+	return s.String(), nil
+}
@@ -0,0 +1,66 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/orchard9/watch-now/internal/config"
+	"github.com/orchard9/watch-now/internal/notify"
+)
+
+// The sample templates are validated against must offer exactly the
+// fields of the event they are rendered with.
+func TestTemplateSampleMatchesEvent(t *testing.T) {
+	sample := reflect.TypeOf(config.TemplateSample)
+	event := reflect.TypeOf(notify.Event{})
+	if sample.NumField() != event.NumField() {
+		t.Fatalf("sample has %d fields, notify.Event %d", sample.NumField(), event.NumField())
+	}
+	for i := 0; i < event.NumField(); i++ {
+		want := event.Field(i)
+		got, ok := sample.FieldByName(want.Name)
+		if !ok {
+			t.Errorf("sample lacks field %s", want.Name)
+			continue
+		}
+		if got.Type.Kind() != want.Type.Kind() {
+			t.Errorf("field %s is a %s, notify.Event has a %s", want.Name, got.Type.Kind(), want.Type.Kind())
+		}
+	}
+}
+
+func TestChannelTemplateValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		wantErr  string
+	}{
+		{"valid", "{{.Name}} went {{.Old}} -> {{.New}}: {{.Message}} {{.Metadata.status_code}} {{.Labels.team}}", ""},
+		{"durations and times", "{{.Duration.Seconds}} {{.Timestamp.Format \"15:04\"}} {{if .Recovered}}{{.Downtime}}{{end}}", ""},
+		{"parse error", "{{.Name", "invalid template"},
+		{"misspelled field", "{{.Nmae}}", "can't evaluate field Nmae"},
+		{"bad method", "{{.Name.Upper}}", "invalid template"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			yaml := "notifications:\n  channels:\n    - name: ops\n      type: webhook\n" +
+				"      url: http://localhost/hook\n      template: '" + strings.ReplaceAll(tt.template, "'", "''") + "'\n"
+			if err := os.WriteFile(path, []byte(yaml), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := config.Load(path)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("error = %v, want one containing %q", err, tt.wantErr)
+			case tt.wantErr != "" && !strings.Contains(err.Error(), `"ops"`):
+				t.Errorf("error %v does not name the channel", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/orchard9/watch-now/internal/monitors"
+)
+
+// Ack acknowledges a monitor's ongoing WARN or FAIL. It keeps showing its
+// real status, flagged acked, but transitions stop notifying until it
+// recovers to OK, which clears the ack.
+func (s *StateStore) Ack(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := s.results[name]
+	if result == nil {
+		return fmt.Errorf("no result for %s yet", name)
+	}
+	if result.Status != monitors.StatusWarn && result.Status != monitors.StatusFail {
+		return fmt.Errorf("%s is %s, only a warning or failure can be acknowledged", name, result.Status)
+	}
+	if s.acked == nil {
+		s.acked = make(map[string]bool)
+	}
+	s.acked[name] = true
+	s.replaceAcked(name, true)
+	return nil
+}
+
+// Unack withdraws an acknowledgement, so notifications resume
+func (s *StateStore) Unack(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.acked, name)
+	s.replaceAcked(name, false)
+}
+
+// replaceAcked stores a copy of the current result with its acked flag
+// set, since readers may hold the old one. Callers hold s.mu.
+func (s *StateStore) replaceAcked(name string, acked bool) {
+	result := s.results[name]
+	if result == nil || result.Acked == acked {
+		return
+	}
+	copied := *result
+	copied.Acked = acked
+	s.results[name] = &copied
+	s.signalChanges()
+}
+
+// applyAck flags a result from an acknowledged monitor, or clears the ack
+// once the monitor is OK again. Callers hold s.mu.
+func (s *StateStore) applyAck(result *monitors.Result) {
+	if !s.acked[result.Name] {
+		return
+	}
+	if result.Status == monitors.StatusOK {
+		delete(s.acked, result.Name)
+		return
+	}
+	result.Acked = true
+}
@@ -0,0 +1,135 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/monitors"
+)
+
+// cacheSpec lists the files whose contents decide whether a check's last
+// result still holds. Patterns match like watch_patterns, relative to dir.
+type cacheSpec struct {
+	dir      string
+	patterns []string
+}
+
+type cachedResult struct {
+	key    string
+	result *monitors.Result
+}
+
+// resultCache remembers each cached check's last result and input hash
+type resultCache struct {
+	mu      sync.Mutex
+	specs   map[string]cacheSpec
+	results map[string]cachedResult
+}
+
+func newResultCache() *resultCache {
+	return &resultCache{
+		specs:   make(map[string]cacheSpec),
+		results: make(map[string]cachedResult),
+	}
+}
+
+// key hashes the monitor's cache files, or returns "" if it isn't cached
+// or none of its patterns match a file
+func (c *resultCache) key(name string) string {
+	spec, ok := c.specs[name]
+	if !ok {
+		return ""
+	}
+	return hashFiles(spec.dir, spec.patterns)
+}
+
+// lookup returns a copy of the stored result, marked cached, if it was
+// recorded for the same key
+func (c *resultCache) lookup(name, key string) *monitors.Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.results[name]
+	if !ok || entry.key != key {
+		return nil
+	}
+	result := cloneResult(entry.result)
+	result.Metadata["cached"] = true
+	result.Metadata["cached_from"] = entry.result.Timestamp.Format(time.RFC3339)
+	result.Timestamp = time.Now()
+	return result
+}
+
+// store keeps a result for reuse. Timed-out runs say nothing about the
+// inputs, so they are never stored.
+func (c *resultCache) store(name, key string, result *monitors.Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if timedOut, _ := result.Metadata["timed_out"].(bool); timedOut {
+		delete(c.results, name)
+		return
+	}
+	c.results[name] = cachedResult{key: key, result: cloneResult(result)}
+}
+
+// cloneResult copies a result so state updates can't alter the cached one
+func cloneResult(result *monitors.Result) *monitors.Result {
+	clone := *result
+	clone.Metadata = make(map[string]interface{}, len(result.Metadata)+2)
+	for k, v := range result.Metadata {
+		clone.Metadata[k] = v
+	}
+	return &clone
+}
+
+// hashFiles digests the path and contents of every file under dir matching
+// one of the patterns. Skipped and hidden directories are ignored, as for
+// --watch. With no matching file there is nothing to key on, so it returns
+// "" instead of the hash of nothing, which would never change.
+func hashFiles(dir string, patterns []string) string {
+	if dir == "" {
+		dir = "."
+	}
+	matched := false
+	h := sha256.New()
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != dir && (skippedDirs[name] || strings.HasPrefix(name, ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, _ := filepath.Rel(dir, path)
+		rel = filepath.ToSlash(rel)
+		if !anyPathMatches(patterns, []string{rel}) {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil
+		}
+		defer f.Close()
+		matched = true
+		_, _ = io.WriteString(h, rel+"\x00")
+		_, _ = io.Copy(h, f)
+		_, _ = h.Write([]byte{0})
+		return nil
+	})
+	if !matched {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
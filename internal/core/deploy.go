@@ -0,0 +1,37 @@
+package core
+
+import (
+	"time"
+
+	"github.com/orchard9/watch-now/internal/monitors"
+)
+
+// SetDeployMode downgrades REST 5xx failures to WARN until the given time,
+// covering the brief errors of a rolling deploy. A zero time ends it.
+func (s *StateStore) SetDeployMode(until time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deployUntil = until
+}
+
+// DeployMode returns when the current deploy window ends, if one is active
+func (s *StateStore) DeployMode() (time.Time, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.deployUntil, time.Now().Before(s.deployUntil)
+}
+
+// applyDeployMode turns a REST server error into a warning while a deploy
+// window is open. Callers hold s.mu.
+func (s *StateStore) applyDeployMode(result *monitors.Result) {
+	if result.Type != monitors.TypeREST || result.Status != monitors.StatusFail || !time.Now().Before(s.deployUntil) {
+		return
+	}
+	if code, ok := result.Metadata["status_code"].(int); !ok || code < 500 {
+		return
+	}
+
+	result.Status = monitors.StatusWarn
+	result.Message += " (deploy in progress)"
+	result.Metadata["deploy_mode"] = true
+}
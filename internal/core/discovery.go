@@ -0,0 +1,128 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"reflect"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/config"
+	"github.com/orchard9/watch-now/internal/monitors"
+)
+
+// runDiscovery refreshes discovered services until ctx is done
+func (e *Engine) runDiscovery(ctx context.Context) {
+	discovered := make(map[string]config.ServiceConfig)
+
+	ticker := time.NewTicker(e.config.Discovery.Interval)
+	defer ticker.Stop()
+
+	for {
+		added, err := e.discover(ctx, discovered)
+		if err != nil {
+			// Keep the last known set rather than dropping every monitor
+			log.Printf("Service discovery failed: %v", err)
+		}
+		// New and changed services run right away rather than at the
+		// next tick
+		if len(added) > 0 && !e.scheduler.paused.Load() {
+			e.scheduler.runMonitors(ctx, added)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// discover runs the discovery command once and reconciles the scheduled
+// monitors with its output. It returns the monitors of new and changed
+// services, which have not run yet.
+func (e *Engine) discover(ctx context.Context, discovered map[string]config.ServiceConfig) ([]monitors.Monitor, error) {
+	services, err := e.runDiscoveryCommand(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	static := e.configuredNames()
+	current := make(map[string]bool)
+	var added []monitors.Monitor
+	for _, svc := range services {
+		if static[svc.Name] {
+			log.Printf("Discovered service %s ignored: name is already configured", svc.Name)
+			continue
+		}
+		current[svc.Name] = true
+		if prev, ok := discovered[svc.Name]; ok && reflect.DeepEqual(prev, svc) {
+			continue
+		}
+
+		newMonitor, ok := serviceMonitors[svc.Type]
+		if !ok {
+			log.Printf("Discovered service %s ignored: unknown type %q", svc.Name, svc.Type)
+			continue
+		}
+		monitor := newMonitor(svc)
+		configureServiceState(e.state, svc)
+		e.scheduler.AddMonitor(monitor)
+		discovered[svc.Name] = svc
+		added = append(added, monitor)
+	}
+
+	e.forgetMissing(discovered, current)
+	if e.exporter != nil {
+		// Checks still running when their monitor went away may have
+		// recorded since, so prune against the whole scheduled set
+		scheduled := make(map[string]bool)
+		for _, m := range e.scheduler.Monitors() {
+			scheduled[m.Name()] = true
+		}
+		e.exporter.Prune(scheduled)
+	}
+	return added, nil
+}
+
+// forgetMissing removes discovered monitors absent from the latest output
+func (e *Engine) forgetMissing(discovered map[string]config.ServiceConfig, current map[string]bool) {
+	for name := range discovered {
+		if !current[name] {
+			e.scheduler.RemoveMonitor(name)
+			e.state.Remove(name)
+			delete(discovered, name)
+		}
+	}
+}
+
+// configuredNames returns the monitors defined in the config file, which
+// discovery may not replace
+func (e *Engine) configuredNames() map[string]bool {
+	names := make(map[string]bool)
+	for _, svc := range e.config.Services {
+		names[svc.Name] = true
+	}
+	for _, check := range e.config.Checks {
+		names[check.Name] = true
+	}
+	return names
+}
+
+func (e *Engine) runDiscoveryCommand(ctx context.Context) ([]config.ServiceConfig, error) {
+	cfg := e.config.Discovery
+	cmdCtx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(cmdCtx, cfg.Command, cfg.Args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %v: %s", cfg.Command, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	return config.ParseDiscoveredServices(stdout.Bytes())
+}
@@ -3,11 +3,17 @@ package core
 import (
 	"context"
 	"fmt"
+	"log"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/orchard9/watch-now/internal/config"
+	"github.com/orchard9/watch-now/internal/cron"
 	"github.com/orchard9/watch-now/internal/monitors"
+	"github.com/orchard9/watch-now/internal/notify"
+	"github.com/orchard9/watch-now/internal/output"
+	"github.com/orchard9/watch-now/internal/telemetry"
 )
 
 type Engine struct {
@@ -15,112 +21,371 @@ type Engine struct {
 	monitors  []monitors.Monitor
 	state     *StateStore
 	scheduler *Scheduler
+
+	// displayRank is each display_order name's position
+	displayRank map[string]int
+
+	gitMu sync.RWMutex
+	git   *GitContext
+
+	exporter *telemetry.Exporter
+	pusher   *telemetry.Pusher
+	outputs  []*output.Writer
+	notifier *notify.Notifier
 }
 
 func NewEngine(cfg *config.Config) *Engine {
+	state := NewStateStore()
+	state.SetFlapDetection(cfg.FlapDetection.Threshold, cfg.FlapDetection.Window)
+	state.SetFailPercent(cfg.Overall.FailPercent)
+	for _, svc := range cfg.Services {
+		configureServiceState(state, svc)
+	}
+	for _, check := range cfg.Checks {
+		state.SetWarnEscalation(check.Name, check.WarnEscalation)
+		state.SetLabels(check.Name, check.Labels)
+		state.SetCritical(check.Name, check.Critical == nil || *check.Critical)
+	}
+
+	displayRank := make(map[string]int)
+	for i, name := range cfg.DisplayOrder {
+		if _, ok := displayRank[name]; !ok {
+			displayRank[name] = i
+		}
+	}
+
 	return &Engine{
-		config: cfg,
-		state:  NewStateStore(),
+		config:      cfg,
+		state:       state,
+		displayRank: displayRank,
 	}
 }
 
+// configureServiceState applies a service's per-monitor state rules
+func configureServiceState(state *StateStore, svc config.ServiceConfig) {
+	state.SetWarnEscalation(svc.Name, svc.WarnEscalation)
+	state.SetLatencySLO(svc.Name, svc.SLO)
+	state.SetLabels(svc.Name, svc.Labels)
+	state.SetCritical(svc.Name, svc.Critical == nil || *svc.Critical)
+	state.SetDependsOn(svc.Name, svc.DependsOn)
+}
+
+// serviceMonitors maps a service type to its monitor constructor
+var serviceMonitors = map[string]func(config.ServiceConfig) monitors.Monitor{
+	"rest":     func(c config.ServiceConfig) monitors.Monitor { return monitors.NewRESTMonitor(c) },
+	"cert":     func(c config.ServiceConfig) monitors.Monitor { return monitors.NewCertMonitor(c) },
+	"kafka":    func(c config.ServiceConfig) monitors.Monitor { return monitors.NewKafkaMonitor(c) },
+	"tcp":      func(c config.ServiceConfig) monitors.Monitor { return monitors.NewTCPMonitor(c) },
+	"grpc-web": func(c config.ServiceConfig) monitors.Monitor { return monitors.NewGRPCWebMonitor(c) },
+	"k8s":      func(c config.ServiceConfig) monitors.Monitor { return monitors.NewK8sMonitor(c) },
+	"exec":     func(c config.ServiceConfig) monitors.Monitor { return monitors.NewExecMonitor(c) },
+	"metric":   func(c config.ServiceConfig) monitors.Monitor { return monitors.NewMetricMonitor(c) },
+	"log":      func(c config.ServiceConfig) monitors.Monitor { return monitors.NewLogMonitor(c) },
+	"sql":      func(c config.ServiceConfig) monitors.Monitor { return monitors.NewSQLMonitor(c) },
+}
+
 func (e *Engine) Initialize() error {
 	// Create service monitors
 	for _, serviceCfg := range e.config.Services {
-		switch serviceCfg.Type {
-		case "rest":
-			monitor := monitors.NewRESTMonitor(serviceCfg)
-			e.monitors = append(e.monitors, monitor)
-		case "grpc":
+		if newMonitor, ok := serviceMonitors[serviceCfg.Type]; ok {
+			e.monitors = append(e.monitors, newMonitor(serviceCfg))
+		} else if serviceCfg.Type == "grpc" {
 			// TODO: Implement gRPC monitor
 			fmt.Printf("Warning: gRPC monitor not yet implemented for %s\n", serviceCfg.Name)
-		default:
+		} else {
 			fmt.Printf("Warning: unknown service type %s for %s\n", serviceCfg.Type, serviceCfg.Name)
 		}
 	}
 
 	// Create quality monitors from checks
 	for _, checkCfg := range e.config.Checks {
-		monitor := monitors.NewQualityMonitor(checkCfg)
+		monitor := monitors.NewQualityMonitor(checkCfg, e.config.Artifacts)
 		e.monitors = append(e.monitors, monitor)
 	}
 
+	e.refreshGitContext(".")
+
+	if err := e.setupNotifications(); err != nil {
+		return err
+	}
+	e.setupHooks()
+	for _, outCfg := range e.config.Outputs {
+		out, err := output.New(outCfg)
+		if err != nil {
+			return err
+		}
+		e.outputs = append(e.outputs, out)
+		e.state.OnResult(out.Write)
+	}
+
 	// Create scheduler
 	e.scheduler = NewScheduler(e.config.Interval, e.monitors, e.state)
+	e.scheduler.SetConcurrency(e.config.MaxCheckConcurrency, e.config.MaxServiceConcurrency)
+	e.scheduler.watchPatterns = make(map[string][]string)
+	e.scheduler.groups = make(map[string]string)
+	e.scheduler.schedules = make(map[string]*cron.Schedule)
+	for _, checkCfg := range e.config.Checks {
+		if checkCfg.Schedule != "" {
+			schedule, err := cron.Parse(checkCfg.Schedule)
+			if err != nil {
+				return fmt.Errorf("check %q: schedule: %w", checkCfg.Name, err)
+			}
+			e.scheduler.schedules[checkCfg.Name] = schedule
+		}
+		e.scheduler.watchPatterns[checkCfg.Name] = checkCfg.WatchPatterns
+		if checkCfg.SerializeGroup != "" {
+			e.scheduler.groups[checkCfg.Name] = checkCfg.SerializeGroup
+		}
+		if len(checkCfg.CacheKeyFiles) > 0 {
+			e.scheduler.cache.specs[checkCfg.Name] = cacheSpec{dir: checkCfg.Dir, patterns: checkCfg.CacheKeyFiles}
+		}
+	}
+
+	if e.config.OTel != nil {
+		e.exporter = telemetry.New(*e.config.OTel)
+		e.scheduler.onCheck = e.exporter.Record
+	}
+	if e.config.Metrics.PushgatewayURL != "" {
+		e.pusher = telemetry.NewPusher(e.config.Metrics)
+		e.scheduler.onCycle = func() { e.pusher.Update(e.state.GetAll()) }
+	}
+
+	return nil
+}
+
+// setupNotifications wires configured channels to status transitions
+func (e *Engine) setupNotifications() error {
+	if len(e.config.Notifications.Channels) == 0 {
+		return nil
+	}
 
+	notifier, err := notify.New(e.config.Notifications)
+	if err != nil {
+		return fmt.Errorf("creating notifier: %w", err)
+	}
+	e.notifier = notifier
+	notifyTransition := func(t Transition) {
+		// An acknowledged failure stays quiet until it recovers
+		if t.Acked {
+			return
+		}
+		// A flapping monitor gets one alert instead of one per change,
+		// but its outages are still followed for reminders
+		if t.Flapping && !t.FlapStarted {
+			notifier.Track(eventFromTransition(t))
+			return
+		}
+		notifier.Notify(eventFromTransition(t))
+	}
+	e.state.OnTransition(notifyTransition)
+	// The end of a flap is announced, so the last alert isn't "flapping"
+	e.state.OnSettled(notifyTransition)
 	return nil
 }
 
+// setupHooks runs each monitor's on_transition command when it changes status
+func (e *Engine) setupHooks() {
+	hooks := e.config.Hooks()
+	if len(hooks) == 0 {
+		return
+	}
+
+	e.state.OnTransition(func(t Transition) {
+		hook, ok := hooks[t.Name]
+		if !ok || (t.Old == "" && t.New == monitors.StatusOK) {
+			return
+		}
+		notify.RunHook(hook, eventFromTransition(t))
+	})
+}
+
+func eventFromTransition(t Transition) notify.Event {
+	event := notify.Event{
+		Name:      t.Name,
+		Type:      t.Result.Type,
+		Old:       t.Old,
+		New:       t.New,
+		Message:   t.Result.Message,
+		Duration:  t.Result.Duration,
+		Metadata:  t.Result.Metadata,
+		Labels:    t.Result.Labels,
+		Timestamp: t.Result.Timestamp,
+	}
+	if t.New == monitors.StatusOK && (t.Old == monitors.StatusWarn || t.Old == monitors.StatusFail) {
+		event.Recovered = true
+		event.Downtime = t.Downtime.Round(time.Second)
+	}
+	if t.FlapStarted {
+		event.Flapping = true
+		event.Message = fmt.Sprintf("%s is flapping (%d status changes), now %s: %s", t.Name, t.Changes, t.New, t.Result.Message)
+	}
+	if t.FlapEnded {
+		event.Stabilized = true
+		event.Message = fmt.Sprintf("%s stopped flapping, settled at %s: %s", t.Name, t.New, t.Result.Message)
+	}
+	return event
+}
+
 func (e *Engine) Start(ctx context.Context) error {
+	if e.config.Discovery != nil {
+		go e.runDiscovery(ctx)
+	}
+	if e.exporter != nil {
+		go e.exporter.Run(ctx)
+	}
+	if e.pusher != nil {
+		go e.pusher.Run(ctx)
+	}
+
 	// Start scheduler
 	return e.scheduler.Start(ctx)
 }
 
-func (e *Engine) State() *StateStore {
-	return e.state
+// RunOnce runs every monitor once and returns as soon as all of them have
+// finished. Discovery runs a single pass first, so discovered services are
+// covered too; unlike Start there is no interval or schedule.
+func (e *Engine) RunOnce(ctx context.Context) {
+	if e.config.Discovery != nil {
+		if _, err := e.discover(ctx, make(map[string]config.ServiceConfig)); err != nil {
+			log.Printf("Service discovery failed: %v", err)
+		}
+	}
+	e.scheduler.RunOnce(ctx)
 }
 
-func (e *Engine) MonitorCount() int {
-	return len(e.monitors)
+// FlushTelemetry exports pending OTel data, pushes the latest metrics and
+// drains the outputs, for runs that exit before the next export or push
+func (e *Engine) FlushTelemetry() {
+	for _, out := range e.outputs {
+		out.Flush(5 * time.Second)
+	}
+	if e.exporter != nil {
+		e.exporter.Flush()
+	}
+	if e.pusher != nil {
+		e.pusher.Push(e.state.GetAll())
+	}
 }
 
-type Scheduler struct {
-	interval time.Duration
-	monitors []monitors.Monitor
-	state    *StateStore
+// Ack acknowledges a monitor's ongoing failure, silencing its
+// notifications and reminders until it recovers.
+func (e *Engine) Ack(name string) error {
+	if err := e.state.Ack(name); err != nil {
+		return err
+	}
+	if e.notifier != nil {
+		e.notifier.Forget(name)
+	}
+	return nil
 }
 
-func NewScheduler(interval time.Duration, monitors []monitors.Monitor, state *StateStore) *Scheduler {
-	return &Scheduler{
-		interval: interval,
-		monitors: monitors,
-		state:    state,
-	}
+// Pause stops scheduled checks from running; last results are kept.
+func (e *Engine) Pause() {
+	e.scheduler.paused.Store(true)
+}
+
+// Resume re-enables scheduled checks after Pause.
+func (e *Engine) Resume() {
+	e.scheduler.paused.Store(false)
 }
 
-func (s *Scheduler) Start(ctx context.Context) error {
-	// Run initial check
-	s.runChecks(ctx)
+func (e *Engine) Paused() bool {
+	return e.scheduler != nil && e.scheduler.paused.Load()
+}
+
+// Watch reruns affected checks whenever files under root change.
+func (e *Engine) Watch(ctx context.Context, root string) {
+	NewFileWatcher(root, time.Second).Watch(ctx, func(changed []string) {
+		// Checkouts and pulls change files, so this catches a moved HEAD
+		e.refreshGitContext(root)
+		e.scheduler.Trigger(changed)
+	})
+}
 
-	// Set up ticker for periodic checks
-	ticker := time.NewTicker(s.interval)
-	defer ticker.Stop()
+func (e *Engine) State() *StateStore {
+	return e.state
+}
 
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-ticker.C:
-			s.runChecks(ctx)
+func (e *Engine) MonitorCount() int {
+	return len(e.scheduler.Monitors())
+}
+
+// FailUnfinished records a FAIL for every scheduled monitor that has not
+// reported yet and returns their names. It lets --once finish a report
+// while some checks are still hanging.
+func (e *Engine) FailUnfinished(message string) []string {
+	var names []string
+	for _, m := range e.scheduler.Monitors() {
+		if e.state.Get(m.Name()) != nil {
+			continue
 		}
+		e.state.Update(&monitors.Result{
+			Name:      m.Name(),
+			Type:      m.Type(),
+			Status:    monitors.StatusFail,
+			Message:   message,
+			Timestamp: time.Now(),
+		})
+		names = append(names, m.Name())
 	}
+	return names
 }
 
-func (s *Scheduler) runChecks(ctx context.Context) {
-	var wg sync.WaitGroup
+// Monitors describes every configured monitor, whether or not it has run.
+func (e *Engine) Monitors() []monitors.Info {
+	list := e.scheduler.Monitors()
+	infos := make([]monitors.Info, 0, len(list))
+	for _, m := range list {
+		infos = append(infos, m.Info())
+	}
+	return infos
+}
 
-	// Run all monitors concurrently
-	for _, monitor := range s.monitors {
-		wg.Add(1)
-		go func(m monitors.Monitor) {
-			defer wg.Done()
+// DisplayLess orders monitors for display: those in display_order first, in
+// that order, then the rest alphabetically ignoring case.
+func (e *Engine) DisplayLess(a, b string) bool {
+	rankA, orderedA := e.displayRank[a]
+	rankB, orderedB := e.displayRank[b]
+	switch {
+	case orderedA && orderedB:
+		return rankA < rankB
+	case orderedA != orderedB:
+		return orderedA
+	default:
+		return strings.ToLower(a) < strings.ToLower(b)
+	}
+}
 
-			result, err := m.Check(ctx)
-			if err != nil {
-				// Create error result
-				result = &monitors.Result{
-					Name:      m.Name(),
-					Type:      m.Type(),
-					Status:    monitors.StatusFail,
-					Message:   fmt.Sprintf("Monitor error: %v", err),
-					Timestamp: time.Now(),
-				}
-			}
+// Projects lists configured project names in display order; "" stands for
+// monitors declared outside any project. It is empty without projects.
+func (e *Engine) Projects() []string {
+	return e.config.ProjectNames()
+}
 
-			// Update state
-			s.state.Update(result)
-		}(monitor)
+// ProjectOf returns the project a monitor belongs to, or "" if none.
+func (e *Engine) ProjectOf(name string) string {
+	for _, svc := range e.config.Services {
+		if svc.Name == name {
+			return svc.Project
+		}
 	}
+	for _, check := range e.config.Checks {
+		if check.Name == name {
+			return check.Project
+		}
+	}
+	return ""
+}
 
-	wg.Wait()
+// ResultsByProject groups results by the project their monitor belongs to.
+func (e *Engine) ResultsByProject(results map[string]*monitors.Result) map[string]map[string]*monitors.Result {
+	grouped := make(map[string]map[string]*monitors.Result)
+	for name, result := range results {
+		project := e.ProjectOf(name)
+		if grouped[project] == nil {
+			grouped[project] = make(map[string]*monitors.Result)
+		}
+		grouped[project][name] = result
+	}
+	return grouped
 }
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/config"
+	"github.com/orchard9/watch-now/internal/monitors"
+)
+
+// A webhook that never answers must not slow down recording results.
+func TestUpdateWithHungWebhook(t *testing.T) {
+	received := make(chan struct{}, 1)
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case received <- struct{}{}:
+		default:
+		}
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	cfg := &config.Config{Notifications: config.NotificationsConfig{
+		Channels: []config.ChannelConfig{{Name: "hook", Type: "webhook", URL: srv.URL}},
+		Timeout:  time.Minute,
+	}}
+	engine := NewEngine(cfg)
+	if err := engine.setupNotifications(); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			status := monitors.StatusFail
+			if i%2 == 1 {
+				status = monitors.StatusOK
+			}
+			engine.State().Update(&monitors.Result{Name: "api", Status: status})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Update blocked on a hung webhook")
+	}
+
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was never called")
+	}
+}
+
+// TestDiscoveryHelperProcess is not a real test: TestRunOnceDiscovers runs
+// the test binary through it as a discovery command that prints its
+// first argument.
+func TestDiscoveryHelperProcess(t *testing.T) {
+	if os.Getenv("WATCH_NOW_DISCOVERY_HELPER") != "1" {
+		return
+	}
+	fmt.Print(os.Args[len(os.Args)-1])
+	os.Exit(0)
+}
+
+// RunOnce checks discovered services too, not just the ones in the config
+func TestRunOnceDiscovers(t *testing.T) {
+	t.Setenv("WATCH_NOW_DISCOVERY_HELPER", "1")
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	output := fmt.Sprintf(`[{"name": "found", "type": "tcp", "url": %q}]`, ln.Addr().String())
+	cfg := &config.Config{
+		Interval: time.Minute,
+		Discovery: &config.DiscoveryConfig{
+			Command: os.Args[0],
+			Args:    []string{"-test.run=^TestDiscoveryHelperProcess$", "--", output},
+			Timeout: 30 * time.Second,
+		},
+	}
+	engine := NewEngine(cfg)
+	if err := engine.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	engine.RunOnce(context.Background())
+
+	result := engine.State().Get("found")
+	if result == nil || result.Status != monitors.StatusOK {
+		t.Fatalf("discovered service result = %+v, want OK", result)
+	}
+}
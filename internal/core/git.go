@@ -0,0 +1,66 @@
+package core
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// gitTimeout bounds each git invocation so a wedged repository can't hold
+// up startup or a --watch rerun
+const gitTimeout = 5 * time.Second
+
+// GitContext is the commit the checks ran against
+type GitContext struct {
+	Commit string `json:"commit"`
+	Branch string `json:"branch,omitempty"`
+}
+
+// readGitContext returns HEAD's SHA and branch in dir, or nil outside a
+// git repository or when git is not installed. Branch is empty on a
+// detached HEAD.
+func readGitContext(dir string) *GitContext {
+	commit := gitOutput(dir, "rev-parse", "HEAD")
+	if commit == "" {
+		return nil
+	}
+	branch := gitOutput(dir, "rev-parse", "--abbrev-ref", "HEAD")
+	if branch == "HEAD" {
+		branch = ""
+	}
+	return &GitContext{Commit: commit, Branch: branch}
+}
+
+func gitOutput(dir string, args ...string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), gitTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// refreshGitContext rereads the git context when capture is enabled
+func (e *Engine) refreshGitContext(dir string) {
+	if !e.config.GitContext {
+		return
+	}
+	git := readGitContext(dir)
+
+	e.gitMu.Lock()
+	defer e.gitMu.Unlock()
+	e.git = git
+}
+
+// GitContext returns the commit checks last ran against, or nil when
+// capture is off or the directory is not a git repository.
+func (e *Engine) GitContext() *GitContext {
+	e.gitMu.RLock()
+	defer e.gitMu.RUnlock()
+	return e.git
+}
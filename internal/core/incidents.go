@@ -0,0 +1,44 @@
+package core
+
+import "github.com/orchard9/watch-now/internal/monitors"
+
+// maxIncidents bounds the status changes kept for feeds
+const maxIncidents = 100
+
+// logIncident numbers and remembers a status change, dropping the oldest
+// once the log is full. A monitor starting out healthy is not an incident.
+// Callers hold s.mu.
+func (s *StateStore) logIncident(t *Transition) {
+	if t.Old == "" && t.New == monitors.StatusOK {
+		return
+	}
+	s.incidentSeq++
+	t.Seq = s.incidentSeq
+	s.incidents = append(s.incidents, *t)
+	if len(s.incidents) > maxIncidents {
+		s.incidents = s.incidents[len(s.incidents)-maxIncidents:]
+	}
+}
+
+// Incidents returns the most recent status changes, oldest first
+func (s *StateStore) Incidents() []Transition {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Transition(nil), s.incidents...)
+}
+
+// IncidentsSince returns the logged status changes numbered after seq,
+// oldest first, and the number of the latest one. Changes already dropped
+// from the log can't be returned.
+func (s *StateStore) IncidentsSince(seq uint64) ([]Transition, uint64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var since []Transition
+	for _, t := range s.incidents {
+		if t.Seq > seq {
+			since = append(since, t)
+		}
+	}
+	return since, s.incidentSeq
+}
@@ -0,0 +1,366 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/cron"
+	"github.com/orchard9/watch-now/internal/monitors"
+)
+
+type Scheduler struct {
+	interval time.Duration
+	state    *StateStore
+	paused   atomic.Bool
+
+	// monitors can change at runtime through discovery
+	mu       sync.RWMutex
+	monitors []monitors.Monitor
+
+	// watchPatterns limits which checks run on a file-change trigger
+	watchPatterns map[string][]string
+	trigger       chan []string
+
+	// groups maps a check to its serialize group; groupLocks holds one
+	// single-slot semaphore per group, created on first use
+	groups     map[string]string
+	locksMu    sync.Mutex
+	groupLocks map[string]chan struct{}
+
+	// checkSlots and serviceSlots bound how many quality checks and
+	// service monitors run at once; nil is unlimited
+	checkSlots   chan struct{}
+	serviceSlots chan struct{}
+
+	// schedules holds the cron schedule of checks that run at set times
+	// instead of every interval
+	schedules map[string]*cron.Schedule
+
+	// cache reuses a check's last result while its cache_key_files are
+	// unchanged
+	cache *resultCache
+
+	// onCheck, when set, observes every check with its start and end time
+	onCheck func(result *monitors.Result, start, end time.Time)
+
+	// onCycle, when set, runs after each batch of checks has finished
+	onCycle func()
+}
+
+func NewScheduler(interval time.Duration, monitors []monitors.Monitor, state *StateStore) *Scheduler {
+	return &Scheduler{
+		interval: interval,
+		monitors: monitors,
+		state:    state,
+		trigger:  make(chan []string, 8),
+		cache:    newResultCache(),
+	}
+}
+
+// SetConcurrency limits how many quality checks and how many service
+// monitors run at once; 0 leaves that pool unlimited
+func (s *Scheduler) SetConcurrency(checks, services int) {
+	if checks > 0 {
+		s.checkSlots = make(chan struct{}, checks)
+	}
+	if services > 0 {
+		s.serviceSlots = make(chan struct{}, services)
+	}
+}
+
+// Monitors returns a snapshot of the monitors currently scheduled
+func (s *Scheduler) Monitors() []monitors.Monitor {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]monitors.Monitor(nil), s.monitors...)
+}
+
+// AddMonitor schedules a monitor, replacing any with the same name
+func (s *Scheduler) AddMonitor(m monitors.Monitor) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, existing := range s.monitors {
+		if existing.Name() == m.Name() {
+			s.monitors[i] = m
+			return
+		}
+	}
+	s.monitors = append(s.monitors, m)
+}
+
+// RemoveMonitor stops scheduling the named monitor
+func (s *Scheduler) RemoveMonitor(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, existing := range s.monitors {
+		if existing.Name() == name {
+			s.monitors = append(s.monitors[:i], s.monitors[i+1:]...)
+			return
+		}
+	}
+}
+
+// scheduled reports whether m is still in the set; callers hold s.mu
+func (s *Scheduler) scheduled(m monitors.Monitor) bool {
+	for _, existing := range s.monitors {
+		if existing == m {
+			return true
+		}
+	}
+	return false
+}
+
+// Trigger requests a run for the checks affected by the changed paths.
+// It never blocks; a trigger is dropped if too many are already queued.
+func (s *Scheduler) Trigger(changed []string) {
+	select {
+	case s.trigger <- changed:
+	default:
+	}
+}
+
+func (s *Scheduler) Start(ctx context.Context) error {
+	// Run initial check. Scheduled checks are left out: they are too heavy
+	// for every interval, so they wait for their first scheduled time.
+	s.runChecks(ctx)
+
+	for name, schedule := range s.schedules {
+		go s.runSchedule(ctx, name, schedule)
+	}
+
+	// Set up ticker for periodic checks
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if s.paused.Load() {
+				continue
+			}
+			s.runChecks(ctx)
+		case changed := <-s.trigger:
+			if s.paused.Load() {
+				continue
+			}
+			s.runMonitors(ctx, s.affectedBy(changed))
+		}
+	}
+}
+
+// RunOnce checks every monitor a single time, scheduled checks included,
+// and returns when all of them have finished
+func (s *Scheduler) RunOnce(ctx context.Context) {
+	s.runMonitors(ctx, s.Monitors())
+}
+
+// affectedBy returns the checks to rerun for a set of changed files. Checks
+// without watch_patterns rerun on any change; services and scheduled checks
+// are never file-driven.
+func (s *Scheduler) affectedBy(changed []string) []monitors.Monitor {
+	var affected []monitors.Monitor
+	for _, m := range s.Monitors() {
+		if m.Type() != monitors.TypeQuality || s.schedules[m.Name()] != nil {
+			continue
+		}
+		patterns := s.watchPatterns[m.Name()]
+		if len(patterns) == 0 || anyPathMatches(patterns, changed) {
+			affected = append(affected, m)
+		}
+	}
+	return affected
+}
+
+// runChecks runs every monitor that follows the interval
+func (s *Scheduler) runChecks(ctx context.Context) {
+	var due []monitors.Monitor
+	for _, m := range s.Monitors() {
+		if s.schedules[m.Name()] == nil {
+			due = append(due, m)
+		}
+	}
+	s.runMonitors(ctx, due)
+}
+
+// runSchedule runs the named check at each time its cron schedule matches.
+// Between runs the check keeps its last result.
+func (s *Scheduler) runSchedule(ctx context.Context, name string, schedule *cron.Schedule) {
+	for {
+		next := schedule.Next(time.Now())
+		if next.IsZero() {
+			log.Printf("Schedule for %s never matches; it will not run again", name)
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if s.paused.Load() {
+			continue
+		}
+
+		for _, m := range s.Monitors() {
+			if m.Name() == name {
+				s.runMonitors(ctx, []monitors.Monitor{m})
+				break
+			}
+		}
+	}
+}
+
+func (s *Scheduler) runMonitors(ctx context.Context, list []monitors.Monitor) {
+	var wg sync.WaitGroup
+
+	// A dependency in the same run finishes first, so its dependents see
+	// its fresh result
+	done := make(map[string]chan struct{}, len(list))
+	for _, m := range list {
+		done[m.Name()] = make(chan struct{})
+	}
+
+	// Run all monitors concurrently
+	for _, monitor := range list {
+		wg.Add(1)
+		go func(m monitors.Monitor) {
+			defer wg.Done()
+			defer close(done[m.Name()])
+
+			if wait, ok := done[s.state.DependencyOf(m.Name())]; ok && !s.state.inDependencyCycle(m.Name()) {
+				select {
+				case <-wait:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			release, ok := s.acquireGroup(ctx, m.Name())
+			if !ok {
+				return
+			}
+			defer release()
+			releaseSlot, ok := s.acquireSlot(ctx, m.Type())
+			if !ok {
+				return
+			}
+			defer releaseSlot()
+
+			start := time.Now()
+			result := s.check(ctx, m)
+			end := time.Now()
+
+			// Update state, unless discovery dropped the monitor mid-check
+			s.mu.RLock()
+			defer s.mu.RUnlock()
+			if !s.scheduled(m) {
+				return
+			}
+			s.state.Update(result)
+
+			// Observed after Update so escalations and SLOs are reflected
+			if s.onCheck != nil {
+				s.onCheck(result, start, end)
+			}
+		}(monitor)
+	}
+
+	wg.Wait()
+	if s.onCycle != nil && len(list) > 0 {
+		s.onCycle()
+	}
+}
+
+// check runs a monitor, or reuses its cached result when its cache files
+// are unchanged since the last run. A monitor whose dependency is failing
+// is skipped.
+func (s *Scheduler) check(ctx context.Context, m monitors.Monitor) *monitors.Result {
+	if dependency, failed := s.state.FailedDependency(m.Name()); failed {
+		return &monitors.Result{
+			Name:      m.Name(),
+			Type:      m.Type(),
+			Status:    monitors.StatusInfo,
+			Message:   fmt.Sprintf("skipped: dependency %s down", dependency),
+			Metadata:  map[string]interface{}{"skipped": true, "depends_on": dependency},
+			Timestamp: time.Now(),
+		}
+	}
+
+	key := s.cache.key(m.Name())
+	if key != "" {
+		if result := s.cache.lookup(m.Name(), key); result != nil {
+			return result
+		}
+	}
+
+	result, err := m.Check(ctx)
+	if err != nil {
+		// Create error result
+		return &monitors.Result{
+			Name:        m.Name(),
+			Type:        m.Type(),
+			Status:      monitors.StatusFail,
+			Message:     fmt.Sprintf("Monitor error: %v", err),
+			FailureKind: monitors.FailureError,
+			Timestamp:   time.Now(),
+		}
+	}
+	if key != "" {
+		s.cache.store(m.Name(), key, result)
+	}
+	return result
+}
+
+// acquireSlot waits for room in the pool of the monitor's kind. It reports
+// false if ctx ends first.
+func (s *Scheduler) acquireSlot(ctx context.Context, kind monitors.MonitorType) (release func(), ok bool) {
+	slots := s.serviceSlots
+	if kind == monitors.TypeQuality {
+		slots = s.checkSlots
+	}
+	if slots == nil {
+		return func() {}, true
+	}
+
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, true
+	case <-ctx.Done():
+		return nil, false
+	}
+}
+
+// acquireGroup waits until no other member of the monitor's serialize group
+// is running. It reports false if ctx ends first.
+func (s *Scheduler) acquireGroup(ctx context.Context, name string) (release func(), ok bool) {
+	group, grouped := s.groups[name]
+	if !grouped {
+		return func() {}, true
+	}
+
+	s.locksMu.Lock()
+	if s.groupLocks == nil {
+		s.groupLocks = make(map[string]chan struct{})
+	}
+	lock, exists := s.groupLocks[group]
+	if !exists {
+		lock = make(chan struct{}, 1)
+		s.groupLocks[group] = lock
+	}
+	s.locksMu.Unlock()
+
+	select {
+	case lock <- struct{}{}:
+		return func() { <-lock }, true
+	case <-ctx.Done():
+		return nil, false
+	}
+}
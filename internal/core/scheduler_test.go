@@ -0,0 +1,36 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/monitors"
+)
+
+// brokenMonitor fails to produce a result at all
+type brokenMonitor struct{}
+
+func (brokenMonitor) Name() string               { return "broken" }
+func (brokenMonitor) Type() monitors.MonitorType { return monitors.TypeExec }
+func (brokenMonitor) Info() monitors.Info {
+	return monitors.Info{Name: "broken", Type: monitors.TypeExec}
+}
+func (brokenMonitor) Check(context.Context) (*monitors.Result, error) {
+	return nil, errors.New("no such runner")
+}
+
+// A monitor that errors instead of reporting fails with failure kind error
+func TestMonitorErrorResult(t *testing.T) {
+	store := NewStateStore()
+	s := NewScheduler(time.Minute, []monitors.Monitor{brokenMonitor{}}, store)
+
+	result := s.check(context.Background(), brokenMonitor{})
+	if result.Status != monitors.StatusFail || result.FailureKind != monitors.FailureError {
+		t.Errorf("result = %s/%q, want %s/%q", result.Status, result.FailureKind, monitors.StatusFail, monitors.FailureError)
+	}
+	if result.Message != "Monitor error: no such runner" {
+		t.Errorf("message = %q", result.Message)
+	}
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/config"
+	"github.com/orchard9/watch-now/internal/monitors"
+)
+
+// sloMinSamples keeps a single early slow response from tripping the SLO
+const sloMinSamples = 5
+
+// SetLatencySLO evaluates a monitor's p95 duration over slo.Window on every
+// result. A nil slo removes the rule.
+func (s *StateStore) SetLatencySLO(name string, slo *config.SLOConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if slo == nil {
+		delete(s.slos, name)
+		return
+	}
+	s.slos[name] = *slo
+}
+
+// applySLO records the windowed p95 on the result and raises its status
+// when the p95 exceeds the configured budget. It never lowers a status.
+func (s *StateStore) applySLO(result *monitors.Result) {
+	slo, ok := s.slos[result.Name]
+	if !ok {
+		return
+	}
+
+	cutoff := time.Now().Add(-slo.Window)
+	durations := []time.Duration{result.Duration}
+	for _, entry := range s.history[result.Name] {
+		if entry.Timestamp.After(cutoff) {
+			durations = append(durations, entry.Result.Duration)
+		}
+	}
+	if len(durations) < sloMinSamples {
+		return
+	}
+
+	p95 := percentile(durations, 0.95)
+	if result.Metadata == nil {
+		result.Metadata = make(map[string]interface{})
+	}
+	result.Metadata["p95"] = p95.String()
+	result.Metadata["p95_window"] = slo.Window.String()
+	result.Metadata["p95_samples"] = len(durations)
+
+	switch {
+	case slo.P95Fail > 0 && p95 > slo.P95Fail:
+		raiseStatus(result, monitors.StatusFail, fmt.Sprintf("p95 %v over %v exceeds %v", p95, slo.Window, slo.P95Fail))
+	case slo.P95Warn > 0 && p95 > slo.P95Warn:
+		raiseStatus(result, monitors.StatusWarn, fmt.Sprintf("p95 %v over %v exceeds %v", p95, slo.Window, slo.P95Warn))
+	}
+}
+
+// raiseStatus moves result to status if that is worse, explaining why. A
+// failure kind the check already gave is kept.
+func raiseStatus(result *monitors.Result, status monitors.Status, reason string) {
+	if result.Status == monitors.StatusFail || (result.Status == monitors.StatusWarn && status == monitors.StatusWarn) {
+		return
+	}
+	result.Status = status
+	result.Message = fmt.Sprintf("%s (%s)", result.Message, reason)
+	if result.FailureKind == "" {
+		result.FailureKind = monitors.FailureSLO
+	}
+}
+
+// percentile returns the nearest-rank percentile of the durations
+func percentile(durations []time.Duration, p float64) time.Duration {
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank].Round(time.Millisecond)
+}
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/monitors"
+)
+
+// Snapshot is the recorded state of every monitor: its latest result and
+// history. It round-trips through JSON for /api/export and /api/import.
+type Snapshot struct {
+	ExportedAt time.Time                   `json:"exported_at"`
+	Results    map[string]*monitors.Result `json:"results"`
+	History    map[string][]HistoryEntry   `json:"history"`
+}
+
+// Snapshot copies the current results and history
+func (s *StateStore) Snapshot() Snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snapshot := Snapshot{
+		ExportedAt: time.Now(),
+		Results:    make(map[string]*monitors.Result, len(s.results)),
+		History:    make(map[string][]HistoryEntry, len(s.history)),
+	}
+	for name, result := range s.results {
+		snapshot.Results[name] = result
+	}
+	for name, history := range s.history {
+		snapshot.History[name] = append([]HistoryEntry(nil), history...)
+	}
+	return snapshot
+}
+
+// Restore replaces all results and history with a snapshot's. Transition
+// handlers are not run; subscribers see a single change.
+func (s *StateStore) Restore(snapshot Snapshot) error {
+	results := make(map[string]*monitors.Result, len(snapshot.Results))
+	for name, result := range snapshot.Results {
+		if result == nil {
+			return fmt.Errorf("result %q is empty", name)
+		}
+		result.Name = name
+		results[name] = result
+	}
+	history := make(map[string][]HistoryEntry, len(snapshot.History))
+	for name, entries := range snapshot.History {
+		for i, entry := range entries {
+			if entry.Result == nil {
+				return fmt.Errorf("history %q entry %d has no result", name, i)
+			}
+		}
+		history[name] = entries
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results = results
+	s.history = history
+	s.flapping = make(map[string]bool)
+	s.signalChanges()
+	return nil
+}
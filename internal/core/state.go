@@ -1,22 +1,59 @@
 package core
 
 import (
+	"fmt"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/orchard9/watch-now/internal/config"
 	"github.com/orchard9/watch-now/internal/monitors"
 )
 
 type StateStore struct {
-	mu       sync.RWMutex
-	results  map[string]*monitors.Result
-	history  map[string][]HistoryEntry
-	watchers []chan StateUpdate
+	mu          sync.RWMutex
+	results     map[string]*monitors.Result
+	history     map[string][]HistoryEntry
+	watchers    []chan StateUpdate
+	changes     []chan struct{}
+	transitions []func(Transition)
+	onResult    []func(*monitors.Result)
+	onSettled   []func(Transition)
+
+	flapThreshold int
+	flapWindow    time.Duration
+	flapping      map[string]bool
+
+	warnEscalation map[string]time.Duration
+	slos           map[string]config.SLOConfig
+	labels         map[string]map[string]string
+
+	// nonCritical monitors are left out of the overall status
+	nonCritical map[string]bool
+
+	// failPercent is the share of failing monitors above which the
+	// overall status is FAIL rather than WARN
+	failPercent float64
+
+	// dependsOn maps a monitor to the one it needs up before it is checked
+	dependsOn map[string]string
+
+	// acked monitors are failing with notifications silenced until they
+	// recover
+	acked map[string]bool
+
+	// incidents holds the most recent status changes, oldest first;
+	// incidentSeq numbers the last one logged
+	incidents   []Transition
+	incidentSeq uint64
+
+	// deployUntil ends the deploy window set with SetDeployMode
+	deployUntil time.Time
 }
 
 type HistoryEntry struct {
-	Result    *monitors.Result
-	Timestamp time.Time
+	Result    *monitors.Result `json:"result"`
+	Timestamp time.Time        `json:"timestamp"`
 }
 
 type StateUpdate struct {
@@ -24,17 +61,214 @@ type StateUpdate struct {
 	Result *monitors.Result
 }
 
+// Transition describes a monitor changing status. Old is empty for the
+// first result recorded for a monitor. Flapping is set while the monitor
+// changes status too often; FlapStarted marks the change that tipped it and
+// FlapEnded the result with which it dropped back under the threshold.
+// Downtime is how long the monitor was WARN or FAIL when it returns to OK.
+type Transition struct {
+	Name        string
+	Old         monitors.Status
+	New         monitors.Status
+	Result      *monitors.Result
+	Flapping    bool
+	FlapStarted bool
+	FlapEnded   bool
+	Changes     int
+	Downtime    time.Duration
+
+	// Acked is set while the monitor's failure is acknowledged
+	Acked bool
+
+	// Seq numbers logged incidents from 1, in order; it is 0 for a
+	// monitor starting out healthy, which is not logged
+	Seq uint64
+}
+
 func NewStateStore() *StateStore {
 	return &StateStore{
-		results: make(map[string]*monitors.Result),
-		history: make(map[string][]HistoryEntry),
+		results:  make(map[string]*monitors.Result),
+		history:  make(map[string][]HistoryEntry),
+		flapping: make(map[string]bool),
+
+		warnEscalation: make(map[string]time.Duration),
+		slos:           make(map[string]config.SLOConfig),
+		labels:         make(map[string]map[string]string),
+		nonCritical:    make(map[string]bool),
+		dependsOn:      make(map[string]string),
+	}
+}
+
+// SetFlapDetection flags monitors whose status changes more than threshold
+// times within window. A zero threshold disables detection.
+func (s *StateStore) SetFlapDetection(threshold int, window time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flapThreshold = threshold
+	s.flapWindow = window
+}
+
+// SetWarnEscalation reports a monitor as FAIL once it has been WARN for
+// longer than after. Zero disables escalation for that monitor.
+func (s *StateStore) SetWarnEscalation(name string, after time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.warnEscalation[name] = after
+}
+
+// SetLabels attaches labels to every result recorded for a monitor
+func (s *StateStore) SetLabels(name string, labels map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(labels) == 0 {
+		delete(s.labels, name)
+		return
+	}
+	s.labels[name] = labels
+}
+
+// SetCritical controls whether a monitor counts toward the overall status.
+// Monitors are critical unless marked otherwise.
+func (s *StateStore) SetCritical(name string, critical bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if critical {
+		delete(s.nonCritical, name)
+		return
+	}
+	s.nonCritical[name] = true
+}
+
+// SetDependsOn makes a monitor wait on another; an empty dependency clears it
+func (s *StateStore) SetDependsOn(name, dependency string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if dependency == "" {
+		delete(s.dependsOn, name)
+		return
+	}
+	s.dependsOn[name] = dependency
+}
+
+// DependencyOf returns the monitor name depends on, or ""
+func (s *StateStore) DependencyOf(name string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.dependsOn[name]
+}
+
+// inDependencyCycle reports whether following name's dependencies leads
+// back to it. Configured services are checked at load, but discovered
+// ones are not.
+func (s *StateStore) inDependencyCycle(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for next, steps := s.dependsOn[name], 0; next != ""; next, steps = s.dependsOn[next], steps+1 {
+		if next == name || steps > len(s.dependsOn) {
+			return true
+		}
+	}
+	return false
+}
+
+// FailedDependency returns the monitor's dependency when its latest result
+// is FAIL, meaning the monitor itself need not be checked.
+func (s *StateStore) FailedDependency(name string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	dependency, ok := s.dependsOn[name]
+	if !ok {
+		return "", false
+	}
+	result := s.results[dependency]
+	return dependency, result != nil && result.Status == monitors.StatusFail
+}
+
+// SetFailPercent makes the overall status FAIL only when more than percent
+// of the critical monitors fail; 0 restores FAIL on any failure.
+func (s *StateStore) SetFailPercent(percent float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failPercent = percent
+}
+
+// Overall is the worst status among the critical monitors in results: FAIL,
+// then WARN, else OK. Failures below the fail percentage count as WARN. It
+// is INFO when results is empty.
+func (s *StateStore) Overall(results map[string]*monitors.Result) monitors.Status {
+	if len(results) == 0 {
+		return monitors.StatusInfo
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	critical, failing := 0, 0
+	hasWarn := false
+	for name, result := range results {
+		if s.nonCritical[name] {
+			continue
+		}
+		critical++
+		if result.Status == monitors.StatusFail {
+			failing++
+		}
+		if result.Status == monitors.StatusWarn {
+			hasWarn = true
+		}
+	}
+
+	if failing > 0 && float64(failing)*100 > s.failPercent*float64(critical) {
+		return monitors.StatusFail
+	}
+	if hasWarn || failing > 0 {
+		return monitors.StatusWarn
 	}
+	return monitors.StatusOK
 }
 
 func (s *StateStore) Update(result *monitors.Result) {
+	transition, changed := s.record(result)
+
+	// Run handlers outside the lock so they may read state
+	s.mu.RLock()
+	resultHandlers, handlers, settled := s.onResult, s.transitions, s.onSettled
+	s.mu.RUnlock()
+	for _, handler := range resultHandlers {
+		handler(result)
+	}
+	if !changed {
+		if transition.FlapEnded {
+			for _, handler := range settled {
+				handler(transition)
+			}
+		}
+		return
+	}
+
+	for _, handler := range handlers {
+		handler(transition)
+	}
+}
+
+// record stores the result and reports whether its status changed.
+func (s *StateStore) record(result *monitors.Result) (Transition, bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if labels, ok := s.labels[result.Name]; ok {
+		result.Labels = labels
+	}
+	s.applyDeployMode(result)
+	s.applySLO(result)
+	s.escalateWarn(result)
+	s.applyAck(result)
+
+	transition := Transition{Name: result.Name, New: result.Status, Result: result, Acked: result.Acked}
+	if prev, ok := s.results[result.Name]; ok {
+		transition.Old = prev.Status
+	}
+
 	// Store current result
 	s.results[result.Name] = result
 
@@ -49,6 +283,14 @@ func (s *StateStore) Update(result *monitors.Result) {
 	}
 	s.history[result.Name] = history
 
+	s.detectFlapping(&transition, history)
+	if transition.New == monitors.StatusOK && transition.Old != "" {
+		transition.Downtime = downtime(history)
+	}
+	if transition.Old != transition.New {
+		s.logIncident(&transition)
+	}
+
 	// Notify watchers
 	update := StateUpdate{
 		Name:   result.Name,
@@ -61,6 +303,133 @@ func (s *StateStore) Update(result *monitors.Result) {
 			// Don't block if watcher is not ready
 		}
 	}
+	s.signalChanges()
+
+	return transition, transition.Old != transition.New
+}
+
+// downtime measures the unhealthy streak that the latest entry ends: from
+// the first consecutive WARN or FAIL before it up to the latest entry.
+func downtime(history []HistoryEntry) time.Duration {
+	last := len(history) - 1
+	start := last
+	for i := last - 1; i >= 0; i-- {
+		status := history[i].Result.Status
+		if status != monitors.StatusWarn && status != monitors.StatusFail {
+			break
+		}
+		start = i
+	}
+	return history[last].Timestamp.Sub(history[start].Timestamp)
+}
+
+// detectFlapping counts status changes inside the flap window and records
+// the outcome on the transition and the result's metadata.
+func (s *StateStore) detectFlapping(t *Transition, history []HistoryEntry) {
+	if s.flapThreshold <= 0 {
+		return
+	}
+
+	cutoff := time.Now().Add(-s.flapWindow)
+	for i := 1; i < len(history); i++ {
+		if history[i].Timestamp.After(cutoff) && history[i].Result.Status != history[i-1].Result.Status {
+			t.Changes++
+		}
+	}
+
+	wasFlapping := s.flapping[t.Name]
+	t.Flapping = t.Changes > s.flapThreshold
+	t.FlapStarted = t.Flapping && !wasFlapping
+	t.FlapEnded = wasFlapping && !t.Flapping
+	s.flapping[t.Name] = t.Flapping
+
+	if t.Flapping {
+		if t.Result.Metadata == nil {
+			t.Result.Metadata = make(map[string]interface{})
+		}
+		t.Result.Metadata["flapping"] = true
+		t.Result.Metadata["status_changes"] = t.Changes
+	}
+}
+
+// escalateWarn turns a WARN that has persisted beyond the monitor's
+// escalation limit into a FAIL. The streak start comes from history, where
+// earlier escalated results still count as warnings.
+func (s *StateStore) escalateWarn(result *monitors.Result) {
+	limit := s.warnEscalation[result.Name]
+	if limit <= 0 || result.Status != monitors.StatusWarn {
+		return
+	}
+
+	since := time.Now()
+	history := s.history[result.Name]
+	for i := len(history) - 1; i >= 0; i-- {
+		prev := history[i].Result
+		if prev.Status != monitors.StatusWarn && prev.Metadata["escalated_from"] != string(monitors.StatusWarn) {
+			break
+		}
+		since = history[i].Timestamp
+	}
+
+	warnFor := time.Since(since)
+	if warnFor < limit {
+		return
+	}
+
+	if result.Metadata == nil {
+		result.Metadata = make(map[string]interface{})
+	}
+	result.Metadata["escalated_from"] = string(monitors.StatusWarn)
+	result.Metadata["warn_since"] = since.Format(time.RFC3339)
+	result.Status = monitors.StatusFail
+	result.Message = fmt.Sprintf("%s (escalated: warning for %v)", result.Message, warnFor.Round(time.Second))
+	// Keep the warning's own kind, such as assertion, when it had one
+	if result.FailureKind == "" {
+		result.FailureKind = monitors.FailureEscalation
+	}
+}
+
+// Remove forgets a monitor's result, history and rules, for monitors that
+// are no longer configured.
+func (s *StateStore) Remove(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.results, name)
+	delete(s.history, name)
+	delete(s.flapping, name)
+	delete(s.warnEscalation, name)
+	delete(s.slos, name)
+	delete(s.labels, name)
+	delete(s.nonCritical, name)
+	delete(s.dependsOn, name)
+	delete(s.acked, name)
+	s.signalChanges()
+}
+
+// OnResult registers a handler invoked with every stored result. It runs on
+// the checking goroutine, so it must not block.
+func (s *StateStore) OnResult(handler func(*monitors.Result)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onResult = append(s.onResult, handler)
+}
+
+// OnTransition registers a handler invoked whenever a monitor's status changes.
+func (s *StateStore) OnTransition(handler func(Transition)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.transitions = append(s.transitions, handler)
+}
+
+// OnSettled registers a handler invoked when a flapping monitor stops
+// flapping without changing status, with the status it settled on. A
+// flap that ends with a status change goes to OnTransition handlers
+// instead, with FlapEnded set.
+func (s *StateStore) OnSettled(handler func(Transition)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onSettled = append(s.onSettled, handler)
 }
 
 func (s *StateStore) Get(name string) *monitors.Result {
@@ -69,6 +438,57 @@ func (s *StateStore) Get(name string) *monitors.Result {
 	return s.results[name]
 }
 
+// History returns recorded entries newer than since, oldest first. An empty
+// name merges the history of every monitor.
+func (s *StateStore) History(name string, since time.Time) []HistoryEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var entries []HistoryEntry
+	for monitor, history := range s.history {
+		if name != "" && monitor != name {
+			continue
+		}
+		for _, entry := range history {
+			if entry.Timestamp.After(since) {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Timestamp.Before(entries[j].Timestamp)
+	})
+	return entries
+}
+
+// WithHistory returns copies of results carrying when each monitor last
+// passed and last failed, and how many of its latest results in a row share
+// the current status, as far back as history goes.
+func (s *StateStore) WithHistory(results map[string]*monitors.Result) map[string]*monitors.Result {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	enriched := make(map[string]*monitors.Result, len(results))
+	for name, result := range results {
+		copied := *result
+		history := s.history[name]
+		for i := len(history) - 1; i >= 0; i-- {
+			entry := history[i]
+			if entry.Result.Status == result.Status && copied.Streak == len(history)-1-i {
+				copied.Streak++
+			}
+			switch {
+			case entry.Result.Status == monitors.StatusOK && copied.LastSuccess == nil:
+				copied.LastSuccess = &entry.Timestamp
+			case entry.Result.Status == monitors.StatusFail && copied.LastFailure == nil:
+				copied.LastFailure = &entry.Timestamp
+			}
+		}
+		enriched[name] = &copied
+	}
+	return enriched
+}
+
 func (s *StateStore) GetAll() map[string]*monitors.Result {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -81,48 +501,47 @@ func (s *StateStore) GetAll() map[string]*monitors.Result {
 	return results
 }
 
-// Subscribe for state updates with map results channel
-func (s *StateStore) Subscribe(ch chan map[string]*monitors.Result) {
+// signalChanges wakes every change subscriber without ever blocking
+func (s *StateStore) signalChanges() {
+	for _, change := range s.changes {
+		select {
+		case change <- struct{}{}:
+		default:
+			// A signal is already pending; the reader will see this change too
+		}
+	}
+}
+
+// SubscribeChanges returns a channel signalled whenever any result is
+// recorded. Signals coalesce rather than drop: a slow reader finds at most
+// one pending signal and should read the latest state with GetAll on wake,
+// so it always converges to current state.
+func (s *StateStore) SubscribeChanges() <-chan struct{} {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Create a state update watcher
-	watcher := make(chan StateUpdate, 10)
-	s.watchers = append(s.watchers, watcher)
+	ch := make(chan struct{}, 1)
+	s.changes = append(s.changes, ch)
+	return ch
+}
 
-	// Convert StateUpdate to map format in background
-	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				// Channel was closed, ignore panic
-				_ = r // Use the recovered value to avoid SA9003
-			}
-		}()
-		for range watcher {
-			select {
-			case ch <- s.GetAll():
-			default:
-				// Don't block if receiver is not ready
-			}
-		}
-	}()
+// ChangeSubscribers reports how many SubscribeChanges channels are open
+func (s *StateStore) ChangeSubscribers() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.changes)
 }
 
-func (s *StateStore) Unsubscribe(ch chan map[string]*monitors.Result) {
+func (s *StateStore) UnsubscribeChanges(ch <-chan struct{}) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Find and remove associated watcher
-	// This is simplified - a better implementation would track the association
-	if len(s.watchers) > 0 {
-		// Close the last watcher added (assumes LIFO for simplicity)
-		lastWatcher := s.watchers[len(s.watchers)-1]
-		s.watchers = s.watchers[:len(s.watchers)-1]
-		close(lastWatcher)
+	for i, change := range s.changes {
+		if change == ch {
+			s.changes = append(s.changes[:i], s.changes[i+1:]...)
+			break
+		}
 	}
-
-	// Close the subscriber channel
-	close(ch)
 }
 
 // Legacy subscription methods for backwards compatibility
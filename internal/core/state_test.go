@@ -0,0 +1,150 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/config"
+	"github.com/orchard9/watch-now/internal/monitors"
+)
+
+// A subscriber that never reads, like a stalled SSE client, must neither
+// block Update nor miss the latest state once it does read.
+func TestSubscribeChangesSlowConsumer(t *testing.T) {
+	store := NewStateStore()
+	changes := store.SubscribeChanges()
+	defer store.UnsubscribeChanges(changes)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			store.Update(&monitors.Result{Name: "api", Status: monitors.StatusOK, Message: fmt.Sprint(i)})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Update blocked on a subscriber that never reads")
+	}
+
+	select {
+	case <-changes:
+	default:
+		t.Fatal("no change signal pending for the slow subscriber")
+	}
+	if got := store.Get("api").Message; got != "999" {
+		t.Errorf("latest message = %q, want %q", got, "999")
+	}
+
+	select {
+	case <-changes:
+		t.Error("signals did not coalesce into one")
+	default:
+	}
+}
+
+// Once a flapping monitor's changes age out of the window, the status it
+// settled on is announced even though that result is no status change.
+func TestFlapEndAnnouncesSettledStatus(t *testing.T) {
+	window := 100 * time.Millisecond
+	store := NewStateStore()
+	store.SetFlapDetection(2, window)
+
+	var flapStarted bool
+	store.OnTransition(func(tr Transition) {
+		if tr.FlapStarted {
+			flapStarted = true
+		}
+	})
+	var settled []Transition
+	store.OnSettled(func(tr Transition) { settled = append(settled, tr) })
+
+	for _, status := range []monitors.Status{monitors.StatusOK, monitors.StatusFail, monitors.StatusOK, monitors.StatusFail} {
+		store.Update(&monitors.Result{Name: "api", Status: status})
+	}
+	if !flapStarted {
+		t.Fatal("monitor never started flapping")
+	}
+	store.Update(&monitors.Result{Name: "api", Status: monitors.StatusFail})
+	if len(settled) != 0 {
+		t.Fatalf("settled while changes are still inside the window: %+v", settled)
+	}
+
+	time.Sleep(window + 20*time.Millisecond)
+	store.Update(&monitors.Result{Name: "api", Status: monitors.StatusFail})
+	if len(settled) != 1 {
+		t.Fatalf("got %d settled notifications, want 1", len(settled))
+	}
+	if tr := settled[0]; !tr.FlapEnded || tr.Flapping || tr.New != monitors.StatusFail {
+		t.Errorf("settled transition = %+v, want FlapEnded at %s", tr, monitors.StatusFail)
+	}
+
+	store.Update(&monitors.Result{Name: "api", Status: monitors.StatusFail})
+	if len(settled) != 1 {
+		t.Errorf("settled announced again: %d notifications", len(settled))
+	}
+}
+
+// Statuses that watch-now raises itself get a failure kind of their own,
+// unless the check already explained its result.
+func TestRaisedStatusFailureKinds(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    func(store *StateStore)
+		result   monitors.Result
+		want     monitors.Status
+		wantKind monitors.FailureKind
+	}{
+		{
+			name: "slo breach",
+			setup: func(store *StateStore) {
+				store.SetLatencySLO("api", &config.SLOConfig{Window: time.Minute, P95Warn: 10 * time.Millisecond})
+			},
+			result:   monitors.Result{Status: monitors.StatusOK, Duration: 50 * time.Millisecond},
+			want:     monitors.StatusWarn,
+			wantKind: monitors.FailureSLO,
+		},
+		{
+			name: "slo breach of a failing assertion",
+			setup: func(store *StateStore) {
+				store.SetLatencySLO("api", &config.SLOConfig{Window: time.Minute, P95Fail: 10 * time.Millisecond})
+			},
+			result:   monitors.Result{Status: monitors.StatusWarn, FailureKind: monitors.FailureAssertion, Duration: 50 * time.Millisecond},
+			want:     monitors.StatusFail,
+			wantKind: monitors.FailureAssertion,
+		},
+		{
+			name:     "escalated slow warning",
+			setup:    func(store *StateStore) { store.SetWarnEscalation("api", time.Nanosecond) },
+			result:   monitors.Result{Status: monitors.StatusWarn, Metadata: map[string]interface{}{"slow": true}},
+			want:     monitors.StatusFail,
+			wantKind: monitors.FailureEscalation,
+		},
+		{
+			name:     "escalated timeout",
+			setup:    func(store *StateStore) { store.SetWarnEscalation("api", time.Nanosecond) },
+			result:   monitors.Result{Status: monitors.StatusWarn, FailureKind: monitors.FailureTimeout},
+			want:     monitors.StatusFail,
+			wantKind: monitors.FailureTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewStateStore()
+			tt.setup(store)
+			for i := 0; i < sloMinSamples; i++ {
+				result := tt.result
+				result.Name = "api"
+				store.Update(&result)
+			}
+
+			got := store.Get("api")
+			if got.Status != tt.want || got.FailureKind != tt.wantKind {
+				t.Errorf("result = %s/%q (%s), want %s/%q", got.Status, got.FailureKind, got.Message, tt.want, tt.wantKind)
+			}
+		})
+	}
+}
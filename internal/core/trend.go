@@ -0,0 +1,105 @@
+package core
+
+import (
+	"time"
+
+	"github.com/orchard9/watch-now/internal/monitors"
+)
+
+// TrendPoint summarises the history entries falling into one time bucket.
+// Status is the worst status seen in the bucket.
+type TrendPoint struct {
+	Timestamp time.Time       `json:"timestamp"`
+	Samples   int             `json:"samples"`
+	AvgMs     float64         `json:"avg_ms"`
+	MaxMs     float64         `json:"max_ms"`
+	Status    monitors.Status `json:"status"`
+
+	// AvgValue and MaxValue summarise metadata.value, the number a metric
+	// monitor reports, when the bucket has one
+	AvgValue *float64 `json:"avg_value,omitempty"`
+	MaxValue *float64 `json:"max_value,omitempty"`
+}
+
+// Trend downsamples a monitor's history into at most points buckets of equal
+// width, oldest first. Empty buckets are left out. It returns false when the
+// monitor has no history.
+func (s *StateStore) Trend(name string, points int) ([]TrendPoint, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	history := s.history[name]
+	if len(history) == 0 {
+		return nil, false
+	}
+	if points < 1 {
+		points = 1
+	}
+
+	start := history[0].Timestamp
+	span := history[len(history)-1].Timestamp.Sub(start)
+	width := span/time.Duration(points) + 1
+
+	var trend []TrendPoint
+	var total time.Duration
+	var valueSum float64
+	var values int
+	bucket := -1
+	for _, entry := range history {
+		i := int(entry.Timestamp.Sub(start) / width)
+		if i != bucket {
+			if len(trend) > 0 {
+				finishPoint(&trend[len(trend)-1], total, valueSum, values)
+			}
+			bucket, total, valueSum, values = i, 0, 0, 0
+			trend = append(trend, TrendPoint{
+				Timestamp: start.Add(time.Duration(i) * width),
+			})
+		}
+
+		p := &trend[len(trend)-1]
+		p.Samples++
+		total += entry.Result.Duration
+		if ms := durationMs(entry.Result.Duration); ms > p.MaxMs {
+			p.MaxMs = ms
+		}
+		if value, ok := entry.Result.Metadata["value"].(float64); ok {
+			valueSum += value
+			values++
+			if p.MaxValue == nil || value > *p.MaxValue {
+				p.MaxValue = &value
+			}
+		}
+		if p.Samples == 1 || statusRank(entry.Result.Status) > statusRank(p.Status) {
+			p.Status = entry.Result.Status
+		}
+	}
+	finishPoint(&trend[len(trend)-1], total, valueSum, values)
+	return trend, true
+}
+
+func finishPoint(p *TrendPoint, total time.Duration, valueSum float64, values int) {
+	p.AvgMs = durationMs(total / time.Duration(p.Samples))
+	if values > 0 {
+		avg := valueSum / float64(values)
+		p.AvgValue = &avg
+	}
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// statusRank orders statuses from healthiest to worst
+func statusRank(status monitors.Status) int {
+	switch status {
+	case monitors.StatusFail:
+		return 3
+	case monitors.StatusWarn:
+		return 2
+	case monitors.StatusOK:
+		return 1
+	default:
+		return 0
+	}
+}
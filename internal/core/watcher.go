@@ -0,0 +1,109 @@
+package core
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// skippedDirs are never scanned for changes
+var skippedDirs = map[string]bool{".git": true, "node_modules": true, "vendor": true, "build": true, "dist": true, "target": true}
+
+// FileWatcher polls a directory tree for modified, added or removed files.
+// Polling keeps watch-now dependency-free and works on every platform.
+type FileWatcher struct {
+	root     string
+	interval time.Duration
+	mtimes   map[string]time.Time
+}
+
+func NewFileWatcher(root string, interval time.Duration) *FileWatcher {
+	return &FileWatcher{root: root, interval: interval}
+}
+
+// Watch calls onChange with the relative paths changed since the last poll
+// until ctx is cancelled.
+func (w *FileWatcher) Watch(ctx context.Context, onChange func([]string)) {
+	w.mtimes = w.scan()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			current := w.scan()
+			if changed := diffSnapshots(w.mtimes, current); len(changed) > 0 {
+				onChange(changed)
+			}
+			w.mtimes = current
+		}
+	}
+}
+
+func (w *FileWatcher) scan() map[string]time.Time {
+	mtimes := make(map[string]time.Time)
+	_ = filepath.WalkDir(w.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != w.root && (skippedDirs[name] || strings.HasPrefix(name, ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			rel, _ := filepath.Rel(w.root, path)
+			mtimes[filepath.ToSlash(rel)] = info.ModTime()
+		}
+		return nil
+	})
+	return mtimes
+}
+
+func diffSnapshots(before, after map[string]time.Time) []string {
+	var changed []string
+	for path, mtime := range after {
+		if prev, ok := before[path]; !ok || !prev.Equal(mtime) {
+			changed = append(changed, path)
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	return changed
+}
+
+// anyPathMatches reports whether a changed path matches any watch pattern.
+// Patterns without a slash match file names ("*.go"); patterns with one
+// match the relative path, and a trailing "/**" matches a whole directory.
+func anyPathMatches(patterns, paths []string) bool {
+	for _, path := range paths {
+		for _, pattern := range patterns {
+			if pathMatches(pattern, path) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func pathMatches(pattern, path string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "/**"); ok {
+		return path == prefix || strings.HasPrefix(path, prefix+"/")
+	}
+	target := path
+	if !strings.Contains(pattern, "/") {
+		target = filepath.Base(path)
+	}
+	matched, _ := filepath.Match(pattern, target)
+	return matched
+}
@@ -0,0 +1,97 @@
+package cron
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestNext checks the next match for ranges, steps, lists, the day fields
+// and rollover into the next month and year
+func TestNext(t *testing.T) {
+	// A Wednesday
+	from := time.Date(2026, time.January, 14, 10, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"every minute", "* * * * *", from, time.Date(2026, 1, 14, 10, 8, 0, 0, time.UTC)},
+		{"fixed time later today", "30 14 * * *", from, time.Date(2026, 1, 14, 14, 30, 0, 0, time.UTC)},
+		{"fixed time tomorrow", "0 2 * * *", from, time.Date(2026, 1, 15, 2, 0, 0, 0, time.UTC)},
+		{"exact match is skipped", "7 10 * * *", from, time.Date(2026, 1, 15, 10, 7, 0, 0, time.UTC)},
+		{"range", "0 12-14 * * *", from, time.Date(2026, 1, 14, 12, 0, 0, 0, time.UTC)},
+		{"step", "*/15 * * * *", from, time.Date(2026, 1, 14, 10, 15, 0, 0, time.UTC)},
+		{"step from offset", "5/20 * * * *", from, time.Date(2026, 1, 14, 10, 25, 0, 0, time.UTC)},
+		{"step over range", "0 9-17/4 * * *", from, time.Date(2026, 1, 14, 13, 0, 0, 0, time.UTC)},
+		{"list", "0 8,11,20 * * *", from, time.Date(2026, 1, 14, 11, 0, 0, 0, time.UTC)},
+		{"list of ranges", "0,50-52 * * * *", from, time.Date(2026, 1, 14, 10, 50, 0, 0, time.UTC)},
+		{"day of week", "0 9 * * 1", from, time.Date(2026, 1, 19, 9, 0, 0, 0, time.UTC)},
+		{"weekdays", "0 9 * * 1-5", from, time.Date(2026, 1, 15, 9, 0, 0, 0, time.UTC)},
+		{"sunday as 7", "0 0 * * 7", from, time.Date(2026, 1, 18, 0, 0, 0, 0, time.UTC)},
+		{"day of month", "0 0 20 * *", from, time.Date(2026, 1, 20, 0, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either one matching is enough
+		{"day of month or week", "0 0 20 * 5", from, time.Date(2026, 1, 16, 0, 0, 0, 0, time.UTC)},
+		// A starred day field leaves the other one in charge
+		{"stepped day of month", "0 0 */10 * 5", from, time.Date(2026, 1, 16, 0, 0, 0, 0, time.UTC)},
+		{"month rollover", "0 0 1 * *", from, time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"31st skips short months", "0 0 31 * *", time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)},
+		{"year rollover", "0 0 1 1 *", from, time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"leap day", "0 0 29 2 *", from, time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"end of day rollover", "0 0 * * *", time.Date(2026, 12, 31, 23, 59, 0, 0, time.UTC), time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"macro", "@hourly", from, time.Date(2026, 1, 14, 11, 0, 0, 0, time.UTC)},
+		{"never", "0 0 31 2 *", from, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.expr, err)
+			}
+			if got := schedule.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", tt.from, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseInvalid checks that malformed expressions are rejected with an
+// error naming the offending field
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"", "expected 5 fields"},
+		{"* * * *", "expected 5 fields"},
+		{"* * * * * *", "expected 5 fields"},
+		{"@often", "expected 5 fields"},
+		{"60 * * * *", "minute"},
+		{"* 24 * * *", "hour"},
+		{"* * 0 * *", "day of month"},
+		{"* * 32 * *", "day of month"},
+		{"* * * 13 *", "month"},
+		{"* * * * 8", "day of week"},
+		{"* * * jan *", "month"},
+		{"*/0 * * * *", "invalid step"},
+		{"*/x * * * *", "invalid step"},
+		{"5-1 * * * *", "outside"},
+		{"1- * * * *", "invalid value"},
+		{"1,,2 * * * *", "invalid value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := Parse(tt.expr)
+			if err == nil {
+				t.Fatalf("Parse(%q) succeeded, want error", tt.expr)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse(%q) = %v, want it to mention %q", tt.expr, err, tt.want)
+			}
+		})
+	}
+}
@@ -1,9 +1,11 @@
 package detector
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/orchard9/watch-now/internal/config"
@@ -13,6 +15,15 @@ type ProjectDetector struct {
 	projectPath string
 }
 
+// projectMarkers identify a directory as a buildable (sub)project
+var projectMarkers = []string{"Makefile", "package.json", "go.mod", "Cargo.toml", "pom.xml", "build.gradle", "build.gradle.kts", "pyproject.toml", "requirements.txt"}
+
+// ignoredDirs are never searched for nested projects
+var ignoredDirs = map[string]bool{"vendor": true, "node_modules": true, ".git": true, "target": true, "dist": true, "build": true}
+
+// maxSubprojectDepth bounds how far below the root nested projects are found
+const maxSubprojectDepth = 2
+
 type ProjectInfo struct {
 	Type             string
 	Services         []config.ServiceConfig
@@ -22,6 +33,7 @@ type ProjectInfo struct {
 	HasGoMod         bool
 	HasDockerCompose bool
 	DetectedPorts    []int
+	Subprojects      []string
 }
 
 func NewProjectDetector(path string) *ProjectDetector {
@@ -48,6 +60,12 @@ func (d *ProjectDetector) DetectProject() (*ProjectInfo, error) {
 	// Generate quality checks based on project type
 	info.QualityChecks = d.generateQualityChecks(info)
 
+	// Monorepos without root tooling get checks per nested project
+	if !info.HasMakefile && (info.Type == "monorepo" || len(info.QualityChecks) == 0) {
+		info.Subprojects = d.findSubprojects(".", 1)
+		info.QualityChecks = append(info.QualityChecks, d.generateSubprojectChecks(info.Subprojects)...)
+	}
+
 	// Try to detect services (if it looks like a service-oriented project)
 	if d.looksLikeServiceProject() {
 		info.Services = d.detectServices()
@@ -56,6 +74,66 @@ func (d *ProjectDetector) DetectProject() (*ProjectInfo, error) {
 	return info, nil
 }
 
+// findSubprojects walks below rel looking for directories with project
+// markers. A directory that is a project is not searched further.
+func (d *ProjectDetector) findSubprojects(rel string, depth int) []string {
+	if depth > maxSubprojectDepth {
+		return nil
+	}
+
+	entries, err := os.ReadDir(filepath.Join(d.projectPath, rel))
+	if err != nil {
+		return nil
+	}
+
+	var found []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || ignoredDirs[name] || strings.HasPrefix(name, ".") {
+			continue
+		}
+		dir := filepath.Join(rel, name)
+		if d.isProjectDir(dir) {
+			found = append(found, dir)
+			continue
+		}
+		found = append(found, d.findSubprojects(dir, depth+1)...)
+	}
+	return found
+}
+
+func (d *ProjectDetector) isProjectDir(dir string) bool {
+	for _, marker := range projectMarkers {
+		if d.fileExists(filepath.Join(dir, marker)) {
+			return true
+		}
+	}
+	return false
+}
+
+// generateSubprojectChecks detects each subproject on its own and prefixes
+// its checks with the directory so names stay unique.
+func (d *ProjectDetector) generateSubprojectChecks(dirs []string) []config.CheckConfig {
+	var checks []config.CheckConfig
+	for _, dir := range dirs {
+		sub := NewProjectDetector(filepath.Join(d.projectPath, dir))
+		subInfo := &ProjectInfo{
+			HasMakefile:    sub.fileExists("Makefile"),
+			HasPackageJSON: sub.fileExists("package.json"),
+			HasGoMod:       sub.fileExists("go.mod"),
+		}
+		subInfo.Type = sub.detectLanguage(subInfo)
+
+		prefix := strings.ReplaceAll(filepath.ToSlash(dir), "/", "-") + "-"
+		for _, check := range sub.generateQualityChecks(subInfo) {
+			check.Name = prefix + check.Name
+			check.Dir = filepath.ToSlash(dir)
+			checks = append(checks, check)
+		}
+	}
+	return checks
+}
+
 func (d *ProjectDetector) fileExists(filename string) bool {
 	_, err := os.Stat(filepath.Join(d.projectPath, filename))
 	return !os.IsNotExist(err)
@@ -87,7 +165,7 @@ func (d *ProjectDetector) detectLanguage(info *ProjectInfo) string {
 	if d.fileExists("requirements.txt") || d.fileExists("pyproject.toml") {
 		return "python"
 	}
-	if d.fileExists("pom.xml") || d.fileExists("build.gradle") {
+	if d.fileExists("pom.xml") || d.fileExists("build.gradle") || d.fileExists("build.gradle.kts") {
 		return "java"
 	}
 	if d.fileExists("Cargo.toml") {
@@ -221,6 +299,10 @@ func (d *ProjectDetector) generateQualityChecks(info *ProjectInfo) []config.Chec
 			checks = append(checks, d.generateNodeChecks()...)
 		case "python":
 			checks = append(checks, d.generatePythonChecks()...)
+		case "rust":
+			checks = append(checks, d.generateRustChecks()...)
+		case "java":
+			checks = append(checks, d.generateJavaChecks()...)
 		}
 	}
 
@@ -271,18 +353,41 @@ func (d *ProjectDetector) generateGoChecks() []config.CheckConfig {
 func (d *ProjectDetector) generateNodeChecks() []config.CheckConfig {
 	checks := []config.CheckConfig{}
 
-	// Check for common npm/yarn scripts
+	// Check for common package.json scripts
 	if d.fileExists("package.json") {
+		pm := d.detectNodePackageManager()
 		checks = append(checks,
-			config.CheckConfig{Name: "lint", Command: "npm", Args: []string{"run", "lint"}, Timeout: 60 * time.Second},
-			config.CheckConfig{Name: "test", Command: "npm", Args: []string{"test"}, Timeout: 120 * time.Second},
-			config.CheckConfig{Name: "build", Command: "npm", Args: []string{"run", "build"}, Timeout: 180 * time.Second},
+			config.CheckConfig{Name: "lint", Command: pm, Args: []string{"run", "lint"}, Timeout: 60 * time.Second},
+			// "run test" rather than "test": bun test is bun's own runner
+			config.CheckConfig{Name: "test", Command: pm, Args: []string{"run", "test"}, Timeout: 120 * time.Second},
+			config.CheckConfig{Name: "build", Command: pm, Args: []string{"run", "build"}, Timeout: 180 * time.Second},
 		)
 	}
 
 	return checks
 }
 
+// nodeLockfiles maps each package manager's lockfile to its command, in
+// the order they are looked for
+var nodeLockfiles = []struct{ file, command string }{
+	{"pnpm-lock.yaml", "pnpm"},
+	{"yarn.lock", "yarn"},
+	{"bun.lockb", "bun"},
+	{"bun.lock", "bun"},
+	{"package-lock.json", "npm"},
+}
+
+// detectNodePackageManager picks the package manager from the lockfile,
+// falling back to npm when there is none
+func (d *ProjectDetector) detectNodePackageManager() string {
+	for _, lock := range nodeLockfiles {
+		if d.fileExists(lock.file) {
+			return lock.command
+		}
+	}
+	return "npm"
+}
+
 func (d *ProjectDetector) generatePythonChecks() []config.CheckConfig {
 	checks := []config.CheckConfig{}
 
@@ -295,6 +400,68 @@ func (d *ProjectDetector) generatePythonChecks() []config.CheckConfig {
 	return checks
 }
 
+func (d *ProjectDetector) generateRustChecks() []config.CheckConfig {
+	return []config.CheckConfig{
+		{Name: "format", Command: "cargo", Args: []string{"fmt", "--check"}, Timeout: 30 * time.Second},
+		{Name: "lint", Command: "cargo", Args: []string{"clippy", "--", "-D", "warnings"}, Timeout: 180 * time.Second},
+		{Name: "test", Command: "cargo", Args: []string{"test"}, Timeout: 300 * time.Second},
+	}
+}
+
+func (d *ProjectDetector) generateJavaChecks() []config.CheckConfig {
+	if d.fileExists("pom.xml") {
+		return d.generateMavenChecks()
+	}
+	return d.generateGradleChecks()
+}
+
+func (d *ProjectDetector) generateMavenChecks() []config.CheckConfig {
+	// Prefer the project's wrapper so the pinned Maven version is used
+	mvn := "mvn"
+	if d.fileExists("mvnw") {
+		mvn = "./mvnw"
+	}
+
+	checks := []config.CheckConfig{
+		{Name: "test", Command: mvn, Args: []string{"-q", "test"}, Timeout: 300 * time.Second},
+		{Name: "verify", Command: mvn, Args: []string{"-q", "verify"}, Timeout: 600 * time.Second},
+	}
+	if d.fileContains("pom.xml", "spotless") {
+		checks = append([]config.CheckConfig{
+			{Name: "format", Command: mvn, Args: []string{"-q", "spotless:check"}, Timeout: 120 * time.Second},
+		}, checks...)
+	}
+	return checks
+}
+
+func (d *ProjectDetector) generateGradleChecks() []config.CheckConfig {
+	gradle := "gradle"
+	if d.fileExists("gradlew") {
+		gradle = "./gradlew"
+	}
+
+	buildFile := "build.gradle"
+	if !d.fileExists(buildFile) {
+		buildFile = "build.gradle.kts"
+	}
+
+	checks := []config.CheckConfig{
+		{Name: "test", Command: gradle, Args: []string{"test"}, Timeout: 300 * time.Second},
+		{Name: "verify", Command: gradle, Args: []string{"check"}, Timeout: 600 * time.Second},
+	}
+	if d.fileContains(buildFile, "spotless") {
+		checks = append([]config.CheckConfig{
+			{Name: "format", Command: gradle, Args: []string{"spotlessCheck"}, Timeout: 120 * time.Second},
+		}, checks...)
+	}
+	return checks
+}
+
+func (d *ProjectDetector) fileContains(filename, needle string) bool {
+	data, err := os.ReadFile(filepath.Join(d.projectPath, filename))
+	return err == nil && bytes.Contains(data, []byte(needle))
+}
+
 func (d *ProjectDetector) GenerateConfig() *config.Config {
 	info, _ := d.DetectProject()
 
@@ -0,0 +1,283 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+)
+
+type node interface {
+	eval(vars map[string]interface{}) (interface{}, error)
+}
+
+type literal struct{ value interface{} }
+
+func (n literal) eval(map[string]interface{}) (interface{}, error) {
+	return n.value, nil
+}
+
+type variable struct{ name string }
+
+func (n variable) eval(vars map[string]interface{}) (interface{}, error) {
+	return normalize(vars[n.name]), nil
+}
+
+type conditional struct{ cond, then, otherwise node }
+
+func (n conditional) eval(vars map[string]interface{}) (interface{}, error) {
+	cond, err := evalBool(n.cond, vars, "condition")
+	if err != nil {
+		return nil, err
+	}
+	if cond {
+		return n.then.eval(vars)
+	}
+	return n.otherwise.eval(vars)
+}
+
+type logical struct {
+	op          string
+	left, right node
+}
+
+// eval short-circuits, so `json != null && json.ok` is safe
+func (n logical) eval(vars map[string]interface{}) (interface{}, error) {
+	left, err := evalBool(n.left, vars, n.op)
+	if err != nil {
+		return nil, err
+	}
+	if left == (n.op == "||") {
+		return left, nil
+	}
+	return evalBool(n.right, vars, n.op)
+}
+
+type comparison struct {
+	op          string
+	left, right node
+}
+
+func (n comparison) eval(vars map[string]interface{}) (interface{}, error) {
+	left, err := n.left.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	}
+
+	var cmp int
+	switch l := left.(type) {
+	case float64:
+		r, ok := right.(float64)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare %s %s %s", typeName(left), n.op, typeName(right))
+		}
+		cmp = compareFloats(l, r)
+	case string:
+		r, ok := right.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare %s %s %s", typeName(left), n.op, typeName(right))
+		}
+		cmp = strings.Compare(l, r)
+	default:
+		return nil, fmt.Errorf("cannot compare %s %s %s", typeName(left), n.op, typeName(right))
+	}
+
+	switch n.op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+type unary struct {
+	op      string
+	operand node
+}
+
+func (n unary) eval(vars map[string]interface{}) (interface{}, error) {
+	if n.op == "!" {
+		value, err := evalBool(n.operand, vars, "!")
+		return !value, err
+	}
+	value, err := n.operand.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	number, ok := value.(float64)
+	if !ok {
+		return nil, fmt.Errorf("cannot negate %s", typeName(value))
+	}
+	return -number, nil
+}
+
+type index struct{ target, key node }
+
+func (n index) eval(vars map[string]interface{}) (interface{}, error) {
+	target, err := n.target.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	key, err := n.key.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := target.(type) {
+	case nil:
+		// Missing parents read as null, like missing fields
+		return nil, nil
+	case map[string]interface{}:
+		name, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("object key must be a string, got %s", typeName(key))
+		}
+		return normalize(t[name]), nil
+	case []interface{}:
+		i, ok := key.(float64)
+		if !ok || i != float64(int(i)) {
+			return nil, fmt.Errorf("array index must be an integer, got %v", key)
+		}
+		if i < 0 || int(i) >= len(t) {
+			return nil, nil
+		}
+		return normalize(t[int(i)]), nil
+	default:
+		return nil, fmt.Errorf("cannot index %s", typeName(target))
+	}
+}
+
+type call struct {
+	name string
+	fn   func(args []interface{}) (interface{}, error)
+	args []node
+}
+
+func (n call) eval(vars map[string]interface{}) (interface{}, error) {
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		value, err := arg.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = value
+	}
+	value, err := n.fn(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", n.name, err)
+	}
+	return value, nil
+}
+
+type function struct {
+	arity int
+	call  func(args []interface{}) (interface{}, error)
+}
+
+var functions = map[string]function{
+	"len": {1, func(args []interface{}) (interface{}, error) {
+		switch v := args[0].(type) {
+		case string:
+			return float64(len(v)), nil
+		case []interface{}:
+			return float64(len(v)), nil
+		case map[string]interface{}:
+			return float64(len(v)), nil
+		}
+		return nil, fmt.Errorf("no length for %s", typeName(args[0]))
+	}},
+	"contains": {2, func(args []interface{}) (interface{}, error) {
+		switch v := args[0].(type) {
+		case string:
+			sub, ok := args[1].(string)
+			if !ok {
+				return nil, fmt.Errorf("cannot look for %s in a string", typeName(args[1]))
+			}
+			return strings.Contains(v, sub), nil
+		case []interface{}:
+			for _, item := range v {
+				if equal(normalize(item), args[1]) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+		return nil, fmt.Errorf("cannot search %s", typeName(args[0]))
+	}},
+}
+
+func evalBool(n node, vars map[string]interface{}, context string) (bool, error) {
+	value, err := n.eval(vars)
+	if err != nil {
+		return false, err
+	}
+	b, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("%s needs a boolean, got %s", context, typeName(value))
+	}
+	return b, nil
+}
+
+// normalize turns Go numbers from callers into float64, the only number
+// type expressions work with
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	}
+	return value
+}
+
+// equal compares scalars; objects and arrays are never equal
+func equal(a, b interface{}) bool {
+	switch a.(type) {
+	case nil, bool, float64, string:
+		return a == b
+	}
+	return false
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
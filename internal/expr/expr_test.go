@@ -0,0 +1,171 @@
+package expr
+
+import "testing"
+
+var testVars = []string{"status_code", "duration_ms", "body", "json"}
+
+func testValues() map[string]interface{} {
+	return map[string]interface{}{
+		"status_code": 200,
+		"duration_ms": int64(120),
+		"body":        `{"ok":true}`,
+		"json": map[string]interface{}{
+			"ok":    true,
+			"name":  "api",
+			"count": 3.0,
+			"items": []interface{}{"a", 2.0, map[string]interface{}{"id": "x"}},
+			"empty": nil,
+		},
+	}
+}
+
+// TestEval covers precedence, associativity, short-circuiting and how
+// null and missing values read
+func TestEval(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want interface{}
+	}{
+		// Precedence, loosest first: ?:, ||, &&, comparison, unary, postfix
+		{"and binds tighter than or", "true || false && false", true},
+		{"and binds tighter than or, reversed", "false && false || true", true},
+		{"comparison binds tighter than and", "status_code == 200 && duration_ms < 500", true},
+		{"not binds tighter than comparison", "!false == true", true},
+		{"negation binds tighter than comparison", "-1 < 0", true},
+		{"postfix binds tighter than negation", "-json.count", -3.0},
+		{"postfix binds tighter than not", "!json.ok", false},
+		{"parentheses override", "(true || false) && false", false},
+		{"conditional is loosest", "status_code == 200 ? 'up' : 'down'", "up"},
+		{"conditional condition takes a whole or", "false || true ? 1 : 2", 1.0},
+
+		// Associativity
+		{"conditional nests to the right", "false ? 1 : true ? 2 : 3", 2.0},
+		{"conditional in the middle", "true ? false ? 1 : 2 : 3", 2.0},
+		{"or chains", "false || false || true", true},
+		{"and chains", "true && true && false", false},
+		{"unary operators stack", "!!true", true},
+		{"negations stack", "--1", 1.0},
+		{"postfix chains left to right", "json.items[2].id", "x"},
+
+		// Short-circuiting skips operands that would fail
+		{"and skips its right side", "false && 1 < 'a'", false},
+		{"or skips its right side", "true || len(true) == 0", true},
+		{"guard against null", "json.empty != null && json.empty.x", false},
+		{"conditional skips the other branch", "true ? 1 : -'a'", 1.0},
+
+		// Null and missing values
+		{"missing field", "json.missing", nil},
+		{"field of missing field", "json.missing.deeper", nil},
+		{"field of null", "json.empty.x", nil},
+		{"index of null", "json.empty[0]", nil},
+		{"out of range index", "json.items[3]", nil},
+		{"negative index", "json.items[-1]", nil},
+		{"null equals missing", "json.empty == json.missing", true},
+		{"null is not false", "json.missing == false", false},
+
+		// Values
+		{"Go integers are numbers", "status_code == 200 && duration_ms >= 120", true},
+		{"bracket access", "json['name'] == 'api'", true},
+		{"string escapes", `"a\"b" == 'a"b'`, true},
+		{"string comparison", "'abc' < 'abd'", true},
+		{"len", "len(json.items) == 3 && len(body) == 11 && len(json) == 5", true},
+		{"contains", "contains(body, 'ok') && contains(json.items, 2) && !contains(json.items, 'b')", true},
+		{"objects are never equal", "json.items[2] == json.items[2]", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := Parse(tt.src, testVars...)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.src, err)
+			}
+			got, err := e.Eval(testValues())
+			if err != nil {
+				t.Fatalf("Eval(%q): %v", tt.src, err)
+			}
+			if got != tt.want {
+				t.Errorf("Eval(%q) = %#v, want %#v", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestEvalErrors covers operands of the wrong type, which parse but fail
+// when evaluated
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"1 < 'a'", "cannot compare number < string"},
+		{"'a' >= 1", "cannot compare string >= number"},
+		{"json.empty < 1", "cannot compare null < number"},
+		{"true > false", "cannot compare boolean > boolean"},
+		{"json.items < json.items", "cannot compare array < array"},
+		{"'a' && true", "&& needs a boolean, got string"},
+		{"false || 1", "|| needs a boolean, got number"},
+		{"!json.missing", "! needs a boolean, got null"},
+		{"json.count ? 1 : 2", "condition needs a boolean, got number"},
+		{"-'a'", "cannot negate string"},
+		{"json.count.x", "cannot index number"},
+		{"json[0]", "object key must be a string, got number"},
+		{"json.items['x']", "array index must be an integer, got x"},
+		{"json.items[0.5]", "array index must be an integer, got 0.5"},
+		{"len(true) == 0", "len: no length for boolean"},
+		{"contains(1, 1)", "contains: cannot search number"},
+		{"contains('abc', 1)", "contains: cannot look for number in a string"},
+		{"true && len(1) == 0", "len: no length for number"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			e, err := Parse(tt.src, testVars...)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.src, err)
+			}
+			_, err = e.Eval(testValues())
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Eval(%q) error = %v, want %q", tt.src, err, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseErrors covers syntax errors and names Parse doesn't know
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"status == 200", `unknown variable "status" at offset 0`},
+		{"json.ok && latency < 5", `unknown variable "latency" at offset 11`},
+		{"size(body) > 0", `unknown function "size" at offset 0`},
+		{`body == "ok`, "unterminated string at offset 8"},
+		{`body == 'ok\'`, "unterminated string at offset 8"},
+		{"'", "unterminated string at offset 0"},
+		{"", "unexpected end of expression at offset 0"},
+		{"status_code ==", "unexpected end of expression at offset 14"},
+		{"status_code + 1", `unexpected character '+' at offset 12`},
+		{"1.2.3 > 0", `invalid number "1.2.3" at offset 0`},
+		{"(true", `expected ")", got end of expression at offset 5`},
+		{"true ? 1", `expected ":", got end of expression at offset 8`},
+		{"1 < 2 < 3", `unexpected "<" at offset 6`},
+		{"json.items[0", `expected "]", got end of expression at offset 12`},
+		{"json.", "expected a field name after '.', got end of expression at offset 5"},
+		{"json.1", `expected a field name after '.', got "1" at offset 5`},
+		{"len(body, json)", "len takes 1 arguments, got 2"},
+		{"contains(body)", "contains takes 2 arguments, got 1"},
+		{"len(body", `expected ",", got end of expression at offset 8`},
+		{"true false", `unexpected "false" at offset 5`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			_, err := Parse(tt.src, testVars...)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Parse(%q) error = %v, want %q", tt.src, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package monitors
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/config"
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// artifactWriter persists complete check output so failures can be
+// inspected after the fact instead of relying on truncated metadata.
+type artifactWriter struct {
+	dir        string
+	keep       int
+	maxAge     time.Duration
+	outputFile string
+}
+
+func newArtifactWriter(cfg config.ArtifactsConfig, outputFile string) *artifactWriter {
+	if cfg.Dir == "" && outputFile == "" {
+		return nil
+	}
+	return &artifactWriter{
+		dir:        cfg.Dir,
+		keep:       cfg.Keep,
+		maxAge:     cfg.MaxAge,
+		outputFile: outputFile,
+	}
+}
+
+// write stores the run output and returns the paths written.
+func (w *artifactWriter) write(name, command string, stdout, stderr []byte, ts time.Time) ([]string, error) {
+	content := fmt.Sprintf("$ %s\n# %s\n\n--- stdout ---\n%s\n--- stderr ---\n%s", command, ts.Format(time.RFC3339), stdout, stderr)
+
+	var paths []string
+	if w.outputFile != "" {
+		if err := writeArtifact(w.outputFile, content); err != nil {
+			return paths, err
+		}
+		paths = append(paths, w.outputFile)
+	}
+
+	if w.dir != "" {
+		prefix := unsafeFileChars.ReplaceAllString(name, "-") + "-"
+		path := filepath.Join(w.dir, prefix+ts.Format("20060102-150405.000")+".log")
+		if err := writeArtifact(path, content); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+		w.prune(prefix)
+	}
+
+	return paths, nil
+}
+
+func writeArtifact(path, content string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating artifact directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("writing artifact: %w", err)
+	}
+	return nil
+}
+
+// prune removes a check's artifacts beyond the retention count or age.
+// Timestamped names sort chronologically, so the newest are kept.
+func (w *artifactWriter) prune(prefix string) {
+	// Match the date portion exactly so "lint" doesn't claim "lint-all" files
+	matches, err := filepath.Glob(filepath.Join(w.dir, prefix+"[0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9]-*.log"))
+	if err != nil {
+		return
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(matches)))
+
+	for i, path := range matches {
+		if w.keep > 0 && i >= w.keep {
+			_ = os.Remove(path)
+			continue
+		}
+		if w.maxAge > 0 && w.expired(path) {
+			_ = os.Remove(path)
+		}
+	}
+}
+
+func (w *artifactWriter) expired(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && time.Since(info.ModTime()) > w.maxAge
+}
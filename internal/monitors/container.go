@@ -0,0 +1,82 @@
+package monitors
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/config"
+)
+
+// dockerStartFailed is the exit code docker run uses when the container
+// never started, e.g. because the image could not be pulled
+const dockerStartFailed = 125
+
+// containerWorkdir is where the check's directory is mounted when no
+// volumes are configured
+const containerWorkdir = "/src"
+
+// containerArgs builds the docker run arguments that run command inside
+// the configured image. Without volumes the check's directory is mounted
+// and used as the working directory.
+func containerArgs(cfg *config.ContainerConfig, name, dir, command string, args []string) ([]string, error) {
+	runArgs := []string{"run", "--rm", "--name", name}
+
+	volumes := cfg.Volumes
+	workdir := cfg.Workdir
+	if len(volumes) == 0 {
+		if dir == "" {
+			dir = "."
+		}
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, fmt.Errorf("resolving %s: %w", dir, err)
+		}
+		volumes = []string{abs + ":" + containerWorkdir}
+		if workdir == "" {
+			workdir = containerWorkdir
+		}
+	}
+	for _, volume := range volumes {
+		runArgs = append(runArgs, "-v", volume)
+	}
+	if workdir != "" {
+		runArgs = append(runArgs, "-w", workdir)
+	}
+
+	runArgs = append(runArgs, cfg.Image, command)
+	return append(runArgs, args...), nil
+}
+
+// containerName gives each run a unique name so a timed-out container can
+// be removed; killing the docker client alone leaves it running.
+func containerName(check string) string {
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	safe := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '-'
+	}, check)
+	return "watch-now-" + safe + "-" + hex.EncodeToString(suffix)
+}
+
+// removeContainer force-removes a container left behind by a cancelled run
+func removeContainer(name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_ = exec.CommandContext(ctx, "docker", "rm", "-f", name).Run()
+}
+
+// containerStartError explains a docker run that failed before the command
+// ran, using the last line docker printed.
+func containerStartError(image, stderr string) string {
+	lines := strings.Split(strings.TrimSpace(stderr), "\n")
+	return fmt.Sprintf("Container image %s could not be started: %s", image, lines[len(lines)-1])
+}
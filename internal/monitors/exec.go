@@ -0,0 +1,148 @@
+package monitors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/config"
+)
+
+// maxRawOutput bounds the raw output kept when a script breaks the contract
+const maxRawOutput = 4096
+
+// ExecMonitor runs a user script that reports its own result. The script
+// must print a single JSON object to stdout:
+//
+//	{"status": "ok|warn|fail|info", "message": "...", "metadata": {...}}
+//
+// status is required; message and metadata are optional and no other
+// fields are accepted. The exit code is ignored when the output conforms.
+type ExecMonitor struct {
+	name    string
+	command string
+	args    []string
+	timeout time.Duration
+}
+
+// execOutput is the JSON contract for exec monitor scripts
+type execOutput struct {
+	Status   Status                 `json:"status"`
+	Message  string                 `json:"message"`
+	Metadata map[string]interface{} `json:"metadata"`
+}
+
+func NewExecMonitor(cfg config.ServiceConfig) *ExecMonitor {
+	return &ExecMonitor{
+		name:    cfg.Name,
+		command: cfg.Command,
+		args:    cfg.Args,
+		timeout: cfg.Timeout,
+	}
+}
+
+func (m *ExecMonitor) Name() string {
+	return m.name
+}
+
+func (m *ExecMonitor) Type() MonitorType {
+	return TypeExec
+}
+
+func (m *ExecMonitor) Info() Info {
+	target := strings.TrimSpace(m.command + " " + strings.Join(m.args, " "))
+	return Info{Name: m.name, Type: TypeExec, Target: target, Timeout: m.timeout}
+}
+
+func (m *ExecMonitor) Check(ctx context.Context) (*Result, error) {
+	start := time.Now()
+
+	checkCtx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(checkCtx, m.command, m.args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+
+	result := &Result{
+		Name:      m.name,
+		Type:      TypeExec,
+		Timestamp: time.Now(),
+		Duration:  time.Since(start),
+		Metadata:  make(map[string]interface{}),
+	}
+
+	if checkCtx.Err() == context.DeadlineExceeded {
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("Script timed out after %v", m.timeout)
+		result.FailureKind = FailureTimeout
+		return result, nil
+	}
+
+	output, err := parseExecOutput(stdout.Bytes())
+	if err != nil {
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("Invalid script output: %v", err)
+		result.FailureKind = failureKindOf(runErr, FailureCommand)
+		if runErr != nil {
+			result.Message = fmt.Sprintf("Script failed (%v) with invalid output: %v", runErr, err)
+		}
+		result.Metadata["output"] = truncateOutput(stdout.String())
+		if stderr.Len() > 0 {
+			result.Metadata["stderr"] = truncateOutput(stderr.String())
+		}
+		return result, nil
+	}
+
+	for key, value := range output.Metadata {
+		result.Metadata[key] = value
+	}
+	result.Status = output.Status
+	result.Message = output.Message
+	if output.Status == StatusWarn || output.Status == StatusFail {
+		result.FailureKind = FailureAssertion
+	}
+	if result.Message == "" {
+		result.Message = fmt.Sprintf("Script reported %s in %v", output.Status, result.Duration.Round(time.Millisecond))
+	}
+	return result, nil
+}
+
+// parseExecOutput decodes and validates the script's JSON result
+func parseExecOutput(data []byte) (*execOutput, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, fmt.Errorf("no output")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var output execOutput
+	if err := decoder.Decode(&output); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("unexpected data after JSON object")
+	}
+
+	switch output.Status {
+	case StatusOK, StatusWarn, StatusFail, StatusInfo:
+	case "":
+		return nil, fmt.Errorf("missing status")
+	default:
+		return nil, fmt.Errorf("unknown status %q", output.Status)
+	}
+	return &output, nil
+}
+
+func truncateOutput(s string) string {
+	if len(s) > maxRawOutput {
+		return s[:maxRawOutput] + "... (truncated)"
+	}
+	return s
+}
@@ -0,0 +1,132 @@
+package monitors
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxBodyBytes bounds how much of a health response is read for assertions
+const maxBodyBytes = 1 << 20
+
+// readBody reads a response body for assertions, up to maxBodyBytes and
+// until wait (when positive) or ctx ends. A streaming body still open by
+// then is cut short instead of failing the check: whatever arrived is
+// returned with truncated set. It fails only when nothing arrived.
+func readBody(ctx context.Context, body io.Reader, wait time.Duration) (data []byte, truncated bool, err error) {
+	var mu sync.Mutex
+	var buf []byte
+	done := make(chan error, 1)
+	// The goroutine ends once the caller closes the body
+	go func() {
+		chunk := make([]byte, 32<<10)
+		for {
+			n, err := body.Read(chunk)
+			mu.Lock()
+			buf = append(buf, chunk[:n]...)
+			full := len(buf) >= maxBodyBytes
+			mu.Unlock()
+			if err != nil || full {
+				done <- err
+				return
+			}
+		}
+	}()
+
+	var expired <-chan time.Time
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	ended := false
+	select {
+	case err = <-done:
+		ended = err == nil || errors.Is(err, io.EOF)
+	case <-expired:
+	case <-ctx.Done():
+	}
+
+	mu.Lock()
+	data = append([]byte(nil), buf...)
+	mu.Unlock()
+	if len(data) > maxBodyBytes {
+		data = data[:maxBodyBytes]
+	}
+
+	switch {
+	case ended:
+		return data, len(data) >= maxBodyBytes, nil
+	case len(data) > 0 && (err == nil || ctx.Err() != nil):
+		return data, true, nil
+	case err != nil && ctx.Err() == nil:
+		return nil, false, err
+	default:
+		return nil, true, fmt.Errorf("no body received before the read deadline: %w", context.DeadlineExceeded)
+	}
+}
+
+// checkExpectJSON evaluates each selector against the decoded body and
+// returns the actual values found plus a description of any mismatches.
+func checkExpectJSON(body []byte, expect map[string]string) (map[string]interface{}, []string, error) {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, nil, err
+	}
+
+	selectors := make([]string, 0, len(expect))
+	for selector := range expect {
+		selectors = append(selectors, selector)
+	}
+	sort.Strings(selectors)
+
+	actual := make(map[string]interface{}, len(expect))
+	var mismatches []string
+	for _, selector := range selectors {
+		value, found := lookupJSON(doc, selector)
+		actual[selector] = value
+		if !found {
+			mismatches = append(mismatches, fmt.Sprintf("%s not found", selector))
+			continue
+		}
+		if got := fmt.Sprint(value); got != expect[selector] {
+			mismatches = append(mismatches, fmt.Sprintf("%s=%s (want %s)", selector, got, expect[selector]))
+		}
+	}
+
+	return actual, mismatches, nil
+}
+
+// lookupJSON walks a dotted selector through decoded JSON objects and arrays.
+// A leading "$." or "." is accepted for JSONPath familiarity.
+func lookupJSON(doc interface{}, selector string) (interface{}, bool) {
+	selector = strings.TrimPrefix(strings.TrimPrefix(selector, "$"), ".")
+	current := doc
+	for _, part := range strings.Split(selector, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[part]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(part)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
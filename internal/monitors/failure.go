@@ -0,0 +1,49 @@
+package monitors
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net"
+	"os/exec"
+)
+
+// FailureKind says why a check did not pass, so API consumers can group
+// and color failures without parsing messages. It is empty on OK results
+// and on checks that passed but ran slow, which carry metadata.slow.
+type FailureKind string
+
+const (
+	FailureTimeout    FailureKind = "timeout"
+	FailureConnection FailureKind = "connection"
+	FailureDNS        FailureKind = "dns"
+	FailureStatus     FailureKind = "status"
+	FailureAssertion  FailureKind = "assertion"
+	FailureCommand    FailureKind = "command-error"
+	FailureNotFound   FailureKind = "not-found"
+
+	// Set by watch-now rather than the check: a p95 over its SLO, a WARN
+	// escalated to FAIL, and a monitor that errored instead of reporting
+	FailureSLO        FailureKind = "slo"
+	FailureEscalation FailureKind = "escalation"
+	FailureError      FailureKind = "error"
+)
+
+// failureKindOf classifies network and lookup errors, falling back to
+// fallback for anything else, such as an error status from the target
+func failureKindOf(err error, fallback FailureKind) FailureKind {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var opErr *net.OpError
+	switch {
+	case errors.As(err, &dnsErr):
+		return FailureDNS
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return FailureTimeout
+	case errors.As(err, &opErr):
+		return FailureConnection
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, exec.ErrNotFound):
+		return FailureNotFound
+	}
+	return fallback
+}
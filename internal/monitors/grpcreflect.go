@@ -0,0 +1,741 @@
+package monitors
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Reflection methods, newest first. Servers that predate v1 only serve
+// v1alpha, which has the same messages.
+var grpcReflectionMethods = []string{
+	"/grpc.reflection.v1.ServerReflection/ServerReflectionInfo",
+	"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo",
+}
+
+// FieldDescriptorProto.Type values
+const (
+	protoDouble   = 1
+	protoFloat    = 2
+	protoInt64    = 3
+	protoUint64   = 4
+	protoInt32    = 5
+	protoFixed64  = 6
+	protoFixed32  = 7
+	protoBool     = 8
+	protoString   = 9
+	protoGroup    = 10
+	protoMessage  = 11
+	protoBytes    = 12
+	protoUint32   = 13
+	protoEnum     = 14
+	protoSfixed32 = 15
+	protoSfixed64 = 16
+	protoSint32   = 17
+	protoSint64   = 18
+)
+
+// protoWireField is one field of an encoded message. Varint, fixed32 and
+// fixed64 values are in value; length-delimited ones in data.
+type protoWireField struct {
+	num   uint64
+	wire  uint64
+	value uint64
+	data  []byte
+}
+
+var errTruncatedProto = errors.New("truncated protobuf message")
+
+// parseProtoFields splits an encoded message into its fields
+func parseProtoFields(msg []byte) ([]protoWireField, error) {
+	var fields []protoWireField
+	for len(msg) > 0 {
+		tag, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return nil, errTruncatedProto
+		}
+		msg = msg[n:]
+
+		f := protoWireField{num: tag >> 3, wire: tag & 0x7}
+		switch f.wire {
+		case 0: // varint
+			f.value, n = binary.Uvarint(msg)
+			if n <= 0 {
+				return nil, errTruncatedProto
+			}
+			msg = msg[n:]
+		case 1: // fixed64
+			if len(msg) < 8 {
+				return nil, errTruncatedProto
+			}
+			f.value = binary.LittleEndian.Uint64(msg)
+			msg = msg[8:]
+		case 2: // length-delimited
+			size, n := binary.Uvarint(msg)
+			if n <= 0 || uint64(len(msg)-n) < size {
+				return nil, errTruncatedProto
+			}
+			f.data = msg[n : n+int(size)]
+			msg = msg[n+int(size):]
+		case 5: // fixed32
+			if len(msg) < 4 {
+				return nil, errTruncatedProto
+			}
+			f.value = uint64(binary.LittleEndian.Uint32(msg))
+			msg = msg[4:]
+		default:
+			return nil, fmt.Errorf("unsupported protobuf wire type %d", f.wire)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+func appendProtoTag(buf []byte, num, wire uint64) []byte {
+	return binary.AppendUvarint(buf, num<<3|wire)
+}
+
+func appendProtoBytes(buf []byte, num uint64, data []byte) []byte {
+	buf = appendProtoTag(buf, num, 2)
+	buf = binary.AppendUvarint(buf, uint64(len(data)))
+	return append(buf, data...)
+}
+
+// protoMessageType is what encoding JSON needs from a DescriptorProto
+type protoMessageType struct {
+	fields   []*protoFieldType
+	mapEntry bool
+}
+
+// protoFieldType is what encoding JSON needs from a FieldDescriptorProto.
+// typeName is fully qualified without the leading dot.
+type protoFieldType struct {
+	name     string
+	jsonName string
+	number   uint64
+	repeated bool
+	kind     uint64
+	typeName string
+}
+
+// protoSchema holds the types of the descriptor files a server returned
+// over reflection, keyed by fully qualified name without the leading dot
+type protoSchema struct {
+	files    map[string]bool
+	imports  []string
+	messages map[string]*protoMessageType
+	enums    map[string]map[string]int32
+	methods  map[string]string // "/pkg.Service/Method" -> input type
+}
+
+func newProtoSchema() *protoSchema {
+	return &protoSchema{
+		files:    make(map[string]bool),
+		messages: make(map[string]*protoMessageType),
+		enums:    make(map[string]map[string]int32),
+		methods:  make(map[string]string),
+	}
+}
+
+// missingImports lists imported files that haven't been added yet
+func (s *protoSchema) missingImports() []string {
+	var missing []string
+	for _, name := range s.imports {
+		if !s.files[name] {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// addFile adds the types of an encoded FileDescriptorProto
+func (s *protoSchema) addFile(data []byte) error {
+	fields, err := parseProtoFields(data)
+	if err != nil {
+		return err
+	}
+	var name, pkg string
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			name = string(f.data)
+		case 2:
+			pkg = string(f.data)
+		}
+	}
+	if s.files[name] {
+		return nil
+	}
+	s.files[name] = true
+
+	for _, f := range fields {
+		var err error
+		switch f.num {
+		case 3:
+			s.imports = append(s.imports, string(f.data))
+		case 4:
+			err = s.addMessage(pkg, f.data)
+		case 5:
+			err = s.addEnum(pkg, f.data)
+		case 6:
+			err = s.addService(pkg, f.data)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// addMessage adds a DescriptorProto and the types nested in it
+func (s *protoSchema) addMessage(scope string, data []byte) error {
+	fields, err := parseProtoFields(data)
+	if err != nil {
+		return err
+	}
+	var fullName string
+	for _, f := range fields {
+		if f.num == 1 {
+			fullName = qualify(scope, string(f.data))
+		}
+	}
+
+	msg := &protoMessageType{}
+	for _, f := range fields {
+		var err error
+		switch f.num {
+		case 2:
+			var field *protoFieldType
+			if field, err = parseProtoField(f.data); err == nil {
+				msg.fields = append(msg.fields, field)
+			}
+		case 3:
+			err = s.addMessage(fullName, f.data)
+		case 4:
+			err = s.addEnum(fullName, f.data)
+		case 7: // MessageOptions
+			var options []protoWireField
+			options, err = parseProtoFields(f.data)
+			for _, o := range options {
+				if o.num == 7 {
+					msg.mapEntry = o.value != 0
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	s.messages[fullName] = msg
+	return nil
+}
+
+func parseProtoField(data []byte) (*protoFieldType, error) {
+	fields, err := parseProtoFields(data)
+	if err != nil {
+		return nil, err
+	}
+	field := &protoFieldType{}
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			field.name = string(f.data)
+		case 3:
+			field.number = f.value
+		case 4:
+			field.repeated = f.value == 3 // LABEL_REPEATED
+		case 5:
+			field.kind = f.value
+		case 6:
+			field.typeName = strings.TrimPrefix(string(f.data), ".")
+		case 10:
+			field.jsonName = string(f.data)
+		}
+	}
+	if field.jsonName == "" {
+		field.jsonName = protoJSONName(field.name)
+	}
+	return field, nil
+}
+
+// protoJSONName is protoc's default json_name: the field name in
+// lowerCamelCase
+func protoJSONName(name string) string {
+	var b strings.Builder
+	upper := false
+	for _, r := range name {
+		switch {
+		case r == '_':
+			upper = true
+		case upper && r >= 'a' && r <= 'z':
+			b.WriteRune(r - 'a' + 'A')
+			upper = false
+		default:
+			b.WriteRune(r)
+			upper = false
+		}
+	}
+	return b.String()
+}
+
+// addEnum adds an EnumDescriptorProto's value names
+func (s *protoSchema) addEnum(scope string, data []byte) error {
+	fields, err := parseProtoFields(data)
+	if err != nil {
+		return err
+	}
+	var name string
+	values := make(map[string]int32)
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			name = string(f.data)
+		case 2:
+			value, err := parseProtoFields(f.data)
+			if err != nil {
+				return err
+			}
+			var valueName string
+			var number int32
+			for _, v := range value {
+				switch v.num {
+				case 1:
+					valueName = string(v.data)
+				case 2:
+					number = int32(v.value)
+				}
+			}
+			values[valueName] = number
+		}
+	}
+	s.enums[qualify(scope, name)] = values
+	return nil
+}
+
+// addService records the input type of each of a ServiceDescriptorProto's
+// methods
+func (s *protoSchema) addService(pkg string, data []byte) error {
+	fields, err := parseProtoFields(data)
+	if err != nil {
+		return err
+	}
+	var service string
+	for _, f := range fields {
+		if f.num == 1 {
+			service = qualify(pkg, string(f.data))
+		}
+	}
+	for _, f := range fields {
+		if f.num != 2 {
+			continue
+		}
+		method, err := parseProtoFields(f.data)
+		if err != nil {
+			return err
+		}
+		var name, input string
+		for _, m := range method {
+			switch m.num {
+			case 1:
+				name = string(m.data)
+			case 2:
+				input = strings.TrimPrefix(string(m.data), ".")
+			}
+		}
+		s.methods["/"+service+"/"+name] = input
+	}
+	return nil
+}
+
+// encodeJSON encodes a JSON object as a message of the named type, taking
+// field names in either their proto or JSON form as protobuf's JSON
+// mapping does. 64-bit integers may be numbers or strings, bytes are
+// base64 and enums are value names or numbers.
+func (s *protoSchema) encodeJSON(typeName, data string) ([]byte, error) {
+	dec := json.NewDecoder(strings.NewReader(data))
+	dec.UseNumber()
+	var obj map[string]interface{}
+	if err := dec.Decode(&obj); err != nil {
+		return nil, err
+	}
+	return s.encodeMessage(typeName, obj)
+}
+
+func (s *protoSchema) encodeMessage(typeName string, obj map[string]interface{}) ([]byte, error) {
+	msg := s.messages[typeName]
+	if msg == nil {
+		return nil, fmt.Errorf("message type %s not found", typeName)
+	}
+
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	buf := []byte{}
+	for _, key := range keys {
+		var field *protoFieldType
+		for _, f := range msg.fields {
+			if f.name == key || f.jsonName == key {
+				field = f
+				break
+			}
+		}
+		if field == nil {
+			return nil, fmt.Errorf("%s has no field %q", typeName, key)
+		}
+		// null leaves the field unset, as in protobuf's JSON mapping
+		if obj[key] == nil {
+			continue
+		}
+		var err error
+		if buf, err = s.appendField(buf, field, obj[key]); err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return buf, nil
+}
+
+// appendField encodes a field's JSON value, which is an object for map
+// fields and an array for other repeated fields
+func (s *protoSchema) appendField(buf []byte, field *protoFieldType, value interface{}) ([]byte, error) {
+	if !field.repeated {
+		return s.appendValue(buf, field, value)
+	}
+
+	if entry := s.messages[field.typeName]; field.kind == protoMessage && entry != nil && entry.mapEntry {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected an object, got %s", jsonKind(value))
+		}
+		var keyField, valueField *protoFieldType
+		for _, f := range entry.fields {
+			switch f.number {
+			case 1:
+				keyField = f
+			case 2:
+				valueField = f
+			}
+		}
+		if keyField == nil || valueField == nil {
+			return nil, fmt.Errorf("map entry %s is missing its key or value", field.typeName)
+		}
+
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			// Map keys are always strings in JSON, whatever their type
+			var k interface{} = key
+			switch keyField.kind {
+			case protoBool:
+				b, err := strconv.ParseBool(key)
+				if err != nil {
+					return nil, fmt.Errorf("key %q: expected true or false", key)
+				}
+				k = b
+			case protoString:
+			default:
+				k = json.Number(key)
+			}
+			entryBuf, err := s.appendValue(nil, keyField, k)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %w", key, err)
+			}
+			if obj[key] != nil {
+				if entryBuf, err = s.appendValue(entryBuf, valueField, obj[key]); err != nil {
+					return nil, fmt.Errorf("%s: %w", key, err)
+				}
+			}
+			buf = appendProtoBytes(buf, field.number, entryBuf)
+		}
+		return buf, nil
+	}
+
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected an array, got %s", jsonKind(value))
+	}
+	// Unpacked: parsers accept it for every repeated field, packed or not
+	for i, item := range list {
+		var err error
+		if buf, err = s.appendValue(buf, field, item); err != nil {
+			return nil, fmt.Errorf("[%d]: %w", i, err)
+		}
+	}
+	return buf, nil
+}
+
+// appendValue encodes a single value of a field
+func (s *protoSchema) appendValue(buf []byte, field *protoFieldType, value interface{}) ([]byte, error) {
+	switch field.kind {
+	case protoDouble, protoFloat:
+		f, err := jsonFloat(value)
+		if err != nil {
+			return nil, err
+		}
+		if field.kind == protoFloat {
+			buf = appendProtoTag(buf, field.number, 5)
+			return binary.LittleEndian.AppendUint32(buf, math.Float32bits(float32(f))), nil
+		}
+		buf = appendProtoTag(buf, field.number, 1)
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(f)), nil
+
+	case protoInt32, protoInt64, protoSint32, protoSint64, protoSfixed32, protoSfixed64:
+		bits := 64
+		if field.kind == protoInt32 || field.kind == protoSint32 || field.kind == protoSfixed32 {
+			bits = 32
+		}
+		n, err := strconv.ParseInt(jsonString(value), 10, bits)
+		if err != nil {
+			return nil, fmt.Errorf("expected a %d-bit integer, got %s", bits, jsonKind(value))
+		}
+		switch field.kind {
+		case protoSint32, protoSint64:
+			buf = appendProtoTag(buf, field.number, 0)
+			return binary.AppendUvarint(buf, uint64(n<<1)^uint64(n>>63)), nil
+		case protoSfixed32:
+			buf = appendProtoTag(buf, field.number, 5)
+			return binary.LittleEndian.AppendUint32(buf, uint32(n)), nil
+		case protoSfixed64:
+			buf = appendProtoTag(buf, field.number, 1)
+			return binary.LittleEndian.AppendUint64(buf, uint64(n)), nil
+		}
+		// Negative int32s are sign-extended to ten bytes like int64s
+		buf = appendProtoTag(buf, field.number, 0)
+		return binary.AppendUvarint(buf, uint64(n)), nil
+
+	case protoUint32, protoUint64, protoFixed32, protoFixed64:
+		bits := 64
+		if field.kind == protoUint32 || field.kind == protoFixed32 {
+			bits = 32
+		}
+		n, err := strconv.ParseUint(jsonString(value), 10, bits)
+		if err != nil {
+			return nil, fmt.Errorf("expected an unsigned %d-bit integer, got %s", bits, jsonKind(value))
+		}
+		switch field.kind {
+		case protoFixed32:
+			buf = appendProtoTag(buf, field.number, 5)
+			return binary.LittleEndian.AppendUint32(buf, uint32(n)), nil
+		case protoFixed64:
+			buf = appendProtoTag(buf, field.number, 1)
+			return binary.LittleEndian.AppendUint64(buf, n), nil
+		}
+		buf = appendProtoTag(buf, field.number, 0)
+		return binary.AppendUvarint(buf, n), nil
+
+	case protoBool:
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("expected true or false, got %s", jsonKind(value))
+		}
+		var n uint64
+		if b {
+			n = 1
+		}
+		buf = appendProtoTag(buf, field.number, 0)
+		return binary.AppendUvarint(buf, n), nil
+
+	case protoString:
+		str, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a string, got %s", jsonKind(value))
+		}
+		return appendProtoBytes(buf, field.number, []byte(str)), nil
+
+	case protoBytes:
+		str, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a base64 string, got %s", jsonKind(value))
+		}
+		data, err := base64.StdEncoding.DecodeString(str)
+		if err != nil {
+			// The JSON mapping accepts the URL-safe alphabet too
+			if data, err = base64.URLEncoding.DecodeString(str); err != nil {
+				return nil, fmt.Errorf("expected a base64 string: %w", err)
+			}
+		}
+		return appendProtoBytes(buf, field.number, data), nil
+
+	case protoEnum:
+		values := s.enums[field.typeName]
+		if values == nil {
+			return nil, fmt.Errorf("enum type %s not found", field.typeName)
+		}
+		var number int32
+		if name, ok := value.(string); ok {
+			n, ok := values[name]
+			if !ok {
+				return nil, fmt.Errorf("%s has no value %q", field.typeName, name)
+			}
+			number = n
+		} else {
+			n, err := strconv.ParseInt(jsonString(value), 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("expected a value name or number, got %s", jsonKind(value))
+			}
+			number = int32(n)
+		}
+		buf = appendProtoTag(buf, field.number, 0)
+		return binary.AppendUvarint(buf, uint64(int64(number))), nil
+
+	case protoMessage:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected an object, got %s", jsonKind(value))
+		}
+		data, err := s.encodeMessage(field.typeName, obj)
+		if err != nil {
+			return nil, err
+		}
+		return appendProtoBytes(buf, field.number, data), nil
+	}
+	return nil, fmt.Errorf("unsupported field type %d", field.kind)
+}
+
+// jsonString is the text of a JSON number or string, which is all an
+// integer may be given as, or "" for anything else
+func jsonString(value interface{}) string {
+	switch v := value.(type) {
+	case json.Number:
+		return string(v)
+	case string:
+		return v
+	}
+	return ""
+}
+
+// jsonFloat reads a number, or a string such as "NaN" or "-Infinity"
+func jsonFloat(value interface{}) (float64, error) {
+	f, err := strconv.ParseFloat(jsonString(value), 64)
+	if err != nil {
+		return 0, fmt.Errorf("expected a number, got %s", jsonKind(value))
+	}
+	return f, nil
+}
+
+func jsonKind(value interface{}) string {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "an array"
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return string(v)
+	case string:
+		return strconv.Quote(v)
+	}
+	return "null"
+}
+
+// loadSchema fetches the descriptor files defining the method's service
+// over server reflection, then any files they import, so every type its
+// request can refer to is known
+func (m *GRPCWebMonitor) loadSchema(ctx context.Context) (*protoSchema, error) {
+	service, _, _ := strings.Cut(strings.TrimPrefix(m.method, "/"), "/")
+	schema := newProtoSchema()
+
+	// ServerReflectionRequest{file_containing_symbol: 4}
+	files, err := m.reflect(ctx, appendProtoBytes(nil, 4, []byte(service)))
+	for err == nil {
+		for _, file := range files {
+			if err := schema.addFile(file); err != nil {
+				return nil, fmt.Errorf("reading descriptors: %w", err)
+			}
+		}
+		missing := schema.missingImports()
+		if len(missing) == 0 {
+			break
+		}
+		// ServerReflectionRequest{file_by_filename: 3}
+		files, err = m.reflect(ctx, appendProtoBytes(nil, 3, []byte(missing[0])))
+		if err == nil && len(files) == 0 {
+			err = fmt.Errorf("server returned no descriptor for %s", missing[0])
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// reflect sends one ServerReflectionRequest and returns the encoded
+// FileDescriptorProtos in the reply. The stream carries just the one
+// request, which gRPC-Web proxies pass on as a half-closed call.
+func (m *GRPCWebMonitor) reflect(ctx context.Context, request []byte) ([][]byte, error) {
+	methods := grpcReflectionMethods
+	if m.reflection != "" {
+		methods = []string{m.reflection}
+	}
+	var response []byte
+	var err, firstErr error
+	for _, method := range methods {
+		if response, err = m.call(ctx, method, request); err == nil {
+			m.reflection = method
+			break
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("server reflection: %w", firstErr)
+	}
+
+	fields, err := parseProtoFields(response)
+	if err != nil {
+		return nil, fmt.Errorf("server reflection: %w", err)
+	}
+	var files [][]byte
+	for _, f := range fields {
+		switch f.num {
+		case 4: // file_descriptor_response
+			inner, err := parseProtoFields(f.data)
+			if err != nil {
+				return nil, fmt.Errorf("server reflection: %w", err)
+			}
+			for _, file := range inner {
+				if file.num == 1 {
+					files = append(files, file.data)
+				}
+			}
+		case 7: // error_response
+			inner, err := parseProtoFields(f.data)
+			if err != nil {
+				return nil, fmt.Errorf("server reflection: %w", err)
+			}
+			var code uint64
+			var message string
+			for _, e := range inner {
+				switch e.num {
+				case 1:
+					code = e.value
+				case 2:
+					message = string(e.data)
+				}
+			}
+			return nil, fmt.Errorf("server reflection: %w", grpcStatusError(strconv.FormatUint(code, 10), message))
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,264 @@
+package monitors
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/config"
+)
+
+// Descriptor builders for the test schema, encoding just the fields the
+// monitor reads
+
+func descString(num uint64, s string) []byte {
+	return appendProtoBytes(nil, num, []byte(s))
+}
+
+func descVarint(num, value uint64) []byte {
+	return binary.AppendUvarint(appendProtoTag(nil, num, 0), value)
+}
+
+func descMsg(num uint64, parts ...[]byte) []byte {
+	return appendProtoBytes(nil, num, bytes.Join(parts, nil))
+}
+
+// fieldDesc encodes a FieldDescriptorProto as field 2 of a DescriptorProto
+func fieldDesc(name string, number, label, kind uint64, typeName string) []byte {
+	parts := [][]byte{descString(1, name), descVarint(3, number), descVarint(4, label), descVarint(5, kind)}
+	if typeName != "" {
+		parts = append(parts, descString(6, typeName))
+	}
+	return descMsg(2, parts...)
+}
+
+const optional, repeated = 1, 3
+
+// billing.proto imports common.proto, which the server only hands out
+// when asked for it by name
+var (
+	billingProto = bytes.Join([][]byte{
+		descString(1, "billing.proto"),
+		descString(2, "billing.v1"),
+		descString(3, "common.proto"),
+		descMsg(4,
+			descString(1, "GetStatusRequest"),
+			fieldDesc("account_id", 1, optional, protoString, ""),
+			fieldDesc("limit", 2, optional, protoInt64, ""),
+			fieldDesc("regions", 3, repeated, protoEnum, ".common.v1.Region"),
+			fieldDesc("quotas", 4, repeated, protoMessage, ".billing.v1.GetStatusRequest.QuotasEntry"),
+			fieldDesc("page", 5, optional, protoMessage, ".common.v1.Page"),
+			fieldDesc("verbose", 6, optional, protoBool, ""),
+			fieldDesc("ratio", 7, optional, protoDouble, ""),
+			fieldDesc("delta", 8, optional, protoSint32, ""),
+			fieldDesc("token", 9, optional, protoBytes, ""),
+			descMsg(3,
+				descString(1, "QuotasEntry"),
+				fieldDesc("key", 1, optional, protoString, ""),
+				fieldDesc("value", 2, optional, protoInt32, ""),
+				descMsg(7, descVarint(7, 1)),
+			),
+		),
+		descMsg(6,
+			descString(1, "Billing"),
+			descMsg(2, descString(1, "GetStatus"), descString(2, ".billing.v1.GetStatusRequest"), descString(3, ".common.v1.Page")),
+		),
+	}, nil)
+
+	commonProto = bytes.Join([][]byte{
+		descString(1, "common.proto"),
+		descString(2, "common.v1"),
+		descMsg(4, descString(1, "Page"), fieldDesc("size", 1, optional, protoInt32, "")),
+		descMsg(5,
+			descString(1, "Region"),
+			descMsg(2, descString(1, "REGION_UNSPECIFIED"), descVarint(2, 0)),
+			descMsg(2, descString(1, "EU"), descVarint(2, 1)),
+			descMsg(2, descString(1, "US"), descVarint(2, 2)),
+		),
+	}, nil)
+)
+
+// TestProtoSchemaEncodeJSON covers each field kind, proto and JSON field
+// names, and JSON that doesn't fit the message
+func TestProtoSchemaEncodeJSON(t *testing.T) {
+	schema := newProtoSchema()
+	for _, file := range [][]byte{billingProto, commonProto} {
+		if err := schema.addFile(file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := schema.methods["/billing.v1.Billing/GetStatus"]; got != "billing.v1.GetStatusRequest" {
+		t.Fatalf("GetStatus input type = %q", got)
+	}
+
+	tests := []struct {
+		name    string
+		json    string
+		want    string // hex
+		wantErr string
+	}{
+		{name: "empty", json: `{}`, want: ""},
+		{name: "string by proto name", json: `{"account_id": "acme"}`, want: "0a0461636d65"},
+		{name: "string by JSON name", json: `{"accountId": "acme"}`, want: "0a0461636d65"},
+		{name: "int64 as string", json: `{"limit": "5000000000"}`, want: "1080e497d012"},
+		{name: "int64 as number", json: `{"limit": 5000000000}`, want: "1080e497d012"},
+		{name: "enums by name and number", json: `{"regions": ["EU", 2]}`, want: "18011802"},
+		{name: "map with negative int32", json: `{"quotas": {"cpu": -1}}`, want: "22100a0363707510ffffffffffffffffff01"},
+		{name: "nested message from an import", json: `{"page": {"size": 10}}`, want: "2a02080a"},
+		{name: "bool", json: `{"verbose": true}`, want: "3001"},
+		{name: "double", json: `{"ratio": 0.5}`, want: "39000000000000e03f"},
+		{name: "sint32", json: `{"delta": -2}`, want: "4003"},
+		{name: "bytes", json: `{"token": "AQI="}`, want: "4a020102"},
+		{name: "null is unset", json: `{"page": null}`, want: ""},
+		{name: "fields in name order", json: `{"verbose": false, "account_id": "a"}`, want: "0a01613000"},
+
+		{name: "unknown field", json: `{"acount": "x"}`, wantErr: `billing.v1.GetStatusRequest has no field "acount"`},
+		{name: "wrong type", json: `{"account_id": 3}`, wantErr: "account_id: expected a string, got 3"},
+		{name: "int32 overflow", json: `{"page": {"size": 3000000000}}`, wantErr: "page: size: expected a 32-bit integer, got 3000000000"},
+		{name: "fraction for an integer", json: `{"limit": 1.5}`, wantErr: "limit: expected a 64-bit integer, got 1.5"},
+		{name: "unknown enum value", json: `{"regions": ["ASIA"]}`, wantErr: `regions: [0]: common.v1.Region has no value "ASIA"`},
+		{name: "repeated needs an array", json: `{"regions": "EU"}`, wantErr: `regions: expected an array, got "EU"`},
+		{name: "map needs an object", json: `{"quotas": [1]}`, wantErr: "quotas: expected an object, got an array"},
+		{name: "not an object", json: `[]`, wantErr: "cannot unmarshal array"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := schema.encodeJSON("billing.v1.GetStatusRequest", tt.json)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("encodeJSON error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hex.EncodeToString(got) != tt.want {
+				t.Errorf("encodeJSON = %x, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeGRPCWebServer serves GetStatus and, unless reflection is false,
+// v1alpha server reflection. It records the last GetStatus request.
+type fakeGRPCWebServer struct {
+	reflection  bool
+	reflections atomic.Int32
+	request     atomic.Value // []byte
+}
+
+func (s *fakeGRPCWebServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if len(body) < 5 {
+		http.Error(w, "no frame", http.StatusBadRequest)
+		return
+	}
+	message := body[5:]
+	w.Header().Set("Content-Type", "application/grpc-web+proto")
+
+	var response []byte
+	switch {
+	case r.URL.Path == "/billing.v1.Billing/GetStatus":
+		s.request.Store(message)
+	case r.URL.Path == grpcReflectionMethods[1] && s.reflection:
+		s.reflections.Add(1)
+		fields, _ := parseProtoFields(message)
+		var files [][]byte
+		for _, f := range fields {
+			switch {
+			case f.num == 4 && string(f.data) == "billing.v1.Billing":
+				files = append(files, billingProto)
+			case f.num == 3 && string(f.data) == "common.proto":
+				files = append(files, commonProto)
+			}
+		}
+		if files == nil {
+			response = descMsg(7, descVarint(1, 5), descString(2, "not found"))
+			break
+		}
+		var descriptors []byte
+		for _, file := range files {
+			descriptors = appendProtoBytes(descriptors, 1, file)
+		}
+		response = appendProtoBytes(nil, 4, descriptors)
+	default:
+		// Trailers-only, as servers answer methods they don't have
+		w.Header().Set("Grpc-Status", "12")
+		w.Header().Set("Grpc-Message", "unknown service")
+		return
+	}
+	_, _ = w.Write(grpcWebFrame(0x00, response))
+	_, _ = w.Write(grpcWebFrame(0x80, []byte("grpc-status: 0\r\n")))
+}
+
+// TestGRPCWebMethodRequest builds the request over reflection once,
+// falling back to v1alpha, and skips reflection for empty requests
+func TestGRPCWebMethodRequest(t *testing.T) {
+	tests := []struct {
+		name            string
+		request         string
+		reflection      bool
+		wantStatus      Status
+		wantMessage     string
+		wantRequest     string // hex
+		wantReflections int32
+	}{
+		{name: "JSON request", request: `{"accountId": "acme", "regions": ["US"]}`, reflection: true, wantStatus: StatusOK, wantMessage: "/billing.v1.Billing/GetStatus succeeded", wantRequest: "0a0461636d651802", wantReflections: 2},
+		{name: "empty request", request: "", wantStatus: StatusOK, wantMessage: "/billing.v1.Billing/GetStatus succeeded", wantRequest: ""},
+		{name: "empty object", request: " { } ", wantStatus: StatusOK, wantMessage: "/billing.v1.Billing/GetStatus succeeded", wantRequest: ""},
+		{name: "no reflection service", request: `{"accountId": "acme"}`, wantStatus: StatusFail, wantMessage: "/billing.v1.Billing/GetStatus failed: server reflection: grpc-status 12: unknown service"},
+		{name: "request that doesn't fit", request: `{"account": "acme"}`, reflection: true, wantStatus: StatusFail, wantMessage: `/billing.v1.Billing/GetStatus failed: encoding grpc_request as billing.v1.GetStatusRequest: billing.v1.GetStatusRequest has no field "account"`, wantReflections: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &fakeGRPCWebServer{reflection: tt.reflection}
+			srv := httptest.NewServer(server)
+			defer srv.Close()
+
+			m := NewGRPCWebMonitor(config.ServiceConfig{
+				Name:        "billing",
+				Type:        "grpc-web",
+				URL:         srv.URL,
+				Timeout:     5 * time.Second,
+				GRPCMethod:  "/billing.v1.Billing/GetStatus",
+				GRPCRequest: tt.request,
+			})
+			// The second check must reuse the request built by the first
+			for i := 0; i < 2; i++ {
+				result, err := m.Check(context.Background())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result.Status != tt.wantStatus || !strings.HasPrefix(result.Message, tt.wantMessage) {
+					t.Fatalf("check %d = %s (%s), want %s (%s)", i+1, result.Status, result.Message, tt.wantStatus, tt.wantMessage)
+				}
+			}
+
+			if tt.wantStatus == StatusOK {
+				got, _ := server.request.Load().([]byte)
+				if hex.EncodeToString(got) != tt.wantRequest {
+					t.Errorf("GetStatus request = %x, want %s", got, tt.wantRequest)
+				}
+			}
+			// A failed build is retried on the next check
+			wantReflections := tt.wantReflections
+			if tt.wantStatus == StatusFail {
+				wantReflections *= 2
+			}
+			if got := server.reflections.Load(); got != wantReflections {
+				t.Errorf("reflection requests = %d, want %d", got, wantReflections)
+			}
+		})
+	}
+}
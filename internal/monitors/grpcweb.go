@@ -0,0 +1,326 @@
+package monitors
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/config"
+)
+
+const grpcHealthMethod = "/grpc.health.v1.Health/Check"
+
+// grpcServingStatus names HealthCheckResponse.ServingStatus values
+var grpcServingStatus = map[uint64]string{
+	0: "UNKNOWN",
+	1: "SERVING",
+	2: "NOT_SERVING",
+	3: "SERVICE_UNKNOWN",
+}
+
+// GRPCWebMonitor calls the standard gRPC health service using gRPC-Web
+// framing over a plain HTTP POST, for services behind HTTP-only proxies.
+// With a method set it calls that RPC instead and only needs it to succeed.
+// A JSON request for it is encoded using the types the server describes
+// over reflection, fetched once and kept for later checks.
+type GRPCWebMonitor struct {
+	name    string
+	url     string
+	service string
+	timeout time.Duration
+	headers map[string]string
+	client  *http.Client
+
+	// method and requestJSON replace the health check when method is set
+	method      string
+	requestJSON string
+
+	mu         sync.Mutex
+	request    []byte // requestJSON encoded, once reflection has succeeded
+	reflection string // the reflection method the server answered
+}
+
+func NewGRPCWebMonitor(cfg config.ServiceConfig) *GRPCWebMonitor {
+	return &GRPCWebMonitor{
+		name:        cfg.Name,
+		url:         strings.TrimSuffix(cfg.URL, "/"),
+		service:     cfg.GRPCService,
+		timeout:     cfg.Timeout,
+		headers:     cfg.Headers,
+		client:      newHTTPClient("", cfg.Proxy, cfg.ConnectTimeout),
+		method:      cfg.GRPCMethod,
+		requestJSON: cfg.GRPCRequest,
+	}
+}
+
+func (m *GRPCWebMonitor) Name() string {
+	return m.name
+}
+
+func (m *GRPCWebMonitor) Type() MonitorType {
+	return TypeGRPCWeb
+}
+
+func (m *GRPCWebMonitor) Info() Info {
+	target := m.url + m.rpc()
+	if m.service != "" {
+		target += " service=" + m.service
+	}
+	return Info{Name: m.name, Type: TypeGRPCWeb, Target: target, Timeout: m.timeout}
+}
+
+func (m *GRPCWebMonitor) Check(ctx context.Context) (*Result, error) {
+	start := time.Now()
+
+	checkCtx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	result := &Result{
+		Name:     m.name,
+		Type:     TypeGRPCWeb,
+		Metadata: map[string]interface{}{"url": m.url + m.rpc()},
+	}
+	if m.service != "" {
+		result.Metadata["service"] = m.service
+	}
+
+	request := encodeHealthRequest(m.service)
+	var err error
+	if m.method != "" {
+		request, err = m.methodRequest(checkCtx)
+	}
+	var response []byte
+	if err == nil {
+		response, err = m.call(checkCtx, m.rpc(), request)
+	}
+	result.Duration = time.Since(start)
+	result.Timestamp = time.Now()
+	if err != nil {
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("Health check failed: %v", err)
+		if m.method != "" {
+			result.Message = fmt.Sprintf("%s failed: %v", m.method, err)
+		}
+		result.FailureKind = failureKindOf(err, FailureStatus)
+		return result, nil
+	}
+
+	if m.method != "" {
+		result.Metadata["response_bytes"] = len(response)
+		result.Status = StatusOK
+		result.Message = fmt.Sprintf("%s succeeded in %v", m.method, result.Duration.Round(time.Millisecond))
+		return result, nil
+	}
+
+	status := decodeHealthResponse(response)
+	result.Metadata["serving_status"] = status
+	if status == "SERVING" {
+		result.Status = StatusOK
+		result.Message = fmt.Sprintf("SERVING in %v", result.Duration.Round(time.Millisecond))
+	} else {
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("Service reports %s", status)
+		result.FailureKind = FailureStatus
+	}
+	return result, nil
+}
+
+// rpc is the method path the monitor calls
+func (m *GRPCWebMonitor) rpc() string {
+	if m.method != "" {
+		return m.method
+	}
+	return grpcHealthMethod
+}
+
+// methodRequest returns the encoded request for method. An empty or {}
+// request is the message with every field unset, which needs no types.
+func (m *GRPCWebMonitor) methodRequest(ctx context.Context) ([]byte, error) {
+	if isEmptyJSONObject(m.requestJSON) {
+		return nil, nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.request != nil {
+		return m.request, nil
+	}
+
+	schema, err := m.loadSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	input, ok := schema.methods[m.method]
+	if !ok {
+		return nil, fmt.Errorf("server reflection does not describe %s", m.method)
+	}
+	request, err := schema.encodeJSON(input, m.requestJSON)
+	if err != nil {
+		return nil, fmt.Errorf("encoding grpc_request as %s: %w", input, err)
+	}
+	m.request = request
+	return request, nil
+}
+
+// isEmptyJSONObject reports whether data is blank or an object with no
+// members
+func isEmptyJSONObject(data string) bool {
+	data = strings.TrimSpace(data)
+	if data == "" {
+		return true
+	}
+	if !strings.HasPrefix(data, "{") || !strings.HasSuffix(data, "}") {
+		return false
+	}
+	return strings.TrimSpace(data[1:len(data)-1]) == ""
+}
+
+// call performs a unary RPC and returns the response message
+func (m *GRPCWebMonitor) call(ctx context.Context, method string, request []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", m.url+method, bytes.NewReader(grpcWebFrame(0x00, request)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/grpc-web+proto")
+	req.Header.Set("X-Grpc-Web", "1")
+	for key, value := range m.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	// Trailers-only responses carry the status in the headers
+	if err := grpcStatusError(resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message")); err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+	return parseGRPCWebResponse(body)
+}
+
+// parseGRPCWebResponse walks the data and trailer frames of a response and
+// returns its message
+func parseGRPCWebResponse(body []byte) ([]byte, error) {
+	var message []byte
+	var trailers textproto.MIMEHeader
+
+	for len(body) > 0 {
+		if len(body) < 5 {
+			return nil, errors.New("truncated gRPC-Web frame")
+		}
+		flag := body[0]
+		size := binary.BigEndian.Uint32(body[1:5])
+		if uint32(len(body)-5) < size {
+			return nil, errors.New("truncated gRPC-Web frame")
+		}
+		payload := body[5 : 5+size]
+		body = body[5+size:]
+
+		if flag&0x80 != 0 {
+			trailers = parseGRPCTrailers(payload)
+		} else {
+			message = payload
+		}
+	}
+
+	if trailers != nil {
+		if err := grpcStatusError(trailers.Get("Grpc-Status"), trailers.Get("Grpc-Message")); err != nil {
+			return nil, err
+		}
+	}
+	if message == nil {
+		return nil, errors.New("response contained no message")
+	}
+	return message, nil
+}
+
+func parseGRPCTrailers(payload []byte) textproto.MIMEHeader {
+	trailers := make(textproto.MIMEHeader)
+	for _, line := range strings.Split(string(payload), "\r\n") {
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			trailers.Add(strings.TrimSpace(key), strings.TrimSpace(value))
+		}
+	}
+	return trailers
+}
+
+func grpcStatusError(code, message string) error {
+	if code == "" || code == "0" {
+		return nil
+	}
+	if message != "" {
+		return fmt.Errorf("grpc-status %s: %s", code, message)
+	}
+	return fmt.Errorf("grpc-status %s", code)
+}
+
+func grpcWebFrame(flag byte, payload []byte) []byte {
+	frame := []byte{flag}
+	frame = binary.BigEndian.AppendUint32(frame, uint32(len(payload)))
+	return append(frame, payload...)
+}
+
+// encodeHealthRequest encodes HealthCheckRequest{service: 1}
+func encodeHealthRequest(service string) []byte {
+	if service == "" {
+		return nil
+	}
+	buf := []byte{0x0a} // field 1, length-delimited
+	buf = binary.AppendUvarint(buf, uint64(len(service)))
+	return append(buf, service...)
+}
+
+// decodeHealthResponse reads HealthCheckResponse{status: 1}, skipping
+// any other fields. A missing status field is the zero value, UNKNOWN.
+func decodeHealthResponse(msg []byte) string {
+	var status uint64
+	for len(msg) > 0 {
+		tag, n := binary.Uvarint(msg)
+		if n <= 0 {
+			break
+		}
+		msg = msg[n:]
+
+		switch tag & 0x7 {
+		case 0: // varint
+			value, n := binary.Uvarint(msg)
+			if n <= 0 {
+				return grpcServingStatus[0]
+			}
+			msg = msg[n:]
+			if tag>>3 == 1 {
+				status = value
+			}
+		case 2: // length-delimited
+			size, n := binary.Uvarint(msg)
+			if n <= 0 || uint64(len(msg)-n) < size {
+				return grpcServingStatus[0]
+			}
+			msg = msg[n+int(size):]
+		default:
+			return grpcServingStatus[0]
+		}
+	}
+
+	if name, ok := grpcServingStatus[status]; ok {
+		return name
+	}
+	return fmt.Sprintf("STATUS_%d", status)
+}
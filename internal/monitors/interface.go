@@ -11,6 +11,15 @@ const (
 	TypeREST    MonitorType = "rest"
 	TypeGRPC    MonitorType = "grpc"
 	TypeQuality MonitorType = "quality"
+	TypeTLS     MonitorType = "cert"
+	TypeKafka   MonitorType = "kafka"
+	TypeTCP     MonitorType = "tcp"
+	TypeGRPCWeb MonitorType = "grpc-web"
+	TypeK8s     MonitorType = "k8s"
+	TypeExec    MonitorType = "exec"
+	TypeMetric  MonitorType = "metric"
+	TypeLog     MonitorType = "log"
+	TypeSQL     MonitorType = "sql"
 )
 
 type Status string
@@ -25,15 +34,36 @@ const (
 type Monitor interface {
 	Name() string
 	Type() MonitorType
+	Info() Info
 	Check(ctx context.Context) (*Result, error)
 }
 
+// Info describes a configured monitor independent of any check result.
+type Info struct {
+	Name    string        `json:"name"`
+	Type    MonitorType   `json:"type"`
+	Target  string        `json:"target"`
+	Timeout time.Duration `json:"timeout"`
+}
+
 type Result struct {
-	Name      string                 `json:"name"`
-	Type      MonitorType            `json:"type"`
-	Status    Status                 `json:"status"`
-	Message   string                 `json:"message"`
-	Metadata  map[string]interface{} `json:"metadata,omitempty"`
-	Timestamp time.Time              `json:"timestamp"`
-	Duration  time.Duration          `json:"duration"`
+	Name        string                 `json:"name"`
+	Type        MonitorType            `json:"type"`
+	Status      Status                 `json:"status"`
+	Message     string                 `json:"message"`
+	FailureKind FailureKind            `json:"failure_kind,omitempty"`
+	Retries     int                    `json:"retries,omitempty"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+	Labels      map[string]string      `json:"labels,omitempty"`
+	Timestamp   time.Time              `json:"timestamp"`
+	Duration    time.Duration          `json:"duration"`
+
+	// LastSuccess, LastFailure and Streak summarize the stored history;
+	// they are filled in when results are read, not by monitors
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	LastFailure *time.Time `json:"last_failure,omitempty"`
+	Streak      int        `json:"streak,omitempty"`
+
+	// Acked marks a WARN or FAIL someone acknowledged; it no longer notifies
+	Acked bool `json:"acked,omitempty"`
 }
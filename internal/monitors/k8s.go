@@ -0,0 +1,188 @@
+package monitors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/config"
+)
+
+// K8sMonitor reports ready versus desired replicas for a deployment, or
+// ready versus total pods matching a label selector.
+type K8sMonitor struct {
+	name       string
+	kubeconfig string
+	namespace  string
+	selector   string
+	deployment string
+	timeout    time.Duration
+}
+
+func NewK8sMonitor(cfg config.ServiceConfig) *K8sMonitor {
+	return &K8sMonitor{
+		name:       cfg.Name,
+		kubeconfig: kubeconfigPath(cfg.Kubeconfig),
+		namespace:  cfg.Namespace,
+		selector:   cfg.Selector,
+		deployment: cfg.Deployment,
+		timeout:    cfg.Timeout,
+	}
+}
+
+func (m *K8sMonitor) Name() string {
+	return m.name
+}
+
+func (m *K8sMonitor) Type() MonitorType {
+	return TypeK8s
+}
+
+func (m *K8sMonitor) Info() Info {
+	return Info{Name: m.name, Type: TypeK8s, Target: m.target(m.namespace), Timeout: m.timeout}
+}
+
+func (m *K8sMonitor) target(namespace string) string {
+	if namespace == "" {
+		namespace = "<context namespace>"
+	}
+	if m.deployment != "" {
+		return namespace + "/deployment/" + m.deployment
+	}
+	return namespace + "/pods?" + m.selector
+}
+
+func (m *K8sMonitor) Check(ctx context.Context) (*Result, error) {
+	start := time.Now()
+
+	checkCtx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	result := &Result{
+		Name:     m.name,
+		Type:     TypeK8s,
+		Metadata: map[string]interface{}{"kubeconfig": m.kubeconfig},
+	}
+
+	ready, desired, err := m.replicas(checkCtx, result)
+	result.Duration = time.Since(start)
+	result.Timestamp = time.Now()
+	if err != nil {
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("Kubernetes query failed: %v", err)
+		result.FailureKind = failureKindOf(err, FailureStatus)
+		return result, nil
+	}
+
+	result.Metadata["ready"] = ready
+	result.Metadata["desired"] = desired
+	if ready < desired {
+		result.FailureKind = FailureStatus
+	}
+	switch {
+	case ready == 0:
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("0/%d ready", desired)
+	case ready < desired:
+		result.Status = StatusWarn
+		result.Message = fmt.Sprintf("%d/%d ready", ready, desired)
+	default:
+		result.Status = StatusOK
+		result.Message = fmt.Sprintf("%d/%d ready", ready, desired)
+	}
+	return result, nil
+}
+
+func (m *K8sMonitor) replicas(ctx context.Context, result *Result) (int, int, error) {
+	kube, err := loadKubeClient(m.kubeconfig)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	namespace := m.namespace
+	if namespace == "" {
+		namespace = kube.namespace
+	}
+	if namespace == "" {
+		namespace = "default"
+	}
+	result.Metadata["target"] = m.target(namespace)
+
+	if m.deployment != "" {
+		return kube.deploymentReplicas(ctx, namespace, m.deployment)
+	}
+	return kube.readyPods(ctx, namespace, m.selector)
+}
+
+func (k *kubeClient) deploymentReplicas(ctx context.Context, namespace, name string) (int, int, error) {
+	var deployment struct {
+		Spec struct {
+			Replicas *int `json:"replicas"`
+		} `json:"spec"`
+		Status struct {
+			ReadyReplicas int `json:"readyReplicas"`
+		} `json:"status"`
+	}
+	path := fmt.Sprintf("/apis/apps/v1/namespaces/%s/deployments/%s", url.PathEscape(namespace), url.PathEscape(name))
+	if err := k.get(ctx, path, &deployment); err != nil {
+		return 0, 0, err
+	}
+
+	desired := 1
+	if deployment.Spec.Replicas != nil {
+		desired = *deployment.Spec.Replicas
+	}
+	return deployment.Status.ReadyReplicas, desired, nil
+}
+
+func (k *kubeClient) readyPods(ctx context.Context, namespace, selector string) (int, int, error) {
+	var pods struct {
+		Items []struct {
+			Status struct {
+				Conditions []struct {
+					Type   string `json:"type"`
+					Status string `json:"status"`
+				} `json:"conditions"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	path := fmt.Sprintf("/api/v1/namespaces/%s/pods?labelSelector=%s", url.PathEscape(namespace), url.QueryEscape(selector))
+	if err := k.get(ctx, path, &pods); err != nil {
+		return 0, 0, err
+	}
+
+	ready := 0
+	for _, pod := range pods.Items {
+		for _, cond := range pod.Status.Conditions {
+			if cond.Type == "Ready" && cond.Status == "True" {
+				ready++
+			}
+		}
+	}
+	return ready, len(pods.Items), nil
+}
+
+func (k *kubeClient) get(ctx context.Context, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", k.server+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if k.token != "" {
+		req.Header.Set("Authorization", "Bearer "+k.token)
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API server returned HTTP %d for %s", resp.StatusCode, path)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,344 @@
+package monitors
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/config"
+)
+
+// Kafka protocol constants for the small subset spoken here
+const (
+	kafkaAPIMetadata      int16 = 3
+	kafkaAPISaslHandshake int16 = 17
+	kafkaClientID               = "watch-now"
+)
+
+// KafkaMonitor checks broker reachability and, optionally, a topic's
+// presence and partition count using the Metadata API directly.
+type KafkaMonitor struct {
+	name       string
+	brokers    []string
+	topic      string
+	partitions int
+	username   string
+	password   config.Secret
+	timeout    time.Duration
+}
+
+func NewKafkaMonitor(cfg config.ServiceConfig) *KafkaMonitor {
+	brokers := cfg.Brokers
+	if len(brokers) == 0 && cfg.URL != "" {
+		brokers = []string{cfg.URL}
+	}
+
+	return &KafkaMonitor{
+		name:       cfg.Name,
+		brokers:    brokers,
+		topic:      cfg.Topic,
+		partitions: cfg.Partitions,
+		username:   cfg.Username,
+		password:   cfg.Password,
+		timeout:    cfg.Timeout,
+	}
+}
+
+func (m *KafkaMonitor) Name() string {
+	return m.name
+}
+
+func (m *KafkaMonitor) Type() MonitorType {
+	return TypeKafka
+}
+
+func (m *KafkaMonitor) Info() Info {
+	target := strings.Join(m.brokers, ",")
+	if m.topic != "" {
+		target += " topic=" + m.topic
+	}
+	return Info{Name: m.name, Type: TypeKafka, Target: target, Timeout: m.timeout}
+}
+
+type kafkaMetadata struct {
+	brokers   int
+	topicErr  int16
+	found     bool
+	leaders   int
+	partCount int
+}
+
+func (m *KafkaMonitor) Check(ctx context.Context) (*Result, error) {
+	start := time.Now()
+
+	checkCtx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	result := &Result{
+		Name:     m.name,
+		Type:     TypeKafka,
+		Metadata: map[string]interface{}{"address": strings.Join(m.brokers, ",")},
+	}
+
+	meta, broker, err := m.fetchMetadata(checkCtx)
+	result.Duration = time.Since(start)
+	result.Timestamp = time.Now()
+	if err != nil {
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("No broker reachable: %v", err)
+		result.FailureKind = failureKindOf(err, FailureConnection)
+		return result, nil
+	}
+
+	result.Metadata["broker"] = broker
+	result.Metadata["cluster_brokers"] = meta.brokers
+	m.evaluate(result, meta)
+	return result, nil
+}
+
+func (m *KafkaMonitor) evaluate(result *Result, meta *kafkaMetadata) {
+	if m.topic == "" {
+		result.Status = StatusOK
+		result.Message = fmt.Sprintf("%d brokers in %v", meta.brokers, result.Duration.Round(time.Millisecond))
+		return
+	}
+
+	result.Metadata["topic"] = m.topic
+	result.Metadata["partitions"] = meta.partCount
+
+	switch {
+	case !meta.found || meta.topicErr == 3:
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("Topic %s does not exist", m.topic)
+		result.FailureKind = FailureNotFound
+	case meta.topicErr != 0:
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("Topic %s error code %d", m.topic, meta.topicErr)
+		result.FailureKind = FailureStatus
+	case m.partitions > 0 && meta.partCount != m.partitions:
+		result.Status = StatusWarn
+		result.Message = fmt.Sprintf("Topic %s has %d partitions, expected %d", m.topic, meta.partCount, m.partitions)
+		result.FailureKind = FailureAssertion
+	case meta.leaders < meta.partCount:
+		result.Status = StatusWarn
+		result.Message = fmt.Sprintf("Topic %s: %d of %d partitions have no leader", m.topic, meta.partCount-meta.leaders, meta.partCount)
+		result.FailureKind = FailureStatus
+	default:
+		result.Status = StatusOK
+		result.Message = fmt.Sprintf("Topic %s has %d partitions across %d brokers", m.topic, meta.partCount, meta.brokers)
+	}
+}
+
+// fetchMetadata tries each configured broker until one answers
+func (m *KafkaMonitor) fetchMetadata(ctx context.Context) (*kafkaMetadata, string, error) {
+	if len(m.brokers) == 0 {
+		return nil, "", errors.New("no brokers configured")
+	}
+
+	var lastErr error
+	for _, broker := range m.brokers {
+		meta, err := m.queryBroker(ctx, broker)
+		if err == nil {
+			return meta, broker, nil
+		}
+		lastErr = fmt.Errorf("%s: %w", broker, err)
+	}
+	return nil, "", lastErr
+}
+
+func (m *KafkaMonitor) queryBroker(ctx context.Context, broker string) (*kafkaMetadata, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", broker)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	kc := &kafkaConn{conn: conn, reader: bufio.NewReader(conn)}
+	if m.username != "" {
+		if err := kc.saslPlain(m.username, m.password.Value()); err != nil {
+			return nil, fmt.Errorf("authentication failed: %w", err)
+		}
+	}
+
+	return kc.metadata(m.topic)
+}
+
+type kafkaConn struct {
+	conn          net.Conn
+	reader        *bufio.Reader
+	correlationID int32
+}
+
+func (c *kafkaConn) request(apiKey int16, body []byte) (*kafkaReader, error) {
+	c.correlationID++
+
+	var buf []byte
+	buf = binary.BigEndian.AppendUint16(buf, uint16(apiKey))
+	buf = binary.BigEndian.AppendUint16(buf, 0) // api version
+	buf = binary.BigEndian.AppendUint32(buf, uint32(c.correlationID))
+	buf = appendKafkaString(buf, kafkaClientID)
+	buf = append(buf, body...)
+
+	if err := c.writeFrame(buf); err != nil {
+		return nil, err
+	}
+
+	frame, err := c.readFrame()
+	if err != nil {
+		return nil, err
+	}
+	r := &kafkaReader{buf: frame}
+	if id := r.int32(); id != c.correlationID {
+		return nil, fmt.Errorf("unexpected correlation id %d", id)
+	}
+	return r, nil
+}
+
+func (c *kafkaConn) writeFrame(payload []byte) error {
+	frame := binary.BigEndian.AppendUint32(nil, uint32(len(payload)))
+	_, err := c.conn.Write(append(frame, payload...))
+	return err
+}
+
+func (c *kafkaConn) readFrame() ([]byte, error) {
+	var size int32
+	if err := binary.Read(c.reader, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+	if size < 0 || size > 16<<20 {
+		return nil, fmt.Errorf("invalid response size %d", size)
+	}
+	frame := make([]byte, size)
+	_, err := io.ReadFull(c.reader, frame)
+	return frame, err
+}
+
+// saslPlain performs the v0 SaslHandshake followed by a raw PLAIN token
+func (c *kafkaConn) saslPlain(username, password string) error {
+	r, err := c.request(kafkaAPISaslHandshake, appendKafkaString(nil, "PLAIN"))
+	if err != nil {
+		return err
+	}
+	if code := r.int16(); code != 0 {
+		return fmt.Errorf("PLAIN mechanism not enabled (error code %d)", code)
+	}
+
+	if err := c.writeFrame([]byte("\x00" + username + "\x00" + password)); err != nil {
+		return err
+	}
+	_, err = c.readFrame()
+	return err
+}
+
+// metadata lists every topic and looks for ours client-side. Naming the
+// topic in a v0 request would make brokers with auto.create.topics.enable
+// (the default) create it, so a missing topic could never be reported.
+func (c *kafkaConn) metadata(topic string) (*kafkaMetadata, error) {
+	body := binary.BigEndian.AppendUint32(nil, 0) // empty array: all topics
+
+	r, err := c.request(kafkaAPIMetadata, body)
+	if err != nil {
+		return nil, err
+	}
+	return parseKafkaMetadata(r, topic)
+}
+
+func parseKafkaMetadata(r *kafkaReader, topic string) (*kafkaMetadata, error) {
+	meta := &kafkaMetadata{brokers: int(r.int32())}
+	for i := 0; i < meta.brokers && r.err == nil; i++ {
+		r.int32()  // node id
+		r.string() // host
+		r.int32()  // port
+	}
+
+	topics := int(r.int32())
+	for i := 0; i < topics && r.err == nil; i++ {
+		errCode := r.int16()
+		name := r.string()
+		partitions := int(r.int32())
+		leaders := 0
+		for p := 0; p < partitions && r.err == nil; p++ {
+			r.int16() // partition error
+			r.int32() // partition id
+			if r.int32() >= 0 {
+				leaders++
+			}
+			r.skipInt32Array() // replicas
+			r.skipInt32Array() // isr
+		}
+		if name == topic {
+			meta.found = true
+			meta.topicErr = errCode
+			meta.partCount = partitions
+			meta.leaders = leaders
+		}
+	}
+
+	if r.err != nil {
+		return nil, fmt.Errorf("malformed metadata response: %w", r.err)
+	}
+	return meta, nil
+}
+
+func appendKafkaString(buf []byte, s string) []byte {
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(s)))
+	return append(buf, s...)
+}
+
+// kafkaReader decodes big-endian protocol fields, remembering the first error
+type kafkaReader struct {
+	buf []byte
+	err error
+}
+
+func (r *kafkaReader) take(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.buf) < n {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *kafkaReader) int16() int16 {
+	if b := r.take(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (r *kafkaReader) int32() int32 {
+	if b := r.take(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (r *kafkaReader) string() string {
+	n := r.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(r.take(int(n)))
+}
+
+func (r *kafkaReader) skipInt32Array() {
+	if n := r.int32(); n > 0 {
+		r.take(int(n) * 4)
+	}
+}
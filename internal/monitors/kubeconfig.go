@@ -0,0 +1,185 @@
+package monitors
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// kubeconfig holds the subset of a kubeconfig file needed to reach the
+// API server of the current context.
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster   string `yaml:"cluster"`
+			User      string `yaml:"user"`
+			Namespace string `yaml:"namespace"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			ClientCertificate     string `yaml:"client-certificate"`
+			ClientCertificateData string `yaml:"client-certificate-data"`
+			ClientKey             string `yaml:"client-key"`
+			ClientKeyData         string `yaml:"client-key-data"`
+			Token                 string `yaml:"token"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+}
+
+// kubeClient is an authenticated HTTP client for one API server
+type kubeClient struct {
+	server    string
+	token     string
+	namespace string
+	client    *http.Client
+}
+
+// kubeconfigPath resolves an explicit path, then $KUBECONFIG, then ~/.kube/config
+func kubeconfigPath(path string) string {
+	if path == "" {
+		path = strings.Split(os.Getenv("KUBECONFIG"), string(os.PathListSeparator))[0]
+	}
+	if path == "" {
+		home, _ := os.UserHomeDir()
+		path = filepath.Join(home, ".kube", "config")
+	}
+	if strings.HasPrefix(path, "~/") {
+		home, _ := os.UserHomeDir()
+		path = filepath.Join(home, path[2:])
+	}
+	return path
+}
+
+func loadKubeClient(path string) (*kubeClient, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading kubeconfig: %w", err)
+	}
+	var kc kubeconfig
+	if err := yaml.Unmarshal(data, &kc); err != nil {
+		return nil, fmt.Errorf("parsing kubeconfig: %w", err)
+	}
+	return kc.client(filepath.Dir(path))
+}
+
+func (kc *kubeconfig) client(baseDir string) (*kubeClient, error) {
+	var clusterName, userName, namespace string
+	for _, c := range kc.Contexts {
+		if c.Name == kc.CurrentContext {
+			clusterName, userName, namespace = c.Context.Cluster, c.Context.User, c.Context.Namespace
+		}
+	}
+	if clusterName == "" {
+		return nil, fmt.Errorf("kubeconfig: context %q not found", kc.CurrentContext)
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	server, err := kc.applyCluster(clusterName, baseDir, tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	token, err := kc.applyUser(userName, baseDir, tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &kubeClient{
+		server:    server,
+		token:     token,
+		namespace: namespace,
+		client:    &http.Client{Transport: transport},
+	}, nil
+}
+
+// applyCluster sets the cluster's CA on tlsConfig and returns its server URL
+func (kc *kubeconfig) applyCluster(name, baseDir string, tlsConfig *tls.Config) (string, error) {
+	for _, c := range kc.Clusters {
+		if c.Name != name {
+			continue
+		}
+		tlsConfig.InsecureSkipVerify = c.Cluster.InsecureSkipTLSVerify // #nosec G402 -- honours the kubeconfig
+		ca, err := kubeData(c.Cluster.CertificateAuthorityData, c.Cluster.CertificateAuthority, baseDir)
+		if err != nil {
+			return "", err
+		}
+		if ca != nil {
+			tlsConfig.RootCAs = x509.NewCertPool()
+			tlsConfig.RootCAs.AppendCertsFromPEM(ca)
+		}
+		return strings.TrimSuffix(c.Cluster.Server, "/"), nil
+	}
+	return "", fmt.Errorf("kubeconfig: cluster %q not found", name)
+}
+
+// applyUser sets the user's client certificate on tlsConfig and returns
+// its bearer token, if any
+func (kc *kubeconfig) applyUser(name, baseDir string, tlsConfig *tls.Config) (string, error) {
+	for _, u := range kc.Users {
+		if u.Name != name {
+			continue
+		}
+		cert, err := kubeData(u.User.ClientCertificateData, u.User.ClientCertificate, baseDir)
+		if err != nil {
+			return "", err
+		}
+		key, err := kubeData(u.User.ClientKeyData, u.User.ClientKey, baseDir)
+		if err != nil {
+			return "", err
+		}
+		if cert != nil && key != nil {
+			pair, err := tls.X509KeyPair(cert, key)
+			if err != nil {
+				return "", fmt.Errorf("kubeconfig: client certificate: %w", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{pair}
+		}
+		return u.User.Token, nil
+	}
+	return "", nil
+}
+
+// kubeData returns inline base64 data, or the contents of a file path
+// relative to the kubeconfig, or nil when neither is set.
+func kubeData(inline, path, baseDir string) ([]byte, error) {
+	if inline != "" {
+		data, err := base64.StdEncoding.DecodeString(inline)
+		if err != nil {
+			return nil, errors.New("kubeconfig: invalid base64 data")
+		}
+		return data, nil
+	}
+	if path == "" {
+		return nil, nil
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("kubeconfig: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,159 @@
+package monitors
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/config"
+)
+
+// maxLogLine bounds how much of a matching line is reported
+const maxLogLine = 200
+
+// LogMonitor follows a log file. A file that stops growing means its
+// writer is stuck, and lines matching the error pattern mean it is
+// failing. Only lines appended since the previous check are scanned, so an
+// old error stops counting once it has been reported.
+type LogMonitor struct {
+	name     string
+	path     string
+	timeout  time.Duration
+	warnIdle time.Duration
+	maxIdle  time.Duration
+	pattern  *regexp.Regexp
+
+	// offset is how far the file has been scanned; file identifies it so
+	// a rotated or truncated log is read from the start
+	mu     sync.Mutex
+	offset int64
+	file   os.FileInfo
+}
+
+func NewLogMonitor(cfg config.ServiceConfig) *LogMonitor {
+	m := &LogMonitor{
+		name:     cfg.Name,
+		path:     cfg.Path,
+		timeout:  cfg.Timeout,
+		warnIdle: cfg.WarnIdle,
+		maxIdle:  cfg.MaxIdle,
+		offset:   -1,
+	}
+	if cfg.ErrorPattern != "" {
+		// Validated at config load
+		m.pattern = regexp.MustCompile(cfg.ErrorPattern)
+	}
+	return m
+}
+
+func (m *LogMonitor) Name() string {
+	return m.name
+}
+
+func (m *LogMonitor) Type() MonitorType {
+	return TypeLog
+}
+
+func (m *LogMonitor) Info() Info {
+	return Info{Name: m.name, Type: TypeLog, Target: m.path, Timeout: m.timeout}
+}
+
+func (m *LogMonitor) Check(ctx context.Context) (*Result, error) {
+	start := time.Now()
+	result := &Result{
+		Name:     m.name,
+		Type:     TypeLog,
+		Metadata: map[string]interface{}{"path": m.path},
+	}
+
+	matches, lastMatch, info, err := m.scan()
+	result.Timestamp = time.Now()
+	result.Duration = time.Since(start)
+	if err != nil {
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("Cannot read log: %v", err)
+		result.FailureKind = failureKindOf(err, FailureCommand)
+		return result, nil
+	}
+
+	idle := time.Since(info.ModTime())
+	result.Metadata["last_write"] = info.ModTime().Format(time.RFC3339)
+	result.Metadata["idle"] = idle.Round(time.Second).String()
+	if m.pattern != nil {
+		result.Metadata["matches"] = matches
+	}
+
+	switch {
+	case matches > 0:
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("%d new line(s) match %q, last: %s", matches, m.pattern, lastMatch)
+		result.FailureKind = FailureAssertion
+		result.Metadata["last_match"] = lastMatch
+	case m.maxIdle > 0 && idle > m.maxIdle:
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("Nothing written for %v (limit %v)", idle.Round(time.Second), m.maxIdle)
+		result.FailureKind = FailureTimeout
+	case m.warnIdle > 0 && idle > m.warnIdle:
+		result.Status = StatusWarn
+		result.Message = fmt.Sprintf("Nothing written for %v", idle.Round(time.Second))
+		result.FailureKind = FailureTimeout
+	default:
+		result.Status = StatusOK
+		result.Message = fmt.Sprintf("Last write %v ago", idle.Round(time.Second))
+	}
+	return result, nil
+}
+
+// scan reads what was appended since the last check and counts the lines
+// matching the pattern. The first check only notes where the file ends.
+func (m *LogMonitor) scan() (matches int, lastMatch string, info os.FileInfo, err error) {
+	f, err := os.Open(m.path)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	defer f.Close()
+	if info, err = f.Stat(); err != nil {
+		return 0, "", nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	from := m.offset
+	if m.file != nil && (!os.SameFile(m.file, info) || info.Size() < m.offset) {
+		from = 0
+	}
+	m.file = info
+	m.offset = info.Size()
+	if from < 0 || m.pattern == nil || from >= info.Size() {
+		return 0, "", info, nil
+	}
+
+	// A burst bigger than the read limit is scanned from its tail
+	if info.Size()-from > maxBodyBytes {
+		from = info.Size() - maxBodyBytes
+	}
+	data := make([]byte, info.Size()-from)
+	if _, err := f.ReadAt(data, from); err != nil && err != io.EOF {
+		return 0, "", nil, err
+	}
+
+	lines := bufio.NewScanner(bytes.NewReader(data))
+	lines.Buffer(make([]byte, 64<<10), maxBodyBytes)
+	for lines.Scan() {
+		if m.pattern.Match(lines.Bytes()) {
+			matches++
+			lastMatch = lines.Text()
+			if len(lastMatch) > maxLogLine {
+				lastMatch = lastMatch[:maxLogLine] + "..."
+			}
+		}
+	}
+	return matches, lastMatch, info, nil
+}
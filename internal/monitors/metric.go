@@ -0,0 +1,167 @@
+package monitors
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/config"
+)
+
+// MetricMonitor runs a command that prints a number, such as
+// `wc -l logs/errors.log`, and judges the number against thresholds. The
+// value, not the exit code, decides the status, and it is kept in
+// metadata.value so /api/trends can chart it.
+type MetricMonitor struct {
+	name    string
+	command string
+	args    []string
+	timeout time.Duration
+	thresholds
+}
+
+func NewMetricMonitor(cfg config.ServiceConfig) *MetricMonitor {
+	return &MetricMonitor{
+		name:       cfg.Name,
+		command:    cfg.Command,
+		args:       cfg.Args,
+		timeout:    cfg.Timeout,
+		thresholds: thresholdsOf(cfg),
+	}
+}
+
+func (m *MetricMonitor) Name() string {
+	return m.name
+}
+
+func (m *MetricMonitor) Type() MonitorType {
+	return TypeMetric
+}
+
+func (m *MetricMonitor) Info() Info {
+	target := strings.TrimSpace(m.command + " " + strings.Join(m.args, " "))
+	return Info{Name: m.name, Type: TypeMetric, Target: target, Timeout: m.timeout}
+}
+
+func (m *MetricMonitor) Check(ctx context.Context) (*Result, error) {
+	start := time.Now()
+
+	checkCtx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(checkCtx, m.command, m.args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Don't let a child that inherited stdout hold the check past its timeout
+	killProcessGroup(cmd)
+	cmd.WaitDelay = time.Second
+	runErr := cmd.Run()
+
+	result := &Result{
+		Name:      m.name,
+		Type:      TypeMetric,
+		Timestamp: time.Now(),
+		Duration:  time.Since(start),
+		Metadata:  make(map[string]interface{}),
+	}
+
+	if checkCtx.Err() == context.DeadlineExceeded {
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("Command timed out after %v", m.timeout)
+		result.FailureKind = FailureTimeout
+		return result, nil
+	}
+
+	value, err := parseMetric(stdout.String())
+	if err != nil {
+		// No number means no verdict on the metric itself, so WARN
+		result.Status = StatusWarn
+		result.Message = fmt.Sprintf("No value: %v", err)
+		if runErr != nil {
+			result.Status = StatusFail
+			result.Message = fmt.Sprintf("Command failed (%v) without a value: %v", runErr, err)
+		}
+		result.FailureKind = failureKindOf(runErr, FailureCommand)
+		result.Metadata["output"] = truncateOutput(stdout.String())
+		if stderr.Len() > 0 {
+			result.Metadata["stderr"] = truncateOutput(stderr.String())
+		}
+		return result, nil
+	}
+
+	result.Metadata["value"] = value
+	m.evaluate(result, value)
+	return result, nil
+}
+
+// parseMetric reads the first field of the output, so `wc -l file` output
+// like "42 file" yields 42
+func parseMetric(output string) (float64, error) {
+	fields := strings.Fields(output)
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("no output")
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("output %q is not a number", truncateMetric(fields[0]))
+	}
+	// NaN and infinities can't be compared, charted or encoded as JSON
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("output %q is not a finite number", truncateMetric(fields[0]))
+	}
+	return value, nil
+}
+
+func truncateMetric(s string) string {
+	if len(s) > 40 {
+		return s[:40] + "..."
+	}
+	return s
+}
+
+// thresholds are the limits a numeric value is judged against; unset ones
+// are not checked
+type thresholds struct {
+	warnAbove, failAbove *float64
+	warnBelow, failBelow *float64
+}
+
+func thresholdsOf(cfg config.ServiceConfig) thresholds {
+	return thresholds{
+		warnAbove: cfg.WarnAbove,
+		failAbove: cfg.FailAbove,
+		warnBelow: cfg.WarnBelow,
+		failBelow: cfg.FailBelow,
+	}
+}
+
+// evaluate applies the thresholds, fail before warn
+func (t thresholds) evaluate(result *Result, value float64) {
+	formatted := strconv.FormatFloat(value, 'g', -1, 64)
+	check := func(status Status, limit *float64, above bool) bool {
+		if limit == nil || (above && value <= *limit) || (!above && value >= *limit) {
+			return false
+		}
+		side := "below"
+		if above {
+			side = "above"
+		}
+		result.Status = status
+		result.Message = fmt.Sprintf("%s (%s %s %s)", formatted, status, side, strconv.FormatFloat(*limit, 'g', -1, 64))
+		result.FailureKind = FailureAssertion
+		return true
+	}
+
+	if check(StatusFail, t.failAbove, true) || check(StatusFail, t.failBelow, false) ||
+		check(StatusWarn, t.warnAbove, true) || check(StatusWarn, t.warnBelow, false) {
+		return
+	}
+	result.Status = StatusOK
+	result.Message = formatted
+}
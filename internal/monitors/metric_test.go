@@ -0,0 +1,138 @@
+package monitors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/config"
+)
+
+// TestParseMetric reads the first field of the output as a finite number
+func TestParseMetric(t *testing.T) {
+	tests := []struct {
+		output  string
+		want    float64
+		wantErr string
+	}{
+		{output: "42", want: 42},
+		{output: "  17 logs/errors.log\n", want: 17},
+		{output: "-0.5\nmore lines\n", want: -0.5},
+		{output: "1e3", want: 1000},
+		{output: "", wantErr: "no output"},
+		{output: " \n\t", wantErr: "no output"},
+		{output: "errors: 3", wantErr: `output "errors:" is not a number`},
+		{output: "1e400", wantErr: `output "1e400" is not a number`},
+		{output: "NaN", wantErr: `output "NaN" is not a finite number`},
+		{output: "nan 0", wantErr: `output "nan" is not a finite number`},
+		{output: "+Inf", wantErr: `output "+Inf" is not a finite number`},
+		{output: "-infinity", wantErr: `output "-infinity" is not a finite number`},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Quote(tt.output), func(t *testing.T) {
+			got, err := parseMetric(tt.output)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("parseMetric error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("parseMetric = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+// TestMetricHelperProcess is not a real test: TestMetricCheck runs the
+// test binary through it as a command that prints its first argument,
+// sleeps for its second and exits with its third.
+func TestMetricHelperProcess(t *testing.T) {
+	if os.Getenv("WATCH_NOW_METRIC_HELPER") != "1" {
+		return
+	}
+	args := os.Args
+	for len(args) > 0 && args[0] != "--" {
+		args = args[1:]
+	}
+	fmt.Print(args[1])
+	sleep, _ := time.ParseDuration(args[2])
+	time.Sleep(sleep)
+	code, _ := strconv.Atoi(args[3])
+	os.Exit(code)
+}
+
+// TestMetricCheck judges the printed value and keeps results JSON-safe
+// when there is none
+func TestMetricCheck(t *testing.T) {
+	t.Setenv("WATCH_NOW_METRIC_HELPER", "1")
+	ten, hundred := 10.0, 100.0
+
+	tests := []struct {
+		name        string
+		output      string
+		sleep       time.Duration
+		exit        int
+		timeout     time.Duration
+		wantStatus  Status
+		wantKind    FailureKind
+		wantMessage string
+		wantValue   interface{}
+	}{
+		{name: "ok", output: "3 lines\n", wantStatus: StatusOK, wantMessage: "3", wantValue: 3.0},
+		{name: "warn", output: "12", wantStatus: StatusWarn, wantKind: FailureAssertion, wantMessage: "12 (warn above 10)", wantValue: 12.0},
+		{name: "fail", output: "250", wantStatus: StatusFail, wantKind: FailureAssertion, wantMessage: "250 (fail above 100)", wantValue: 250.0},
+		{name: "value decides despite exit code", output: "5", exit: 1, wantStatus: StatusOK, wantMessage: "5", wantValue: 5.0},
+		{name: "no number", output: "n/a", wantStatus: StatusWarn, wantKind: FailureCommand, wantMessage: `No value: output "n/a" is not a number`},
+		{name: "NaN", output: "NaN", wantStatus: StatusWarn, wantKind: FailureCommand, wantMessage: `No value: output "NaN" is not a finite number`},
+		{name: "infinity", output: "-Inf", wantStatus: StatusWarn, wantKind: FailureCommand, wantMessage: `No value: output "-Inf" is not a finite number`},
+		{name: "failed without value", output: "", exit: 2, wantStatus: StatusFail, wantKind: FailureCommand, wantMessage: "Command failed (exit status 2) without a value: no output"},
+		{name: "timeout", output: "1", sleep: time.Minute, timeout: 500 * time.Millisecond, wantStatus: StatusFail, wantKind: FailureTimeout, wantMessage: "Command timed out after 500ms"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Generous, as starting the test binary can be slow under -race
+			timeout := tt.timeout
+			if timeout == 0 {
+				timeout = 30 * time.Second
+			}
+			m := NewMetricMonitor(config.ServiceConfig{
+				Name:      "errors",
+				Type:      "metric",
+				Command:   os.Args[0],
+				Args:      []string{"-test.run=^TestMetricHelperProcess$", "--", tt.output, tt.sleep.String(), strconv.Itoa(tt.exit)},
+				Timeout:   timeout,
+				WarnAbove: &ten,
+				FailAbove: &hundred,
+			})
+
+			start := time.Now()
+			result, err := m.Check(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if elapsed := time.Since(start); elapsed > timeout+5*time.Second {
+				t.Errorf("check took %v with a %v timeout", elapsed, timeout)
+			}
+			if result.Status != tt.wantStatus || result.FailureKind != tt.wantKind {
+				t.Errorf("status = %s/%q (%s), want %s/%q", result.Status, result.FailureKind, result.Message, tt.wantStatus, tt.wantKind)
+			}
+			if !strings.HasPrefix(result.Message, tt.wantMessage) {
+				t.Errorf("message = %q, want prefix %q", result.Message, tt.wantMessage)
+			}
+			if got := result.Metadata["value"]; got != tt.wantValue {
+				t.Errorf("metadata.value = %v, want %v", got, tt.wantValue)
+			}
+			if _, err := json.Marshal(result); err != nil {
+				t.Errorf("result does not encode: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,380 @@
+package monitors
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// PostgreSQL frontend/backend protocol constants for the small subset
+// spoken here: startup, password/MD5/SCRAM-SHA-256 authentication and the
+// simple query protocol
+const (
+	postgresProtocolVersion = 3 << 16
+	postgresSSLRequestCode  = 80877103
+
+	postgresAuthOK        = 0
+	postgresAuthCleartext = 3
+	postgresAuthMD5       = 5
+	postgresAuthSASL      = 10
+	postgresAuthSASLCont  = 11
+	postgresAuthSASLFinal = 12
+)
+
+type postgresOptions struct {
+	host     string
+	user     string
+	password string
+	database string
+	sslmode  string
+}
+
+// postgresError is an ErrorResponse from the server; during says whether
+// it came while connecting ("startup") or from the query ("query")
+type postgresError struct {
+	during  string
+	code    string
+	message string
+}
+
+func (e *postgresError) Error() string {
+	return fmt.Sprintf("%s (SQLSTATE %s)", e.message, e.code)
+}
+
+type postgresConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// startPostgres negotiates TLS per sslmode, authenticates and waits until
+// the server is ready for a query
+func startPostgres(conn net.Conn, opts postgresOptions) (*postgresConn, error) {
+	conn, err := postgresTLS(conn, opts)
+	if err != nil {
+		return nil, err
+	}
+	c := &postgresConn{conn: conn, reader: bufio.NewReader(conn)}
+
+	startup := binary.BigEndian.AppendUint32(nil, postgresProtocolVersion)
+	for _, param := range [][2]string{{"user", opts.user}, {"database", opts.database}, {"application_name", "watch-now"}} {
+		startup = appendCString(appendCString(startup, param[0]), param[1])
+	}
+	startup = append(startup, 0)
+	if err := c.write(0, startup); err != nil {
+		return nil, err
+	}
+
+	for {
+		kind, payload, err := c.read()
+		if err != nil {
+			return nil, err
+		}
+		switch kind {
+		case 'R':
+			if err := c.authenticate(payload, opts); err != nil {
+				return nil, err
+			}
+		case 'E':
+			return nil, parsePostgresError("startup", payload)
+		case 'Z':
+			return c, nil
+		case 'S', 'K', 'N':
+			// Parameter status, cancellation key and notices don't matter here
+		default:
+			return nil, fmt.Errorf("unexpected message %q during startup", kind)
+		}
+	}
+}
+
+// postgresTLS upgrades the connection unless sslmode is disable. Like
+// libpq, prefer (the default) falls back to plaintext when the server
+// declines, and only verify-full checks the certificate.
+func postgresTLS(conn net.Conn, opts postgresOptions) (net.Conn, error) {
+	mode := opts.sslmode
+	if mode == "" {
+		mode = "prefer"
+	}
+	if mode == "disable" {
+		return conn, nil
+	}
+
+	request := binary.BigEndian.AppendUint32(nil, 8)
+	request = binary.BigEndian.AppendUint32(request, postgresSSLRequestCode)
+	if _, err := conn.Write(request); err != nil {
+		return nil, err
+	}
+	answer := make([]byte, 1)
+	if _, err := io.ReadFull(conn, answer); err != nil {
+		return nil, err
+	}
+	if answer[0] != 'S' {
+		if mode == "prefer" {
+			return conn, nil
+		}
+		return nil, errors.New("server does not support TLS")
+	}
+
+	tlsConn := tls.Client(conn, &tls.Config{
+		ServerName:         opts.host,
+		InsecureSkipVerify: mode != "verify-full", //nolint:gosec // sslmode asks for encryption only
+	})
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, fmt.Errorf("TLS handshake: %w", err)
+	}
+	return tlsConn, nil
+}
+
+func (c *postgresConn) authenticate(payload []byte, opts postgresOptions) error {
+	if len(payload) < 4 {
+		return errors.New("malformed authentication request")
+	}
+	switch code := binary.BigEndian.Uint32(payload); code {
+	case postgresAuthOK:
+		return nil
+	case postgresAuthCleartext:
+		return c.write('p', appendCString(nil, opts.password))
+	case postgresAuthMD5:
+		if len(payload) < 8 {
+			return errors.New("malformed MD5 authentication request")
+		}
+		inner := md5.Sum([]byte(opts.password + opts.user))
+		outer := md5.Sum(append([]byte(hex.EncodeToString(inner[:])), payload[4:8]...))
+		return c.write('p', appendCString(nil, "md5"+hex.EncodeToString(outer[:])))
+	case postgresAuthSASL:
+		for _, mechanism := range strings.Split(string(payload[4:]), "\x00") {
+			if mechanism == "SCRAM-SHA-256" {
+				return c.scram(opts.password)
+			}
+		}
+		return errors.New("server offers no supported SASL mechanism")
+	default:
+		return fmt.Errorf("unsupported authentication method %d", code)
+	}
+}
+
+// scram runs a SCRAM-SHA-256 exchange (RFC 5802, RFC 7677) without
+// channel binding
+func (c *postgresConn) scram(password string) error {
+	nonce := make([]byte, 18)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	clientNonce := base64.StdEncoding.EncodeToString(nonce)
+	// The server takes the user from the startup message, so it's left empty
+	clientFirstBare := "n=,r=" + clientNonce
+
+	initial := appendCString(nil, "SCRAM-SHA-256")
+	initial = binary.BigEndian.AppendUint32(initial, uint32(len("n,,"+clientFirstBare)))
+	initial = append(initial, "n,,"+clientFirstBare...)
+	if err := c.write('p', initial); err != nil {
+		return err
+	}
+
+	serverFirst, err := c.readSASL(postgresAuthSASLCont)
+	if err != nil {
+		return err
+	}
+	attrs := scramAttributes(serverFirst)
+	salt, err := base64.StdEncoding.DecodeString(attrs["s"])
+	iterations, iterErr := strconv.Atoi(attrs["i"])
+	if err != nil || iterErr != nil || iterations < 1 || !strings.HasPrefix(attrs["r"], clientNonce) {
+		return errors.New("malformed SCRAM challenge")
+	}
+
+	salted := pbkdf2SHA256([]byte(password), salt, iterations)
+	clientKey := hmacSHA256(salted, "Client Key")
+	storedKey := sha256.Sum256(clientKey)
+	clientFinal := "c=biws,r=" + attrs["r"]
+	authMessage := clientFirstBare + "," + serverFirst + "," + clientFinal
+	proof := hmacSHA256(storedKey[:], authMessage)
+	for i := range proof {
+		proof[i] ^= clientKey[i]
+	}
+	if err := c.write('p', []byte(clientFinal+",p="+base64.StdEncoding.EncodeToString(proof))); err != nil {
+		return err
+	}
+
+	serverFinal, err := c.readSASL(postgresAuthSASLFinal)
+	if err != nil {
+		return err
+	}
+	signature := hmacSHA256(hmacSHA256(salted, "Server Key"), authMessage)
+	if scramAttributes(serverFinal)["v"] != base64.StdEncoding.EncodeToString(signature) {
+		return errors.New("server SCRAM signature does not match")
+	}
+	return nil
+}
+
+// readSASL reads the next authentication message, which must have code want
+func (c *postgresConn) readSASL(want uint32) (string, error) {
+	kind, payload, err := c.read()
+	if err != nil {
+		return "", err
+	}
+	if kind == 'E' {
+		return "", parsePostgresError("startup", payload)
+	}
+	if kind != 'R' || len(payload) < 4 || binary.BigEndian.Uint32(payload) != want {
+		return "", errors.New("unexpected message during SCRAM authentication")
+	}
+	return string(payload[4:]), nil
+}
+
+func scramAttributes(message string) map[string]string {
+	attrs := make(map[string]string)
+	for _, field := range strings.Split(message, ",") {
+		if key, value, ok := strings.Cut(field, "="); ok {
+			attrs[key] = value
+		}
+	}
+	return attrs
+}
+
+func hmacSHA256(key []byte, message string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
+
+// pbkdf2SHA256 derives one SHA-256-sized key, all SCRAM-SHA-256 needs
+func pbkdf2SHA256(password, salt []byte, iterations int) []byte {
+	mac := hmac.New(sha256.New, password)
+	mac.Write(salt)
+	mac.Write([]byte{0, 0, 0, 1})
+	u := mac.Sum(nil)
+	key := append([]byte(nil), u...)
+	for i := 1; i < iterations; i++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(u[:0])
+		for j := range key {
+			key[j] ^= u[j]
+		}
+	}
+	return key
+}
+
+// queryValue runs query and returns the first column of its first row,
+// nil when that is NULL
+func (c *postgresConn) queryValue(query string) (*string, error) {
+	if err := c.write('Q', appendCString(nil, query)); err != nil {
+		return nil, err
+	}
+
+	var cell *string
+	var found bool
+	var queryErr error
+	for {
+		kind, payload, err := c.read()
+		if err != nil {
+			return nil, err
+		}
+		switch kind {
+		case 'D':
+			if found {
+				continue
+			}
+			value, ok, err := firstColumn(payload)
+			if err != nil {
+				return nil, err
+			}
+			cell, found = value, ok
+		case 'E':
+			queryErr = parsePostgresError("query", payload)
+		case 'Z':
+			if queryErr != nil {
+				return nil, queryErr
+			}
+			if !found {
+				return nil, sqlValueError("query returned no rows")
+			}
+			return cell, nil
+		}
+		// Row descriptions, command tags, notices and the rest carry
+		// nothing needed here
+	}
+}
+
+// firstColumn decodes a DataRow's first column in text format
+func firstColumn(payload []byte) (*string, bool, error) {
+	if len(payload) < 2 || binary.BigEndian.Uint16(payload) == 0 {
+		return nil, false, nil
+	}
+	if len(payload) < 6 {
+		return nil, false, errors.New("malformed data row")
+	}
+	size := int32(binary.BigEndian.Uint32(payload[2:]))
+	if size < 0 {
+		return nil, true, nil
+	}
+	if int(size) > len(payload)-6 {
+		return nil, false, errors.New("malformed data row")
+	}
+	value := string(payload[6 : 6+size])
+	return &value, true, nil
+}
+
+// close says goodbye so the server doesn't log an unexpected EOF
+func (c *postgresConn) close() {
+	_ = c.write('X', nil)
+}
+
+// write sends one message; kind 0 is the untyped startup message
+func (c *postgresConn) write(kind byte, payload []byte) error {
+	var msg []byte
+	if kind != 0 {
+		msg = append(msg, kind)
+	}
+	msg = binary.BigEndian.AppendUint32(msg, uint32(len(payload)+4))
+	_, err := c.conn.Write(append(msg, payload...))
+	return err
+}
+
+func (c *postgresConn) read() (byte, []byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(c.reader, header); err != nil {
+		return 0, nil, err
+	}
+	size := int32(binary.BigEndian.Uint32(header[1:]))
+	if size < 4 || size > 16<<20 {
+		return 0, nil, fmt.Errorf("invalid message size %d", size)
+	}
+	payload := make([]byte, size-4)
+	_, err := io.ReadFull(c.reader, payload)
+	return header[0], payload, err
+}
+
+// parsePostgresError reads the SQLSTATE code and message fields of an
+// ErrorResponse
+func parsePostgresError(during string, payload []byte) *postgresError {
+	e := &postgresError{during: during, message: "unknown error"}
+	for _, field := range bytes.Split(payload, []byte{0}) {
+		if len(field) < 2 {
+			continue
+		}
+		switch field[0] {
+		case 'C':
+			e.code = string(field[1:])
+		case 'M':
+			e.message = string(field[1:])
+		}
+	}
+	return e
+}
+
+func appendCString(buf []byte, s string) []byte {
+	return append(append(buf, s...), 0)
+}
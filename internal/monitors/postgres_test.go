@@ -0,0 +1,484 @@
+package monitors
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakePostgres is the server side of the protocol subset postgresConn
+// speaks, enough to authenticate a client and answer its queries
+type fakePostgres struct {
+	auth     string // "trust", "cleartext", "md5", "scram" or "gss"
+	user     string
+	password string
+	database string
+
+	// badSignature makes the SCRAM server prove the wrong password
+	badSignature bool
+
+	// reply is sent in answer to every query, before ReadyForQuery
+	reply [][]byte
+}
+
+// pipe serves f on one end of an in-memory connection and returns the
+// other. Server errors other than the client hanging up fail the test.
+func (f *fakePostgres) pipe(t *testing.T) net.Conn {
+	t.Helper()
+	client, server := net.Pipe()
+	_ = server.SetDeadline(time.Now().Add(5 * time.Second))
+
+	done := make(chan error, 1)
+	go func() {
+		defer server.Close()
+		done <- f.serve(server)
+	}()
+	t.Cleanup(func() {
+		client.Close()
+		if err := <-done; err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrClosedPipe) {
+			t.Errorf("fake server: %v", err)
+		}
+	})
+	return client
+}
+
+func (f *fakePostgres) serve(conn net.Conn) error {
+	r := bufio.NewReader(conn)
+
+	// Decline TLS; the client either falls back or gives up
+	var startup []byte
+	for {
+		payload, err := readFakeMessage(r)
+		if err != nil {
+			return err
+		}
+		if len(payload) == 4 && binary.BigEndian.Uint32(payload) == postgresSSLRequestCode {
+			if _, err := conn.Write([]byte{'N'}); err != nil {
+				return err
+			}
+			continue
+		}
+		startup = payload
+		break
+	}
+	if binary.BigEndian.Uint32(startup) != postgresProtocolVersion {
+		return fmt.Errorf("startup: protocol version %x", binary.BigEndian.Uint32(startup))
+	}
+	params := make(map[string]string)
+	fields := strings.Split(string(startup[4:]), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		params[fields[i]] = fields[i+1]
+	}
+	if params["user"] != f.user || params["database"] != f.database {
+		return fmt.Errorf("startup: user %q database %q", params["user"], params["database"])
+	}
+
+	ok, err := f.authenticate(conn, r)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		msg := fmt.Sprintf("password authentication failed for user %q", f.user)
+		return writeFakeMessage(conn, 'E', fakeError("28P01", msg))
+	}
+	for _, msg := range []struct {
+		kind    byte
+		payload []byte
+	}{
+		{'R', authCode(postgresAuthOK)},
+		{'S', []byte("server_version\x0016.0\x00")},
+		{'K', make([]byte, 8)},
+		{'Z', []byte{'I'}},
+	} {
+		if err := writeFakeMessage(conn, msg.kind, msg.payload); err != nil {
+			return err
+		}
+	}
+
+	for {
+		kind, _, err := readFakeTyped(r)
+		if err != nil {
+			return err
+		}
+		switch kind {
+		case 'Q':
+			for _, msg := range f.reply {
+				if _, err := conn.Write(msg); err != nil {
+					return err
+				}
+			}
+			if err := writeFakeMessage(conn, 'Z', []byte{'I'}); err != nil {
+				return err
+			}
+		case 'X':
+			return nil
+		default:
+			return fmt.Errorf("unexpected message %q", kind)
+		}
+	}
+}
+
+// authenticate runs the configured method and reports whether the client
+// knew the password
+func (f *fakePostgres) authenticate(conn net.Conn, r *bufio.Reader) (bool, error) {
+	switch f.auth {
+	case "trust":
+		return true, nil
+	case "cleartext":
+		if err := writeFakeMessage(conn, 'R', authCode(postgresAuthCleartext)); err != nil {
+			return false, err
+		}
+		password, err := readFakePassword(r)
+		return password == f.password+"\x00", err
+	case "md5":
+		salt := []byte{0x9f, 0x10, 0x2c, 0x41}
+		if err := writeFakeMessage(conn, 'R', append(authCode(postgresAuthMD5), salt...)); err != nil {
+			return false, err
+		}
+		inner := md5.Sum([]byte(f.password + f.user))
+		outer := md5.Sum(append([]byte(hex.EncodeToString(inner[:])), salt...))
+		password, err := readFakePassword(r)
+		return password == "md5"+hex.EncodeToString(outer[:])+"\x00", err
+	case "scram":
+		return f.scram(conn, r)
+	case "gss":
+		if err := writeFakeMessage(conn, 'R', authCode(7)); err != nil {
+			return false, err
+		}
+		// The client can't continue and hangs up
+		_, _, err := readFakeTyped(r)
+		return false, err
+	default:
+		return false, fmt.Errorf("unknown auth %q", f.auth)
+	}
+}
+
+// scram is the server half of SCRAM-SHA-256
+func (f *fakePostgres) scram(conn net.Conn, r *bufio.Reader) (bool, error) {
+	if err := writeFakeMessage(conn, 'R', append(authCode(postgresAuthSASL), "SCRAM-SHA-256\x00\x00"...)); err != nil {
+		return false, err
+	}
+	initial, err := readFakePassword(r)
+	if err != nil {
+		return false, err
+	}
+	mechanism, rest, _ := strings.Cut(initial, "\x00")
+	if mechanism != "SCRAM-SHA-256" || len(rest) < 4 {
+		return false, fmt.Errorf("SASL initial response %q", initial)
+	}
+	clientFirst := rest[4:]
+	clientFirstBare, ok := strings.CutPrefix(clientFirst, "n,,")
+	if !ok {
+		return false, fmt.Errorf("client first message %q", clientFirst)
+	}
+
+	salt := []byte("fake salt")
+	iterations := 64
+	nonce := scramAttributes(clientFirstBare)["r"] + "server-nonce"
+	serverFirst := fmt.Sprintf("r=%s,s=%s,i=%d", nonce, base64.StdEncoding.EncodeToString(salt), iterations)
+	if err := writeFakeMessage(conn, 'R', append(authCode(postgresAuthSASLCont), serverFirst...)); err != nil {
+		return false, err
+	}
+
+	clientFinal, err := readFakePassword(r)
+	if err != nil {
+		return false, err
+	}
+	withoutProof, proof, _ := strings.Cut(clientFinal, ",p=")
+	if withoutProof != "c=biws,r="+nonce {
+		return false, fmt.Errorf("client final message %q", clientFinal)
+	}
+	authMessage := clientFirstBare + "," + serverFirst + "," + withoutProof
+
+	salted := pbkdf2SHA256([]byte(f.password), salt, iterations)
+	storedKey := sha256.Sum256(hmacSHA256(salted, "Client Key"))
+	clientKey, _ := base64.StdEncoding.DecodeString(proof)
+	signature := hmacSHA256(storedKey[:], authMessage)
+	if len(clientKey) != len(signature) {
+		return false, nil
+	}
+	for i := range clientKey {
+		clientKey[i] ^= signature[i]
+	}
+	if recovered := sha256.Sum256(clientKey); recovered != storedKey {
+		return false, nil
+	}
+
+	serverSignature := hmacSHA256(hmacSHA256(salted, "Server Key"), authMessage)
+	if f.badSignature {
+		serverSignature[0] ^= 0xff
+	}
+	serverFinal := "v=" + base64.StdEncoding.EncodeToString(serverSignature)
+	return true, writeFakeMessage(conn, 'R', append(authCode(postgresAuthSASLFinal), serverFinal...))
+}
+
+// readFakeMessage reads a message body after its type byte, if any
+func readFakeMessage(r *bufio.Reader) ([]byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	payload := make([]byte, binary.BigEndian.Uint32(header)-4)
+	_, err := io.ReadFull(r, payload)
+	return payload, err
+}
+
+func readFakeTyped(r *bufio.Reader) (byte, []byte, error) {
+	kind, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	payload, err := readFakeMessage(r)
+	return kind, payload, err
+}
+
+// readFakePassword reads a PasswordMessage, which also carries SASL data
+func readFakePassword(r *bufio.Reader) (string, error) {
+	kind, payload, err := readFakeTyped(r)
+	if err == nil && kind != 'p' {
+		err = fmt.Errorf("got message %q, want a password", kind)
+	}
+	return string(payload), err
+}
+
+func writeFakeMessage(conn net.Conn, kind byte, payload []byte) error {
+	_, err := conn.Write(fakeMessage(kind, payload))
+	return err
+}
+
+func fakeMessage(kind byte, payload []byte) []byte {
+	msg := binary.BigEndian.AppendUint32([]byte{kind}, uint32(len(payload)+4))
+	return append(msg, payload...)
+}
+
+func authCode(code uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, code)
+}
+
+func fakeError(code, message string) []byte {
+	payload := appendCString([]byte{'S'}, "ERROR")
+	payload = appendCString(append(payload, 'C'), code)
+	payload = appendCString(append(payload, 'M'), message)
+	return append(payload, 0)
+}
+
+// fakeRow is a DataRow; nil cells are NULL
+func fakeRow(cells ...*string) []byte {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(len(cells)))
+	for _, cell := range cells {
+		if cell == nil {
+			payload = binary.BigEndian.AppendUint32(payload, 0xffffffff)
+			continue
+		}
+		payload = binary.BigEndian.AppendUint32(payload, uint32(len(*cell)))
+		payload = append(payload, *cell...)
+	}
+	return fakeMessage('D', payload)
+}
+
+func fakeRowDescription(column string) []byte {
+	payload := binary.BigEndian.AppendUint16(nil, 1)
+	payload = appendCString(payload, column)
+	return fakeMessage('T', append(payload, make([]byte, 18)...))
+}
+
+func fakeComplete(tag string) []byte {
+	return fakeMessage('C', appendCString(nil, tag))
+}
+
+func text(s string) *string {
+	return &s
+}
+
+// TestPostgresStartup authenticates against the fake server with each
+// supported method, right and wrong passwords, and TLS negotiation
+func TestPostgresStartup(t *testing.T) {
+	tests := []struct {
+		name     string
+		server   fakePostgres
+		password string
+		sslmode  string
+		wantErr  string
+		wantCode string
+	}{
+		{name: "trust", server: fakePostgres{auth: "trust"}},
+		{name: "cleartext", server: fakePostgres{auth: "cleartext", password: "hunter2"}, password: "hunter2"},
+		{name: "cleartext wrong password", server: fakePostgres{auth: "cleartext", password: "hunter2"}, password: "nope", wantErr: "password authentication failed", wantCode: "28P01"},
+		{name: "md5", server: fakePostgres{auth: "md5", password: "hunter2"}, password: "hunter2"},
+		{name: "md5 wrong password", server: fakePostgres{auth: "md5", password: "hunter2"}, password: "nope", wantErr: "password authentication failed", wantCode: "28P01"},
+		{name: "scram", server: fakePostgres{auth: "scram", password: "hunter2"}, password: "hunter2"},
+		{name: "scram wrong password", server: fakePostgres{auth: "scram", password: "hunter2"}, password: "nope", wantErr: "password authentication failed", wantCode: "28P01"},
+		{name: "scram forged server", server: fakePostgres{auth: "scram", password: "hunter2", badSignature: true}, password: "hunter2", wantErr: "signature does not match"},
+		{name: "unsupported method", server: fakePostgres{auth: "gss"}, wantErr: "unsupported authentication method 7"},
+		{name: "prefer falls back to plaintext", server: fakePostgres{auth: "md5", password: "hunter2"}, password: "hunter2", sslmode: "prefer"},
+		{name: "require without server TLS", server: fakePostgres{auth: "trust"}, sslmode: "require", wantErr: "server does not support TLS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.server.user, tt.server.database = "watcher", "app"
+			sslmode := tt.sslmode
+			if sslmode == "" {
+				sslmode = "disable"
+			}
+
+			pc, err := startPostgres(tt.server.pipe(t), postgresOptions{
+				host:     "db.example",
+				user:     "watcher",
+				password: tt.password,
+				database: "app",
+				sslmode:  sslmode,
+			})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("startPostgres: %v", err)
+				}
+				pc.close()
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("startPostgres error = %v, want %q", err, tt.wantErr)
+			}
+			var pgErr *postgresError
+			if tt.wantCode != "" && (!errors.As(err, &pgErr) || pgErr.code != tt.wantCode || pgErr.during != "startup") {
+				t.Errorf("error = %#v, want SQLSTATE %s during startup", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+// TestPostgresQueryValue reads the first cell of a query's result
+func TestPostgresQueryValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		reply    [][]byte
+		want     *string
+		wantErr  string
+		wantCode string
+	}{
+		{
+			name:  "value",
+			reply: [][]byte{fakeRowDescription("count"), fakeRow(text("42")), fakeComplete("SELECT 1")},
+			want:  text("42"),
+		},
+		{
+			name:  "first row and column",
+			reply: [][]byte{fakeRowDescription("count"), fakeRow(text("7"), text("8")), fakeRow(text("9")), fakeComplete("SELECT 2")},
+			want:  text("7"),
+		},
+		{
+			name:  "empty string",
+			reply: [][]byte{fakeRowDescription("name"), fakeRow(text("")), fakeComplete("SELECT 1")},
+			want:  text(""),
+		},
+		{
+			name:  "NULL",
+			reply: [][]byte{fakeRowDescription("max"), fakeRow(nil), fakeComplete("SELECT 1")},
+		},
+		{
+			name:    "no rows",
+			reply:   [][]byte{fakeRowDescription("count"), fakeComplete("SELECT 0")},
+			wantErr: "query returned no rows",
+		},
+		{
+			name:    "no result set",
+			reply:   [][]byte{fakeMessage('I', nil)},
+			wantErr: "query returned no rows",
+		},
+		{
+			name:     "error response",
+			reply:    [][]byte{fakeMessage('E', fakeError("42P01", `relation "jobs" does not exist`))},
+			wantErr:  `relation "jobs" does not exist (SQLSTATE 42P01)`,
+			wantCode: "42P01",
+		},
+		{
+			name:    "malformed row",
+			reply:   [][]byte{fakeMessage('D', []byte{0, 1, 0, 0, 0, 9, '1'})},
+			wantErr: "malformed data row",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakePostgres{auth: "trust", user: "watcher", database: "app", reply: tt.reply}
+			pc, err := startPostgres(server.pipe(t), postgresOptions{user: "watcher", database: "app", sslmode: "disable"})
+			if err != nil {
+				t.Fatalf("startPostgres: %v", err)
+			}
+
+			// Not closed: after a malformed row the server is still
+			// sending, and the pipe's cleanup hangs up on it anyway
+			got, err := pc.queryValue("SELECT count(*) FROM jobs")
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("queryValue error = %v, want %q", err, tt.wantErr)
+				}
+				var pgErr *postgresError
+				if tt.wantCode != "" && (!errors.As(err, &pgErr) || pgErr.code != tt.wantCode || pgErr.during != "query") {
+					t.Errorf("error = %#v, want SQLSTATE %s during query", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("queryValue: %v", err)
+			}
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("queryValue = %v, want %v", quoted(got), quoted(tt.want))
+			}
+		})
+	}
+}
+
+func quoted(s *string) string {
+	if s == nil {
+		return "NULL"
+	}
+	return fmt.Sprintf("%q", *s)
+}
+
+// TestSCRAMVector checks the SCRAM key derivation against the example
+// exchange in RFC 7677
+func TestSCRAMVector(t *testing.T) {
+	salt, _ := base64.StdEncoding.DecodeString("W22ZaJ0SNY7soEsUEjb6gQ==")
+	authMessage := "n=user,r=rOprNGfwEbeRWgbNEkqO," +
+		"r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096," +
+		"c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0"
+
+	salted := pbkdf2SHA256([]byte("pencil"), salt, 4096)
+	clientKey := hmacSHA256(salted, "Client Key")
+	storedKey := sha256.Sum256(clientKey)
+	proof := hmacSHA256(storedKey[:], authMessage)
+	for i := range proof {
+		proof[i] ^= clientKey[i]
+	}
+	if got, want := base64.StdEncoding.EncodeToString(proof), "dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ="; got != want {
+		t.Errorf("client proof = %s, want %s", got, want)
+	}
+
+	signature := hmacSHA256(hmacSHA256(salted, "Server Key"), authMessage)
+	if got, want := base64.StdEncoding.EncodeToString(signature), "6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4="; got != want {
+		t.Errorf("server signature = %s, want %s", got, want)
+	}
+}
+
+// TestParsePostgresError keeps the code and message of an ErrorResponse
+func TestParsePostgresError(t *testing.T) {
+	err := parsePostgresError("query", fakeError("57014", "canceling statement due to statement timeout"))
+	if err.code != "57014" || err.message != "canceling statement due to statement timeout" || err.during != "query" {
+		t.Errorf("parsePostgresError = %#v", err)
+	}
+	if empty := parsePostgresError("startup", nil); empty.message != "unknown error" || empty.code != "" {
+		t.Errorf("parsePostgresError(nil) = %#v", empty)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix && !windows
+
+package monitors
+
+import "os/exec"
+
+// killProcessGroup leaves cmd's default cancellation, which kills only the
+// direct child, on platforms without process groups.
+func killProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package monitors
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killProcessGroup starts cmd in its own process group and, on context
+// cancellation, kills the whole group so grandchildren (make -> go test ->
+// compilers) don't outlive the check.
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,25 @@
+//go:build windows
+
+package monitors
+
+import (
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// killProcessGroup starts cmd in its own process group and, on context
+// cancellation, has taskkill end the whole tree so grandchildren don't
+// outlive the check.
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= syscall.CREATE_NEW_PROCESS_GROUP
+	cmd.Cancel = func() error {
+		if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run(); err != nil {
+			return cmd.Process.Kill()
+		}
+		return nil
+	}
+}
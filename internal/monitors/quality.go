@@ -18,18 +18,30 @@ import (
 var golangciLintMutex sync.Mutex
 
 type QualityMonitor struct {
-	name    string
-	command string
-	args    []string
-	timeout time.Duration
+	name      string
+	command   string
+	args      []string
+	dir       string
+	timeout   time.Duration
+	retries   int
+	warnAfter time.Duration
+	shell     bool
+	container *config.ContainerConfig
+	artifacts *artifactWriter
 }
 
-func NewQualityMonitor(cfg config.CheckConfig) *QualityMonitor {
+func NewQualityMonitor(cfg config.CheckConfig, artifacts config.ArtifactsConfig) *QualityMonitor {
 	return &QualityMonitor{
-		name:    cfg.Name,
-		command: cfg.Command,
-		args:    cfg.Args,
-		timeout: cfg.Timeout,
+		name:      cfg.Name,
+		command:   cfg.Command,
+		args:      cfg.Args,
+		dir:       cfg.Dir,
+		timeout:   cfg.Timeout,
+		retries:   cfg.Retries,
+		warnAfter: cfg.WarnDuration,
+		shell:     cfg.Shell,
+		container: cfg.Container,
+		artifacts: newArtifactWriter(artifacts, cfg.OutputFile),
 	}
 }
 
@@ -41,6 +53,71 @@ func (m *QualityMonitor) Type() MonitorType {
 	return TypeQuality
 }
 
+func (m *QualityMonitor) Info() Info {
+	target := strings.TrimSpace(m.command + " " + strings.Join(m.args, " "))
+	if m.dir != "" {
+		target += " (in " + m.dir + ")"
+	}
+	if m.container != nil {
+		target += " [" + m.container.Image + "]"
+	}
+	return Info{Name: m.name, Type: TypeQuality, Target: target, Timeout: m.timeout}
+}
+
+// saveArtifacts writes the full output when artifact capture is configured
+func (m *QualityMonitor) saveArtifacts(result *Result, stdout, stderr []byte) {
+	if m.artifacts == nil {
+		return
+	}
+	paths, err := m.artifacts.write(m.name, result.Metadata["command"].(string), stdout, stderr, result.Timestamp)
+	if err != nil {
+		result.Metadata["artifact_error"] = err.Error()
+	}
+	if len(paths) > 0 {
+		result.Metadata["artifact"] = paths[len(paths)-1]
+	}
+}
+
+// run executes one attempt of the command. Cancelling ctx kills its whole
+// process tree, and WaitDelay stops anything that escaped the group while
+// holding the output pipes from keeping the attempt open.
+func (m *QualityMonitor) run(ctx context.Context, stdout, stderr *bytes.Buffer) error {
+	command, args := m.command, m.args
+	if m.shell {
+		command, args = shellCommand(m.command)
+	}
+	var container string
+	if m.container != nil {
+		container = containerName(m.name)
+		inner, innerArgs := m.command, m.args
+		if m.shell {
+			// Images are Linux whatever the host runs
+			inner, innerArgs = "sh", []string{"-c", m.command}
+		}
+		var err error
+		if args, err = containerArgs(m.container, container, m.dir, inner, innerArgs); err != nil {
+			return err
+		}
+		command = "docker"
+	}
+
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Dir = m.dir
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	killProcessGroup(cmd)
+	if m.shell && container == "" {
+		setShellCmdLine(cmd, m.command)
+	}
+	cmd.WaitDelay = time.Second
+	err := cmd.Run()
+
+	if container != "" && ctx.Err() != nil {
+		removeContainer(container)
+	}
+	return err
+}
+
 // isGolangciLint checks if this monitor is running golangci-lint
 func (m *QualityMonitor) isGolangciLint() bool {
 	// Check if command is golangci-lint
@@ -67,18 +144,37 @@ func (m *QualityMonitor) Check(ctx context.Context) (*Result, error) {
 		defer golangciLintMutex.Unlock()
 	}
 
-	// Create context with timeout
+	if m.container != nil {
+		if _, err := exec.LookPath("docker"); err != nil {
+			return &Result{
+				Name:        m.name,
+				Type:        TypeQuality,
+				Status:      StatusFail,
+				Message:     fmt.Sprintf("Check runs in container %s but docker is not available: %v", m.container.Image, err),
+				FailureKind: FailureNotFound,
+				Metadata:    make(map[string]interface{}),
+				Timestamp:   time.Now(),
+				Duration:    time.Since(start),
+			}, nil
+		}
+	}
+
+	// The timeout spans every attempt, so retries only use what is left
 	checkCtx, cancel := context.WithTimeout(ctx, m.timeout)
 	defer cancel()
 
-	// Prepare command
-	cmd := exec.CommandContext(checkCtx, m.command, m.args...)
+	attempts := 0
 	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	// Execute command
-	err := cmd.Run()
+	var err error
+	for {
+		attempts++
+		stdout.Reset()
+		stderr.Reset()
+		err = m.run(checkCtx, &stdout, &stderr)
+		if err == nil || checkCtx.Err() != nil || attempts > m.retries || !waitForRetry(checkCtx) {
+			break
+		}
+	}
 	duration := time.Since(start)
 
 	result := &Result{
@@ -91,18 +187,26 @@ func (m *QualityMonitor) Check(ctx context.Context) (*Result, error) {
 
 	// Add command info to metadata
 	result.Metadata["command"] = fmt.Sprintf("%s %s", m.command, strings.Join(m.args, " "))
+	if m.retries > 0 {
+		result.Metadata["attempts"] = attempts
+	}
+	result.Retries = attempts - 1
+	m.saveArtifacts(result, stdout.Bytes(), stderr.Bytes())
 
 	if err != nil {
 		// Check if it was a timeout
 		if checkCtx.Err() == context.DeadlineExceeded {
 			result.Status = StatusFail
 			result.Message = fmt.Sprintf("Command timed out after %v", m.timeout)
+			result.FailureKind = FailureTimeout
+			result.Metadata["timed_out"] = true
 			return result, nil
 		}
 
 		// Command failed
 		result.Status = StatusFail
 		result.Message = fmt.Sprintf("Command failed: %v", err)
+		result.FailureKind = failureKindOf(err, FailureCommand)
 
 		// Include stderr in metadata if available
 		if stderr.Len() > 0 {
@@ -112,6 +216,9 @@ func (m *QualityMonitor) Check(ctx context.Context) (*Result, error) {
 		// Check exit code
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			result.Metadata["exit_code"] = exitErr.ExitCode()
+			if m.container != nil && exitErr.ExitCode() == dockerStartFailed {
+				result.Message = containerStartError(m.container.Image, stderr.String())
+			}
 		}
 
 		return result, nil
@@ -120,6 +227,11 @@ func (m *QualityMonitor) Check(ctx context.Context) (*Result, error) {
 	// Command succeeded
 	result.Status = StatusOK
 	result.Message = fmt.Sprintf("Check passed in %v", duration.Round(time.Millisecond))
+	if m.warnAfter > 0 && duration > m.warnAfter {
+		result.Status = StatusWarn
+		result.Message = fmt.Sprintf("slow: took %v, expected <%v", duration.Round(time.Millisecond), m.warnAfter)
+		result.Metadata["slow"] = true
+	}
 
 	// Include stdout if it's not too large
 	if stdout.Len() > 0 && stdout.Len() < 1024 {
@@ -2,19 +2,67 @@ package monitors
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/orchard9/watch-now/internal/config"
+	"github.com/orchard9/watch-now/internal/expr"
+	"github.com/orchard9/watch-now/internal/schema"
 )
 
+// retryDelay is the pause between attempts of a retrying check
+const retryDelay = 500 * time.Millisecond
+
 type RESTMonitor struct {
-	name    string
-	url     string
-	health  string
-	timeout time.Duration
-	headers map[string]string
+	name     string
+	url      string
+	health   string
+	timeout  time.Duration
+	deadline time.Duration
+	retries  int
+	headers  map[string]string
+	username string
+	password config.Secret
+
+	// liveness counts any HTTP response as up
+	liveness bool
+
+	httpVersion    string
+	connectTimeout time.Duration
+	bodyTimeout    time.Duration
+	client         *http.Client
+
+	expectJSON  map[string]string
+	successExpr *expr.Expr
+
+	// schema is compiled once; schemaErr is set if the file stopped
+	// loading after config validation
+	schema    *schema.Schema
+	schemaErr error
+
+	minBytes, maxBytes int64
+
+	// preRequests run before each health request, sharing a cookie jar
+	preRequests []config.PreRequestConfig
+
+	// detectChange reports INFO when the ETag or body hash differs from
+	// lastVersion, the one seen on the previous check
+	detectChange bool
+	trace        bool
+	mu           sync.Mutex
+	lastVersion  string
 }
 
 func NewRESTMonitor(cfg config.ServiceConfig) *RESTMonitor {
@@ -23,13 +71,79 @@ func NewRESTMonitor(cfg config.ServiceConfig) *RESTMonitor {
 		healthPath = "/health"
 	}
 
-	return &RESTMonitor{
-		name:    cfg.Name,
-		url:     cfg.URL,
-		health:  healthPath,
-		timeout: cfg.Timeout,
-		headers: cfg.Headers,
+	client := newHTTPClient(cfg.HTTPVersion, cfg.Proxy, cfg.ConnectTimeout)
+	if cfg.FollowRedirects != nil && !*cfg.FollowRedirects {
+		client.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+
+	m := &RESTMonitor{
+		name:     cfg.Name,
+		url:      cfg.URL,
+		health:   healthPath,
+		timeout:  cfg.Timeout,
+		deadline: cfg.Deadline,
+		retries:  cfg.Retries,
+		headers:  cfg.Headers,
+		username: cfg.Username,
+		password: cfg.Password,
+
+		liveness: cfg.Probe == "liveness",
+
+		httpVersion:    cfg.HTTPVersion,
+		connectTimeout: cfg.ConnectTimeout,
+		bodyTimeout:    cfg.BodyTimeout,
+		client:         client,
+
+		expectJSON:  cfg.ExpectJSON,
+		successExpr: parseSuccessExpr(cfg.SuccessExpr),
+		minBytes:    cfg.MinBytes,
+		maxBytes:    cfg.MaxBytes,
+		preRequests: cfg.PreRequests,
+
+		detectChange: cfg.DetectChange,
+		trace:        cfg.Trace,
+	}
+	if cfg.ExpectSchema != "" {
+		m.schema, m.schemaErr = schema.Load(cfg.ExpectSchema)
+	}
+	return m
+}
+
+// newHTTPClient returns a client restricted to the requested HTTP version
+// and routed through proxy, which overrides the environment: "none"
+// connects directly and "" keeps the environment's proxy settings. A
+// non-zero connectTimeout bounds dialing separately from the request.
+func newHTTPClient(version, proxy string, connectTimeout time.Duration) *http.Client {
+	if version != "1.1" && version != "2" && proxy == "" && connectTimeout == 0 {
+		return &http.Client{}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if connectTimeout > 0 {
+		dialer := &net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}
+		transport.DialContext = dialer.DialContext
+	}
+	switch version {
+	case "1.1":
+		transport.ForceAttemptHTTP2 = false
+		// A non-nil empty map disables the built-in HTTP/2 upgrade
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	case "2":
+		transport.ForceAttemptHTTP2 = true
 	}
+
+	switch proxy {
+	case "":
+	case "none":
+		transport.Proxy = nil
+	default:
+		// Validated at config load
+		proxyURL, _ := url.Parse(proxy)
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	return &http.Client{Transport: transport}
 }
 
 func (m *RESTMonitor) Name() string {
@@ -40,13 +154,68 @@ func (m *RESTMonitor) Type() MonitorType {
 	return TypeREST
 }
 
+func (m *RESTMonitor) Info() Info {
+	target := m.url + m.health
+	if m.liveness {
+		target += " (liveness)"
+	}
+	return Info{Name: m.name, Type: TypeREST, Target: target, Timeout: m.timeout}
+}
+
 func (m *RESTMonitor) Check(ctx context.Context) (*Result, error) {
+	// The deadline spans every attempt; each attempt gets its own timeout
+	deadlineCtx, cancel := context.WithTimeout(ctx, m.deadline)
+	defer cancel()
+
+	attempts := 0
+	for {
+		attempts++
+		result := m.attempt(deadlineCtx)
+		if result.Status != StatusFail || attempts > m.retries || !waitForRetry(deadlineCtx) {
+			if m.retries > 0 {
+				result.Metadata["attempts"] = attempts
+			}
+			result.Retries = attempts - 1
+			return result, nil
+		}
+	}
+}
+
+// waitForRetry pauses before the next attempt and reports whether the
+// deadline leaves room for one.
+func waitForRetry(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(retryDelay):
+		return ctx.Err() == nil
+	}
+}
+
+func (m *RESTMonitor) attempt(ctx context.Context) *Result {
 	start := time.Now()
 
 	// Create context with timeout
 	checkCtx, cancel := context.WithTimeout(ctx, m.timeout)
 	defer cancel()
 
+	client := m.client
+	if len(m.preRequests) > 0 {
+		var err error
+		if client, err = m.runPreRequests(checkCtx); err != nil {
+			return &Result{
+				Name:        m.name,
+				Type:        TypeREST,
+				Status:      StatusFail,
+				Message:     fmt.Sprintf("Pre-request failed: %v", err),
+				FailureKind: failureKindOf(err, FailureStatus),
+				Metadata:    make(map[string]interface{}),
+				Timestamp:   time.Now(),
+				Duration:    time.Since(start),
+			}
+		}
+	}
+
 	// Build full URL
 	fullURL := m.url + m.health
 
@@ -54,22 +223,26 @@ func (m *RESTMonitor) Check(ctx context.Context) (*Result, error) {
 	req, err := http.NewRequestWithContext(checkCtx, "GET", fullURL, nil)
 	if err != nil {
 		return &Result{
-			Name:      m.name,
-			Type:      TypeREST,
-			Status:    StatusFail,
-			Message:   fmt.Sprintf("Failed to create request: %v", err),
-			Timestamp: time.Now(),
-			Duration:  time.Since(start),
-		}, nil
+			Name:        m.name,
+			Type:        TypeREST,
+			Status:      StatusFail,
+			Message:     fmt.Sprintf("Failed to create request: %v", err),
+			FailureKind: FailureConnection,
+			Metadata:    make(map[string]interface{}),
+			Timestamp:   time.Now(),
+			Duration:    time.Since(start),
+		}
 	}
 
-	// Add headers
-	for key, value := range m.headers {
-		req.Header.Set(key, value)
+	m.setHeaders(req, nil)
+
+	var trace *requestTrace
+	if m.trace {
+		trace = &requestTrace{}
+		req = trace.attach(req)
 	}
 
 	// Make request
-	client := &http.Client{}
 	resp, err := client.Do(req)
 	duration := time.Since(start)
 
@@ -84,37 +257,328 @@ func (m *RESTMonitor) Check(ctx context.Context) (*Result, error) {
 	// Add request info to metadata
 	result.Metadata["url"] = fullURL
 	result.Metadata["timeout"] = m.timeout.String()
+	if trace != nil {
+		result.Metadata["timing"] = trace.timing()
+	}
 
 	if err != nil {
 		// Check if it was a timeout
 		if checkCtx.Err() == context.DeadlineExceeded {
 			result.Status = StatusFail
-			result.Message = fmt.Sprintf("Request timed out after %v", m.timeout)
-			return result, nil
+			result.Message = fmt.Sprintf("Request timed out after %v", duration.Round(time.Millisecond))
+			result.FailureKind = FailureTimeout
+			return result
+		}
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" && opErr.Timeout() {
+			result.Status = StatusFail
+			result.Message = fmt.Sprintf("Connection not established within %v", duration.Round(time.Millisecond))
+			result.FailureKind = FailureTimeout
+			return result
 		}
 
 		// Request failed
 		result.Status = StatusFail
 		result.Message = fmt.Sprintf("Request failed: %v", err)
-		return result, nil
+		result.FailureKind = failureKindOf(err, FailureConnection)
+		return result
 	}
 
 	defer resp.Body.Close()
 
 	// Add response info to metadata
 	result.Metadata["status_code"] = resp.StatusCode
+	result.Metadata["protocol"] = resp.Proto
 
 	// Check status code
-	if resp.StatusCode >= 200 && resp.StatusCode < 400 {
+	classifyStatusCode(result, resp.StatusCode, duration)
+	if m.liveness {
+		// The same response tells readiness: a 2xx or 3xx
+		ready := result.Status == StatusOK
+		result.Metadata["probe"] = "liveness"
+		result.Metadata["ready"] = ready
+		if !ready {
+			result.Status = StatusOK
+			result.Message = fmt.Sprintf("Alive but not ready (HTTP %d) in %v", resp.StatusCode, duration.Round(time.Millisecond))
+			result.FailureKind = ""
+		}
+	}
+
+	if m.httpVersion == "2" && resp.ProtoMajor != 2 {
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("Server negotiated %s, expected HTTP/2", resp.Proto)
+		result.FailureKind = FailureAssertion
+		return result
+	}
+
+	if m.schemaErr != nil {
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("Cannot load expect_schema: %v", m.schemaErr)
+		result.FailureKind = FailureAssertion
+		return result
+	}
+
+	// The body is only read for assertions; otherwise headers with a good
+	// status are enough, which keeps streaming endpoints from hanging
+	checkSize := m.minBytes > 0 || m.maxBytes > 0
+	if m.successExpr != nil || (result.Status == StatusOK && (len(m.expectJSON) > 0 || m.schema != nil || m.detectChange || checkSize)) {
+		body, truncated, err := readBody(checkCtx, resp.Body, m.bodyTimeout)
+		if err != nil {
+			result.Status = StatusFail
+			result.Message = fmt.Sprintf("Failed to read response body: %v", err)
+			result.FailureKind = failureKindOf(err, FailureConnection)
+			return result
+		}
+		if truncated {
+			result.Metadata["body_truncated"] = true
+		}
+		if m.successExpr != nil {
+			m.applySuccessExpr(resp.StatusCode, duration, body, result)
+		}
+		if result.Status == StatusOK && checkSize {
+			m.applySize(resp.ContentLength, body, truncated, result)
+		}
+		if result.Status == StatusOK && len(m.expectJSON) > 0 {
+			m.applyExpectJSON(body, result)
+		}
+		if result.Status == StatusOK && m.schema != nil {
+			m.applyExpectSchema(body, result)
+		}
+		if result.Status == StatusOK && m.detectChange {
+			m.applyChangeDetection(resp.Header.Get("ETag"), body, result)
+		}
+	}
+
+	return result
+}
+
+// setHeaders applies the service's credentials and headers, then extra.
+// Explicit headers are applied last, so an Authorization header wins.
+func (m *RESTMonitor) setHeaders(req *http.Request, extra map[string]string) {
+	if m.username != "" {
+		req.SetBasicAuth(m.username, m.password.Value())
+	}
+	for key, value := range m.headers {
+		req.Header.Set(key, value)
+	}
+	for key, value := range extra {
+		req.Header.Set(key, value)
+	}
+}
+
+// runPreRequests makes the pre_requests in order on a client with a fresh
+// cookie jar and returns that client for the health request
+func (m *RESTMonitor) runPreRequests(ctx context.Context) (*http.Client, error) {
+	jar, _ := cookiejar.New(nil) // Only fails on invalid options
+	client := *m.client
+	client.Jar = jar
+
+	for _, pre := range m.preRequests {
+		method, body := strings.ToUpper(pre.Method), io.Reader(nil)
+		if pre.Body != "" {
+			body = strings.NewReader(pre.Body.Value())
+		}
+		if method == "" {
+			method = http.MethodGet
+			if body != nil {
+				method = http.MethodPost
+			}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, m.url+pre.Path, body)
+		if err != nil {
+			return nil, err
+		}
+		m.setHeaders(req, pre.Headers)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", method, pre.Path, err)
+		}
+		// Drain the body so the connection is reused for the next request
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxBodyBytes))
+		resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			return nil, fmt.Errorf("%s %s: HTTP %d", method, pre.Path, resp.StatusCode)
+		}
+	}
+	return &client, nil
+}
+
+func classifyStatusCode(result *Result, code int, duration time.Duration) {
+	if code >= 200 && code < 400 {
 		result.Status = StatusOK
-		result.Message = fmt.Sprintf("HTTP %d in %v", resp.StatusCode, duration.Round(time.Millisecond))
-	} else if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+		result.Message = fmt.Sprintf("HTTP %d in %v", code, duration.Round(time.Millisecond))
+		return
+	}
+	result.FailureKind = FailureStatus
+	if code >= 400 && code < 500 {
 		result.Status = StatusWarn
-		result.Message = fmt.Sprintf("HTTP %d (client error) in %v", resp.StatusCode, duration.Round(time.Millisecond))
+		result.Message = fmt.Sprintf("HTTP %d (client error) in %v", code, duration.Round(time.Millisecond))
+	} else {
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("HTTP %d (server error) in %v", code, duration.Round(time.Millisecond))
+	}
+}
+
+// parseSuccessExpr compiles an expression already validated at config load
+func parseSuccessExpr(src string) *expr.Expr {
+	if src == "" {
+		return nil
+	}
+	e, _ := expr.Parse(src, config.SuccessExprVars...)
+	return e
+}
+
+// applySuccessExpr replaces the status code verdict with success_expr's
+func (m *RESTMonitor) applySuccessExpr(code int, duration time.Duration, body []byte, result *Result) {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		doc = nil
+	}
+	value, err := m.successExpr.Eval(map[string]interface{}{
+		"status_code": code,
+		"duration_ms": float64(duration.Microseconds()) / 1000,
+		"body":        string(body),
+		"json":        doc,
+	})
+
+	summary := fmt.Sprintf("HTTP %d in %v", code, duration.Round(time.Millisecond))
+	result.Metadata["success_expr"] = m.successExpr.String()
+	result.FailureKind = FailureAssertion
+	switch v := value.(type) {
+	case bool:
+		if v {
+			result.Status = StatusOK
+			result.Message = summary
+			result.FailureKind = ""
+		} else {
+			result.Status = StatusFail
+			result.Message = fmt.Sprintf("success_expr is false: %s", summary)
+		}
+		return
+	case string:
+		switch status := Status(strings.ToLower(v)); status {
+		case StatusOK, StatusWarn, StatusFail, StatusInfo:
+			result.Status = status
+			result.Message = fmt.Sprintf("success_expr returned %s: %s", status, summary)
+			if status == StatusOK || status == StatusInfo {
+				result.FailureKind = ""
+			}
+			return
+		}
+	}
+
+	result.Status = StatusFail
+	if err != nil {
+		result.Message = fmt.Sprintf("success_expr error: %v", err)
 	} else {
+		result.Message = fmt.Sprintf("success_expr returned %v, want a boolean or status", value)
+	}
+}
+
+// applyExpectJSON fails the result when the body doesn't match expect_json
+func (m *RESTMonitor) applyExpectJSON(body []byte, result *Result) {
+	actual, mismatches, err := checkExpectJSON(body, m.expectJSON)
+	if err != nil {
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("Response is not valid JSON: %v", err)
+		result.FailureKind = FailureAssertion
+		return
+	}
+
+	result.Metadata["json"] = actual
+	if len(mismatches) > 0 {
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("JSON mismatch: %s", strings.Join(mismatches, ", "))
+		result.FailureKind = FailureAssertion
+	}
+}
+
+// applySize fails the result when the body is outside min_bytes..max_bytes.
+// A truncated body's size is its Content-Length when given, and otherwise
+// only a lower bound, which can still prove it too large.
+func (m *RESTMonitor) applySize(contentLength int64, body []byte, truncated bool, result *Result) {
+	size := int64(len(body))
+	if truncated && contentLength > size {
+		size = contentLength
+	}
+	result.Metadata["bytes"] = size
+
+	switch {
+	case size < m.minBytes && !(truncated && contentLength < 0):
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("Response too small: %d bytes, expected at least %d", size, m.minBytes)
+		result.FailureKind = FailureAssertion
+	case m.maxBytes > 0 && size > m.maxBytes:
 		result.Status = StatusFail
-		result.Message = fmt.Sprintf("HTTP %d (server error) in %v", resp.StatusCode, duration.Round(time.Millisecond))
+		result.Message = fmt.Sprintf("Response too large: %d bytes, expected at most %d", size, m.maxBytes)
+		result.FailureKind = FailureAssertion
+	}
+}
+
+// maxSchemaErrors bounds the violations kept in a result's metadata
+const maxSchemaErrors = 20
+
+// applyExpectSchema fails the result when the body doesn't conform to
+// expect_schema, listing the violations in metadata.schema_errors
+func (m *RESTMonitor) applyExpectSchema(body []byte, result *Result) {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("Response is not valid JSON: %v", err)
+		result.FailureKind = FailureAssertion
+		return
 	}
 
-	return result, nil
+	errs := m.schema.Validate(doc)
+	if len(errs) == 0 {
+		return
+	}
+	result.Status = StatusFail
+	result.FailureKind = FailureAssertion
+	result.Message = fmt.Sprintf("Response does not match schema: %s", errs[0])
+	if len(errs) > 1 {
+		result.Message += fmt.Sprintf(" (and %d more)", len(errs)-1)
+	}
+	if len(errs) > maxSchemaErrors {
+		errs = errs[:maxSchemaErrors]
+	}
+	result.Metadata["schema_errors"] = errs
+}
+
+// applyChangeDetection compares the response's version, its ETag or else a
+// hash of the body, with the previous check's and reports INFO when it
+// moved, e.g. to confirm a deploy has propagated.
+func (m *RESTMonitor) applyChangeDetection(etag string, body []byte, result *Result) {
+	version := etag
+	if version == "" {
+		sum := sha256.Sum256(body)
+		version = "sha256:" + hex.EncodeToString(sum[:])
+	}
+
+	m.mu.Lock()
+	previous := m.lastVersion
+	m.lastVersion = version
+	m.mu.Unlock()
+
+	result.Metadata["version"] = version
+	switch {
+	case previous == "":
+		result.Message += " (first version recorded)"
+	case previous != version:
+		result.Status = StatusInfo
+		result.Message = fmt.Sprintf("changed: %s → %s", shortVersion(previous), shortVersion(version))
+		result.Metadata["previous_version"] = previous
+	default:
+		result.Message += " (unchanged)"
+	}
+}
+
+// shortVersion trims body hashes to a readable length
+func shortVersion(version string) string {
+	if hash, ok := strings.CutPrefix(version, "sha256:"); ok && len(hash) > 12 {
+		return hash[:12]
+	}
+	return version
 }
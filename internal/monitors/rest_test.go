@@ -0,0 +1,55 @@
+package monitors
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/config"
+)
+
+// A server that sends its headers and then stalls the body must not hold
+// a check with body assertions past its timeout.
+func TestRESTStalledBody(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"status":`))
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	timeout := 300 * time.Millisecond
+	m := NewRESTMonitor(config.ServiceConfig{
+		Name:       "stalled",
+		Type:       "rest",
+		URL:        srv.URL,
+		Timeout:    timeout,
+		Deadline:   timeout,
+		ExpectJSON: map[string]string{"status": "ok"},
+	})
+
+	start := time.Now()
+	result, err := m.Check(context.Background())
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != StatusFail {
+		t.Errorf("status = %s (%s), want %s", result.Status, result.Message, StatusFail)
+	}
+	if result.Metadata["body_truncated"] != true {
+		t.Error("stalled body not marked as truncated")
+	}
+	if elapsed > 2*timeout {
+		t.Errorf("check took %v with a %v timeout", elapsed, timeout)
+	}
+}
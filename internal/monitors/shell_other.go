@@ -0,0 +1,13 @@
+//go:build !windows
+
+package monitors
+
+import "os/exec"
+
+// shellCommand runs script through the POSIX shell
+func shellCommand(script string) (string, []string) {
+	return "sh", []string{"-c", script}
+}
+
+// setShellCmdLine does nothing: sh takes the script as a plain argument
+func setShellCmdLine(cmd *exec.Cmd, script string) {}
@@ -0,0 +1,23 @@
+//go:build windows
+
+package monitors
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// shellCommand runs script through cmd.exe
+func shellCommand(script string) (string, []string) {
+	return "cmd", []string{"/c", script}
+}
+
+// setShellCmdLine hands script to cmd.exe verbatim. Go would otherwise
+// quote it as an argument, which cmd.exe's own parsing doesn't undo, so
+// scripts with quotes or redirections break.
+func setShellCmdLine(cmd *exec.Cmd, script string) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CmdLine = "/c " + script
+}
@@ -0,0 +1,197 @@
+package monitors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/config"
+)
+
+// SQLMonitor connects to a PostgreSQL database and, with a probe query,
+// judges the number it returns against thresholds, so business-level
+// figures such as a job backlog can be watched like any metric. The value
+// is kept in metadata.value so /api/trends can chart it.
+type SQLMonitor struct {
+	name     string
+	url      *url.URL
+	username string
+	password string
+	query    string
+	timeout  time.Duration
+	thresholds
+
+	// dial opens the connection; tests replace it
+	dial func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+func NewSQLMonitor(cfg config.ServiceConfig) *SQLMonitor {
+	// Validation guarantees a parseable url
+	u, _ := url.Parse(cfg.URL)
+	if u == nil {
+		u = &url.URL{}
+	}
+
+	username, password := cfg.Username, cfg.Password.Value()
+	if username == "" {
+		username = u.User.Username()
+	}
+	if password == "" {
+		password, _ = u.User.Password()
+	}
+
+	return &SQLMonitor{
+		name:       cfg.Name,
+		url:        u,
+		username:   username,
+		password:   password,
+		query:      cfg.ProbeQuery,
+		timeout:    cfg.Timeout,
+		thresholds: thresholdsOf(cfg),
+		dial:       (&net.Dialer{}).DialContext,
+	}
+}
+
+func (m *SQLMonitor) Name() string {
+	return m.name
+}
+
+func (m *SQLMonitor) Type() MonitorType {
+	return TypeSQL
+}
+
+func (m *SQLMonitor) Info() Info {
+	return Info{Name: m.name, Type: TypeSQL, Target: m.url.Redacted(), Timeout: m.timeout}
+}
+
+func (m *SQLMonitor) Check(ctx context.Context) (*Result, error) {
+	start := time.Now()
+
+	checkCtx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	result := &Result{
+		Name:     m.name,
+		Type:     TypeSQL,
+		Metadata: map[string]interface{}{"address": m.address(), "database": m.database()},
+	}
+
+	value, err := m.probe(checkCtx)
+	result.Duration = time.Since(start)
+	result.Timestamp = time.Now()
+
+	var pgErr *postgresError
+	var valueErr sqlValueError
+	switch {
+	case checkCtx.Err() == context.DeadlineExceeded:
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("No answer within %v", m.timeout)
+		result.FailureKind = FailureTimeout
+	case errors.As(err, &valueErr):
+		// No number means no verdict on the value itself, so WARN
+		result.Status = StatusWarn
+		result.Message = fmt.Sprintf("No value: %v", err)
+		result.FailureKind = FailureAssertion
+	case errors.As(err, &pgErr) && pgErr.during == "query":
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("Query failed: %v", err)
+		result.FailureKind = FailureCommand
+		result.Metadata["sqlstate"] = pgErr.code
+	case err != nil:
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("Connection failed: %v", err)
+		result.FailureKind = failureKindOf(err, FailureConnection)
+		if errors.As(err, &pgErr) {
+			// Refused by the server, e.g. bad credentials or no such database
+			result.FailureKind = FailureStatus
+			result.Metadata["sqlstate"] = pgErr.code
+		}
+	case m.query == "":
+		result.Status = StatusOK
+		result.Message = fmt.Sprintf("Connected in %v", result.Duration.Round(time.Millisecond))
+	default:
+		result.Metadata["value"] = value
+		m.evaluate(result, value)
+	}
+	return result, nil
+}
+
+// probe connects, runs the probe query (or SELECT 1) and parses its value
+func (m *SQLMonitor) probe(ctx context.Context) (float64, error) {
+	conn, err := m.dial(ctx, "tcp", m.address())
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	pc, err := startPostgres(conn, postgresOptions{
+		host:     m.url.Hostname(),
+		user:     m.username,
+		password: m.password,
+		database: m.database(),
+		sslmode:  m.url.Query().Get("sslmode"),
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer pc.close()
+
+	if m.query == "" {
+		_, err := pc.queryValue("SELECT 1")
+		return 0, err
+	}
+	cell, err := pc.queryValue(m.query)
+	if err != nil {
+		return 0, err
+	}
+	return parseSQLValue(cell)
+}
+
+func (m *SQLMonitor) address() string {
+	port := m.url.Port()
+	if port == "" {
+		port = "5432"
+	}
+	return net.JoinHostPort(m.url.Hostname(), port)
+}
+
+// database is the url path, defaulting to the user name as libpq does
+func (m *SQLMonitor) database() string {
+	if db := strings.TrimPrefix(m.url.Path, "/"); db != "" {
+		return db
+	}
+	return m.username
+}
+
+// sqlValueError is a query result that holds no usable number
+type sqlValueError string
+
+func (e sqlValueError) Error() string {
+	return string(e)
+}
+
+func parseSQLValue(cell *string) (float64, error) {
+	if cell == nil {
+		return 0, sqlValueError("query returned NULL")
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(*cell), 64)
+	if err != nil {
+		return 0, sqlValueError(fmt.Sprintf("query returned %q, not a number", truncateMetric(*cell)))
+	}
+	// Postgres floats and numerics can be NaN or Infinity, which no
+	// threshold or JSON encoding can take
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, sqlValueError(fmt.Sprintf("query returned %q, not a finite number", truncateMetric(*cell)))
+	}
+	return value, nil
+}
@@ -0,0 +1,229 @@
+package monitors
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/config"
+)
+
+// TestSQLCheck runs the monitor against the fake server and judges the
+// value, or the lack of one, its probe query returns
+func TestSQLCheck(t *testing.T) {
+	ten, hundred := 10.0, 100.0
+	count := func(cell *string) [][]byte {
+		return [][]byte{fakeRowDescription("count"), fakeRow(cell), fakeComplete("SELECT 1")}
+	}
+
+	tests := []struct {
+		name        string
+		server      fakePostgres
+		password    string
+		query       string
+		dialErr     error
+		wantStatus  Status
+		wantKind    FailureKind
+		wantMessage string
+		wantValue   interface{}
+		wantState   string
+	}{
+		{
+			name:        "connect only",
+			server:      fakePostgres{auth: "scram", password: "hunter2", reply: count(text("1"))},
+			password:    "hunter2",
+			wantStatus:  StatusOK,
+			wantMessage: "Connected in",
+		},
+		{
+			name:        "value within thresholds",
+			server:      fakePostgres{auth: "md5", password: "hunter2", reply: count(text("3"))},
+			password:    "hunter2",
+			query:       "SELECT count(*) FROM jobs",
+			wantStatus:  StatusOK,
+			wantMessage: "3",
+			wantValue:   3.0,
+		},
+		{
+			name:        "value above warn",
+			server:      fakePostgres{auth: "cleartext", password: "hunter2", reply: count(text(" 42.5\n"))},
+			password:    "hunter2",
+			query:       "SELECT count(*) FROM jobs",
+			wantStatus:  StatusWarn,
+			wantKind:    FailureAssertion,
+			wantMessage: "42.5 (warn above 10)",
+			wantValue:   42.5,
+		},
+		{
+			name:        "value above fail",
+			server:      fakePostgres{auth: "trust", reply: count(text("1e3"))},
+			query:       "SELECT count(*) FROM jobs",
+			wantStatus:  StatusFail,
+			wantKind:    FailureAssertion,
+			wantMessage: "1000 (fail above 100)",
+			wantValue:   1000.0,
+		},
+		{
+			name:        "NULL",
+			server:      fakePostgres{auth: "trust", reply: count(nil)},
+			query:       "SELECT max(age) FROM jobs",
+			wantStatus:  StatusWarn,
+			wantKind:    FailureAssertion,
+			wantMessage: "No value: query returned NULL",
+		},
+		{
+			name:        "not a number",
+			server:      fakePostgres{auth: "trust", reply: count(text("lots"))},
+			query:       "SELECT state FROM jobs",
+			wantStatus:  StatusWarn,
+			wantKind:    FailureAssertion,
+			wantMessage: `No value: query returned "lots", not a number`,
+		},
+		{
+			name:        "empty result",
+			server:      fakePostgres{auth: "trust", reply: [][]byte{fakeRowDescription("count"), fakeComplete("SELECT 0")}},
+			query:       "SELECT count(*) FROM jobs WHERE false GROUP BY 1",
+			wantStatus:  StatusWarn,
+			wantKind:    FailureAssertion,
+			wantMessage: "No value: query returned no rows",
+		},
+		{
+			name:        "query error",
+			server:      fakePostgres{auth: "trust", reply: [][]byte{fakeMessage('E', fakeError("42P01", `relation "jobs" does not exist`))}},
+			query:       "SELECT count(*) FROM jobs",
+			wantStatus:  StatusFail,
+			wantKind:    FailureCommand,
+			wantMessage: `Query failed: relation "jobs" does not exist`,
+			wantState:   "42P01",
+		},
+		{
+			name:        "wrong password",
+			server:      fakePostgres{auth: "scram", password: "hunter2"},
+			password:    "nope",
+			query:       "SELECT count(*) FROM jobs",
+			wantStatus:  StatusFail,
+			wantKind:    FailureStatus,
+			wantMessage: "Connection failed: password authentication failed",
+			wantState:   "28P01",
+		},
+		{
+			name:        "unreachable",
+			dialErr:     &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+			wantStatus:  StatusFail,
+			wantKind:    FailureConnection,
+			wantMessage: "Connection failed: dial tcp: connection refused",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewSQLMonitor(config.ServiceConfig{
+				Name:       "backlog",
+				Type:       "sql",
+				URL:        "postgres://watcher@db.example/app?sslmode=disable",
+				Password:   config.Secret(tt.password),
+				ProbeQuery: tt.query,
+				Timeout:    5 * time.Second,
+				WarnAbove:  &ten,
+				FailAbove:  &hundred,
+			})
+			tt.server.user, tt.server.database = "watcher", "app"
+			m.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+				if address != "db.example:5432" {
+					t.Errorf("dialed %s, want db.example:5432", address)
+				}
+				if tt.dialErr != nil {
+					return nil, tt.dialErr
+				}
+				return tt.server.pipe(t), nil
+			}
+
+			result, err := m.Check(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Status != tt.wantStatus || result.FailureKind != tt.wantKind {
+				t.Errorf("status = %s/%q (%s), want %s/%q", result.Status, result.FailureKind, result.Message, tt.wantStatus, tt.wantKind)
+			}
+			if !strings.HasPrefix(result.Message, tt.wantMessage) {
+				t.Errorf("message = %q, want prefix %q", result.Message, tt.wantMessage)
+			}
+			if got := result.Metadata["value"]; got != tt.wantValue {
+				t.Errorf("metadata.value = %v, want %v", got, tt.wantValue)
+			}
+			if tt.wantState != "" && result.Metadata["sqlstate"] != tt.wantState {
+				t.Errorf("metadata.sqlstate = %v, want %s", result.Metadata["sqlstate"], tt.wantState)
+			}
+		})
+	}
+}
+
+// A server that accepts the connection but never answers fails the check
+// at its timeout
+func TestSQLCheckTimeout(t *testing.T) {
+	timeout := 200 * time.Millisecond
+	m := NewSQLMonitor(config.ServiceConfig{
+		Name:    "backlog",
+		Type:    "sql",
+		URL:     "postgres://watcher@db.example/app?sslmode=disable",
+		Timeout: timeout,
+	})
+	m.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+		client, server := net.Pipe()
+		t.Cleanup(func() { server.Close() })
+		return client, nil
+	}
+
+	start := time.Now()
+	result, err := m.Check(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != StatusFail || result.FailureKind != FailureTimeout {
+		t.Errorf("status = %s/%q (%s), want FAIL/timeout", result.Status, result.FailureKind, result.Message)
+	}
+	if elapsed := time.Since(start); elapsed > 10*timeout {
+		t.Errorf("check took %v with a %v timeout", elapsed, timeout)
+	}
+}
+
+// TestParseSQLValue accepts anything strconv reads as a float, padding
+// included, and explains everything else
+func TestParseSQLValue(t *testing.T) {
+	tests := []struct {
+		cell    *string
+		want    float64
+		wantErr string
+	}{
+		{cell: text("42"), want: 42},
+		{cell: text("-0.25"), want: -0.25},
+		{cell: text(" 7 "), want: 7},
+		{cell: text("1.5e2"), want: 150},
+		{cell: nil, wantErr: "query returned NULL"},
+		{cell: text(""), wantErr: `query returned "", not a number`},
+		{cell: text("t"), wantErr: `query returned "t", not a number`},
+		{cell: text("12 jobs"), wantErr: `query returned "12 jobs", not a number`},
+		{cell: text("NaN"), wantErr: `query returned "NaN", not a finite number`},
+		{cell: text("Infinity"), wantErr: `query returned "Infinity", not a finite number`},
+		{cell: text("-Infinity"), wantErr: `query returned "-Infinity", not a finite number`},
+	}
+
+	for _, tt := range tests {
+		t.Run(quoted(tt.cell), func(t *testing.T) {
+			got, err := parseSQLValue(tt.cell)
+			if tt.wantErr != "" {
+				var valueErr sqlValueError
+				if !errors.As(err, &valueErr) || err.Error() != tt.wantErr {
+					t.Fatalf("parseSQLValue error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("parseSQLValue = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package monitors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/config"
+)
+
+// TCPMonitor checks that a port accepts connections, or with
+// expect: closed, that it refuses them. With ports it checks several
+// ports on one host.
+type TCPMonitor struct {
+	name       string
+	address    string
+	ports      []int
+	timeout    time.Duration
+	wantClosed bool
+}
+
+func NewTCPMonitor(cfg config.ServiceConfig) *TCPMonitor {
+	return &TCPMonitor{
+		name:       cfg.Name,
+		address:    cfg.URL,
+		ports:      cfg.Ports,
+		timeout:    cfg.Timeout,
+		wantClosed: cfg.Expect == "closed",
+	}
+}
+
+func (m *TCPMonitor) Name() string {
+	return m.name
+}
+
+func (m *TCPMonitor) Type() MonitorType {
+	return TypeTCP
+}
+
+func (m *TCPMonitor) Info() Info {
+	target := m.address
+	if len(m.ports) > 0 {
+		ports := make([]string, len(m.ports))
+		for i, port := range m.ports {
+			ports[i] = strconv.Itoa(port)
+		}
+		target = m.host() + ":" + strings.Join(ports, ",")
+	}
+	if m.wantClosed {
+		target += " (expect closed)"
+	}
+	return Info{Name: m.name, Type: TypeTCP, Target: target, Timeout: m.timeout}
+}
+
+func (m *TCPMonitor) Check(ctx context.Context) (*Result, error) {
+	if len(m.ports) > 0 {
+		return m.checkPorts(ctx), nil
+	}
+	return m.probe(ctx, m.address), nil
+}
+
+// host is the address without a port, which ports replaces
+func (m *TCPMonitor) host() string {
+	if host, _, err := net.SplitHostPort(m.address); err == nil {
+		return host
+	}
+	return m.address
+}
+
+// checkPorts probes every port at once. It is OK when all of them are as
+// expected, FAIL when none are and WARN in between.
+func (m *TCPMonitor) checkPorts(ctx context.Context) *Result {
+	start := time.Now()
+	host := m.host()
+
+	probes := make([]*Result, len(m.ports))
+	var wg sync.WaitGroup
+	for i, port := range m.ports {
+		wg.Add(1)
+		go func(i, port int) {
+			defer wg.Done()
+			probes[i] = m.probe(ctx, net.JoinHostPort(host, strconv.Itoa(port)))
+		}(i, port)
+	}
+	wg.Wait()
+
+	result := &Result{
+		Name:      m.name,
+		Type:      TypeTCP,
+		Duration:  time.Since(start),
+		Timestamp: time.Now(),
+		Metadata:  map[string]interface{}{"address": host},
+	}
+
+	ports := make(map[string]interface{}, len(probes))
+	var failed, open []string
+	for i, probe := range probes {
+		port := strconv.Itoa(m.ports[i])
+		ports[port] = map[string]interface{}{"status": probe.Status, "message": probe.Message}
+		if probe.Status != StatusOK {
+			failed = append(failed, port)
+			result.FailureKind = probe.FailureKind
+		}
+		if m.wantClosed && probe.Status == StatusFail {
+			open = append(open, port)
+		}
+	}
+	result.Metadata["ports"] = ports
+
+	// Any open port breaks an expect: closed guardrail; a port that could
+	// not be probed at all only warns
+	switch {
+	case len(failed) == 0 && m.wantClosed:
+		result.Status = StatusOK
+		result.Message = fmt.Sprintf("All %d ports closed", len(probes))
+	case len(failed) == 0:
+		result.Status = StatusOK
+		result.Message = fmt.Sprintf("All %d ports open", len(probes))
+	case len(open) > 0:
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("Ports unexpectedly open: %s", strings.Join(open, ", "))
+		result.FailureKind = FailureAssertion
+	case m.wantClosed:
+		result.Status = StatusWarn
+		result.Message = fmt.Sprintf("Could not probe %d of %d ports: %s", len(failed), len(probes), strings.Join(failed, ", "))
+	case len(failed) == len(probes):
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("No port open: %s", strings.Join(failed, ", "))
+	default:
+		result.Status = StatusWarn
+		result.Message = fmt.Sprintf("%d of %d ports open; not open: %s", len(probes)-len(failed), len(probes), strings.Join(failed, ", "))
+	}
+	return result
+}
+
+// probe dials one address and judges it against the expected state
+func (m *TCPMonitor) probe(ctx context.Context, address string) *Result {
+	start := time.Now()
+
+	checkCtx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(checkCtx, "tcp", address)
+	if conn != nil {
+		_ = conn.Close()
+	}
+
+	result := &Result{
+		Name:      m.name,
+		Type:      TypeTCP,
+		Duration:  time.Since(start),
+		Timestamp: time.Now(),
+		Metadata:  map[string]interface{}{"address": address},
+	}
+
+	if m.wantClosed {
+		m.evaluateClosed(result, err, checkCtx.Err())
+	} else {
+		m.evaluateOpen(result, err)
+	}
+	return result
+}
+
+func (m *TCPMonitor) evaluateOpen(result *Result, err error) {
+	if err != nil {
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("Connection failed: %v", err)
+		result.FailureKind = failureKindOf(err, FailureConnection)
+		return
+	}
+	result.Status = StatusOK
+	result.Message = fmt.Sprintf("Port open, connected in %v", result.Duration.Round(time.Millisecond))
+}
+
+// evaluateClosed inverts the usual semantics: refusal or silence is healthy
+func (m *TCPMonitor) evaluateClosed(result *Result, err, ctxErr error) {
+	switch {
+	case err == nil:
+		result.Status = StatusFail
+		result.Message = "Port unexpectedly open"
+		result.FailureKind = FailureAssertion
+	case errors.Is(err, syscall.ECONNREFUSED):
+		result.Status = StatusOK
+		result.Message = "Port closed (connection refused)"
+	case errors.Is(ctxErr, context.DeadlineExceeded):
+		result.Status = StatusOK
+		result.Message = "Port closed (no response, likely filtered)"
+	default:
+		// DNS or routing failures say nothing about the port itself
+		result.Status = StatusWarn
+		result.Message = fmt.Sprintf("Could not probe port: %v", err)
+		result.FailureKind = failureKindOf(err, FailureConnection)
+	}
+}
@@ -0,0 +1,75 @@
+package monitors
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// requestTrace notes when each phase of a request started and finished.
+// Only the first occurrence counts, so redirects and parallel dials don't
+// overwrite the phases of the original connection.
+type requestTrace struct {
+	mu sync.Mutex
+
+	start                     time.Time
+	dnsStart, dnsDone         time.Time
+	connectStart, connectDone time.Time
+	tlsStart, tlsDone         time.Time
+	wroteRequest, firstByte   time.Time
+	reused                    bool
+}
+
+func (t *requestTrace) attach(req *http.Request) *http.Request {
+	t.start = time.Now()
+	mark := func(at *time.Time) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if at.IsZero() {
+			*at = time.Now()
+		}
+	}
+
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if t.wroteRequest.IsZero() {
+				t.reused = info.Reused
+			}
+		},
+		DNSStart:             func(httptrace.DNSStartInfo) { mark(&t.dnsStart) },
+		DNSDone:              func(httptrace.DNSDoneInfo) { mark(&t.dnsDone) },
+		ConnectStart:         func(string, string) { mark(&t.connectStart) },
+		ConnectDone:          func(string, string, error) { mark(&t.connectDone) },
+		TLSHandshakeStart:    func() { mark(&t.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { mark(&t.tlsDone) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { mark(&t.wroteRequest) },
+		GotFirstResponseByte: func() { mark(&t.firstByte) },
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+// timing reports the phases that happened; a reused connection has no dns,
+// connect or tls, and a failed request stops at the phase that failed.
+// server is the wait between sending the request and the first byte, and
+// ttfb the time from the start of the request to it.
+func (t *requestTrace) timing() map[string]interface{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	timing := map[string]interface{}{"reused_connection": t.reused}
+	phase := func(name string, from, to time.Time) {
+		if !from.IsZero() && !to.IsZero() {
+			timing[name] = to.Sub(from).Round(time.Microsecond).String()
+		}
+	}
+	phase("dns", t.dnsStart, t.dnsDone)
+	phase("connect", t.connectStart, t.connectDone)
+	phase("tls", t.tlsStart, t.tlsDone)
+	phase("server", t.wroteRequest, t.firstByte)
+	phase("ttfb", t.start, t.firstByte)
+	return timing
+}
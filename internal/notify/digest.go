@@ -0,0 +1,75 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// renderDigest combines several events into one message, one template
+// line per event under a summary header.
+func renderDigest(ch *channel, events []Event, held int) (string, error) {
+	var text strings.Builder
+	fmt.Fprintf(&text, "%d status changes", len(events))
+	if held > 0 {
+		fmt.Fprintf(&text, " (%d held back by rate limit)", held)
+	}
+	text.WriteString(":")
+
+	for _, event := range events {
+		var line bytes.Buffer
+		if err := ch.template.Execute(&line, event); err != nil {
+			return "", fmt.Errorf("rendering template: %w", err)
+		}
+		text.WriteString("\n• ")
+		text.WriteString(line.String())
+	}
+	return text.String(), nil
+}
+
+func digestPayload(ch *channel, text string, events []Event, held int) interface{} {
+	if ch.cfg.Type == "slack" {
+		return map[string]string{"text": text}
+	}
+	return struct {
+		Text       string  `json:"text"`
+		Digest     bool    `json:"digest"`
+		Events     []Event `json:"events"`
+		Suppressed int     `json:"suppressed"`
+	}{Text: text, Digest: true, Events: events, Suppressed: held}
+}
+
+// tokenBucket allows a burst of up to capacity sends, refilling evenly
+// over the period.
+type tokenBucket struct {
+	capacity float64
+	tokens   float64
+	rate     float64 // tokens per second
+	last     time.Time
+}
+
+func newTokenBucket(perPeriod int, period time.Duration) *tokenBucket {
+	return &tokenBucket{
+		capacity: float64(perPeriod),
+		tokens:   float64(perPeriod),
+		rate:     float64(perPeriod) / period.Seconds(),
+		last:     time.Now(),
+	}
+}
+
+// take consumes a token if one is available and returns 0, otherwise it
+// returns how long until the next token.
+func (b *tokenBucket) take(now time.Time) time.Duration {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
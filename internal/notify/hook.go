@@ -0,0 +1,53 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/config"
+)
+
+const defaultHookTimeout = 30 * time.Second
+
+// RunHook executes a transition hook in the background and logs its output.
+func RunHook(hook *config.HookConfig, event Event) {
+	go func() {
+		output, err := runHook(hook, event)
+		if err != nil {
+			log.Printf("Transition hook for %s failed: %v: %s", event.Name, err, output)
+			return
+		}
+		if output != "" {
+			log.Printf("Transition hook for %s: %s", event.Name, output)
+		}
+	}()
+}
+
+func runHook(hook *config.HookConfig, event Event) (string, error) {
+	timeout := hook.Timeout
+	if timeout == 0 {
+		timeout = defaultHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, hook.Command, hook.Args...)
+	cmd.Env = append(os.Environ(),
+		"WATCH_NOW_NAME="+event.Name,
+		"WATCH_NOW_TYPE="+string(event.Type),
+		"WATCH_NOW_OLD_STATUS="+string(event.Old),
+		"WATCH_NOW_NEW_STATUS="+string(event.New),
+		"WATCH_NOW_MESSAGE="+event.Message,
+	)
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := cmd.Run()
+	return strings.TrimSpace(output.String()), err
+}
@@ -0,0 +1,307 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/config"
+	"github.com/orchard9/watch-now/internal/monitors"
+)
+
+const defaultTemplate = `{{if .Flapping}}[flapping] {{.Name}}: {{.Message}}{{else if .Stabilized}}[stabilized] {{.Name}}: {{.Message}}{{else if .Reminder}}[still {{.New}}] {{.Name}} for {{.Downtime}}: {{.Message}}{{else if .Recovered}}[recovered] {{.Name}} is {{.New}} after {{.Downtime}} {{.Old}}: {{.Message}}{{else}}[{{.New}}] {{.Name}}{{if .Old}} went {{.Old}} → {{.New}}{{end}}: {{.Message}}{{end}}`
+
+// Event is the data available to notification templates.
+type Event struct {
+	Name      string                 `json:"name"`
+	Type      monitors.MonitorType   `json:"type"`
+	Old       monitors.Status        `json:"old"`
+	New       monitors.Status        `json:"new"`
+	Message   string                 `json:"message"`
+	Duration  time.Duration          `json:"duration"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	Labels    map[string]string      `json:"labels,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+	Flapping  bool                   `json:"flapping,omitempty"`
+
+	// Stabilized marks the end of a flap; New is the status it settled on.
+	Stabilized bool `json:"stabilized,omitempty"`
+
+	// Recovered marks a return to OK from WARN or FAIL; Downtime is how
+	// long the monitor was unhealthy.
+	Recovered bool          `json:"recovered,omitempty"`
+	Downtime  time.Duration `json:"downtime,omitempty"`
+
+	// Reminder marks a repeat alert for a monitor that is still failing;
+	// Downtime is how long it has been failing so far.
+	Reminder bool `json:"reminder,omitempty"`
+}
+
+// queueSize bounds the deliveries waiting on a slow channel
+const queueSize = 100
+
+// retryBackoff is the wait before the first retry, doubling after each
+const retryBackoff = time.Second
+
+type channel struct {
+	cfg      config.ChannelConfig
+	template *template.Template
+	queue    chan delivery
+
+	// dropped counts transitions lost to a full queue
+	dropped atomic.Int64
+}
+
+// delivery is one message: a single event, or a digest when held > 0 or
+// there are several events
+type delivery struct {
+	events []Event
+	held   int
+}
+
+// Notifier delivers status transitions to the configured channels.
+type Notifier struct {
+	channels []*channel
+	client   *http.Client
+	retries  int
+
+	// notifyOn holds the enabled transition kinds; nil enables all
+	notifyOn map[string]bool
+
+	// routes restrict which channels get an event; nil sends to all
+	routes []route
+
+	// outages tracks failing monitors for reminder_interval reminders
+	reminderInterval time.Duration
+	outagesMu        sync.Mutex
+	outages          map[string]*outage
+
+	// Batching state, only used when a coalesce window or rate limit is set
+	window  time.Duration
+	limiter *tokenBucket
+	mu      sync.Mutex
+	pending []Event
+	held    int
+	timer   *time.Timer
+}
+
+func New(cfg config.NotificationsConfig) (*Notifier, error) {
+	n := &Notifier{
+		client:  &http.Client{Timeout: cfg.Timeout},
+		retries: cfg.Retries,
+		window:  cfg.CoalesceWindow,
+
+		reminderInterval: cfg.ReminderInterval,
+		outages:          make(map[string]*outage),
+	}
+	if len(cfg.NotifyOn) > 0 {
+		n.notifyOn = make(map[string]bool)
+		for _, kind := range cfg.NotifyOn {
+			n.notifyOn[kind] = true
+		}
+	}
+	for _, r := range cfg.Routes {
+		n.routes = append(n.routes, newRoute(r))
+	}
+	if cfg.MaxPerMinute > 0 {
+		n.limiter = newTokenBucket(cfg.MaxPerMinute, time.Minute)
+	}
+
+	for _, chCfg := range cfg.Channels {
+		text := chCfg.Template
+		if text == "" {
+			text = defaultTemplate
+		}
+		tmpl, err := template.New(chCfg.Name).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("parsing template for channel %s: %w", chCfg.Name, err)
+		}
+		ch := &channel{cfg: chCfg, template: tmpl, queue: make(chan delivery, queueSize)}
+		n.channels = append(n.channels, ch)
+		go n.work(ch)
+	}
+
+	return n, nil
+}
+
+// Notify sends the event to every channel in the background.
+func (n *Notifier) Notify(event Event) {
+	n.Track(event)
+
+	// A monitor starting out healthy is not worth announcing
+	if event.Old == "" && event.New == monitors.StatusOK {
+		return
+	}
+	// Flap alerts and the end of a flap are sent whatever their direction
+	if n.notifyOn != nil && !event.Flapping && !event.Stabilized && !n.notifyOn[transitionKind(event)] {
+		return
+	}
+	n.dispatch(event)
+}
+
+// dispatch sends an event now, or queues it for the next digest when
+// batching or rate limiting is on
+func (n *Notifier) dispatch(event Event) {
+	if n.window == 0 && n.limiter == nil {
+		n.send([]Event{event}, 0)
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.pending = append(n.pending, event)
+	if n.timer == nil {
+		n.timer = time.AfterFunc(n.window, n.flush)
+	}
+}
+
+// transitionKind names where a transition leads, as used by notify_on
+func transitionKind(event Event) string {
+	if event.New == monitors.StatusOK {
+		return "recovery"
+	}
+	return string(event.New)
+}
+
+// flush sends everything pending as one message, or reschedules itself
+// when the rate limit has no capacity left. Nothing pending is dropped
+// here; only a channel whose queue is full drops messages, see send.
+func (n *Notifier) flush() {
+	n.mu.Lock()
+	if n.limiter != nil {
+		if wait := n.limiter.take(time.Now()); wait > 0 {
+			n.held = len(n.pending)
+			n.timer = time.AfterFunc(wait, n.flush)
+			n.mu.Unlock()
+			return
+		}
+	}
+	batch, held := n.pending, n.held
+	n.pending, n.held, n.timer = nil, 0, nil
+	n.mu.Unlock()
+
+	n.send(batch, held)
+}
+
+// send queues a batch for every channel without blocking, keeping only the
+// events routed to each. Each channel has its own worker, so a hung
+// endpoint only delays its own messages. Once queueSize messages are
+// waiting on a channel, further ones for it are dropped and counted rather
+// than stalling monitoring.
+func (n *Notifier) send(events []Event, held int) {
+	for _, ch := range n.channels {
+		routed := n.routedTo(ch, events)
+		if len(routed) == 0 {
+			continue
+		}
+		d := delivery{events: routed, held: held}
+		if d.held > len(routed) {
+			d.held = len(routed)
+		}
+		select {
+		case ch.queue <- d:
+		default:
+			total := ch.dropped.Add(int64(len(routed)))
+			log.Printf("Notification queue for %s is full; dropped %d transitions (%d dropped so far)", ch.cfg.Name, len(routed), total)
+		}
+	}
+}
+
+// work delivers a channel's queued messages in order
+func (n *Notifier) work(ch *channel) {
+	for d := range ch.queue {
+		if err := n.deliverWithRetry(ch, d); err != nil {
+			log.Printf("Notification to %s failed: %v", ch.cfg.Name, err)
+		}
+	}
+}
+
+// deliverWithRetry retries transient failures with exponential backoff
+func (n *Notifier) deliverWithRetry(ch *channel, d delivery) error {
+	backoff := retryBackoff
+	var err error
+	for attempt := 0; attempt <= n.retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		err = n.deliver(ch, d.events, d.held)
+		var status statusError
+		if err == nil || (errors.As(err, &status) && !status.retryable()) {
+			return err
+		}
+	}
+	return err
+}
+
+// statusError is a non-2xx response from a channel endpoint
+type statusError int
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("unexpected status %d", int(e))
+}
+
+// retryable reports whether the endpoint might accept the same message later
+func (e statusError) retryable() bool {
+	return e >= 500 || e == http.StatusTooManyRequests
+}
+
+func (n *Notifier) deliver(ch *channel, events []Event, held int) error {
+	var payload interface{}
+	if len(events) == 1 && held == 0 {
+		var text bytes.Buffer
+		if err := ch.template.Execute(&text, events[0]); err != nil {
+			return fmt.Errorf("rendering template: %w", err)
+		}
+		payload = eventPayload(ch, text.String(), events[0])
+	} else {
+		text, err := renderDigest(ch, events, held)
+		if err != nil {
+			return err
+		}
+		payload = digestPayload(ch, text, events, held)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encoding payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), "POST", ch.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range ch.cfg.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return statusError(resp.StatusCode)
+	}
+	return nil
+}
+
+func eventPayload(ch *channel, text string, event Event) interface{} {
+	if ch.cfg.Type == "slack" {
+		return map[string]string{"text": text}
+	}
+	return struct {
+		Text string `json:"text"`
+		Event
+	}{Text: text, Event: event}
+}
@@ -0,0 +1,79 @@
+package notify
+
+import (
+	"time"
+
+	"github.com/orchard9/watch-now/internal/monitors"
+)
+
+// outage is a monitor that has been failing since the event that started it
+type outage struct {
+	event Event
+	since time.Time
+	timer *time.Timer
+}
+
+// Track follows status transitions so a monitor that keeps failing gets a
+// reminder every reminder_interval. Notify calls it for every transition;
+// callers that suppress a transition should still pass it here.
+func (n *Notifier) Track(event Event) {
+	if n.reminderInterval <= 0 {
+		return
+	}
+
+	n.outagesMu.Lock()
+	defer n.outagesMu.Unlock()
+
+	if event.New != monitors.StatusFail {
+		if o, ok := n.outages[event.Name]; ok {
+			o.timer.Stop()
+			delete(n.outages, event.Name)
+		}
+		return
+	}
+	if _, ok := n.outages[event.Name]; ok {
+		return
+	}
+
+	o := &outage{event: event, since: event.Timestamp}
+	if o.since.IsZero() {
+		o.since = time.Now()
+	}
+	o.timer = time.AfterFunc(n.reminderInterval, func() { n.remind(o) })
+	n.outages[event.Name] = o
+}
+
+// Forget stops reminders for a monitor's current outage, e.g. once it has
+// been acknowledged. A later failure starts a new outage.
+func (n *Notifier) Forget(name string) {
+	n.outagesMu.Lock()
+	defer n.outagesMu.Unlock()
+	if o, ok := n.outages[name]; ok {
+		o.timer.Stop()
+		delete(n.outages, name)
+	}
+}
+
+// remind sends a "still failing" message and schedules the next one, unless
+// the outage ended in the meantime
+func (n *Notifier) remind(o *outage) {
+	n.outagesMu.Lock()
+	if n.outages[o.event.Name] != o {
+		n.outagesMu.Unlock()
+		return
+	}
+	reminder := o.event
+	reminder.Old = o.event.New
+	reminder.Reminder = true
+	reminder.Flapping = false
+	reminder.Stabilized = false
+	reminder.Downtime = time.Since(o.since).Round(time.Second)
+	reminder.Timestamp = time.Now()
+	o.timer = time.AfterFunc(n.reminderInterval, func() { n.remind(o) })
+	n.outagesMu.Unlock()
+
+	if n.notifyOn != nil && !n.notifyOn[transitionKind(reminder)] {
+		return
+	}
+	n.dispatch(reminder)
+}
@@ -0,0 +1,54 @@
+package notify
+
+import "github.com/orchard9/watch-now/internal/config"
+
+// route is a compiled notifications.routes entry
+type route struct {
+	kinds    map[string]bool // nil matches every transition kind
+	labels   map[string]string
+	channels map[string]bool
+}
+
+func newRoute(cfg config.RouteConfig) route {
+	r := route{labels: cfg.Labels, channels: make(map[string]bool)}
+	if len(cfg.Status) > 0 {
+		r.kinds = make(map[string]bool)
+		for _, kind := range cfg.Status {
+			r.kinds[kind] = true
+		}
+	}
+	for _, name := range cfg.Channels {
+		r.channels[name] = true
+	}
+	return r
+}
+
+func (r route) matches(event Event) bool {
+	if r.kinds != nil && !r.kinds[transitionKind(event)] {
+		return false
+	}
+	for key, value := range r.labels {
+		if event.Labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// routedTo returns the events that go to ch: all of them without routes,
+// otherwise those matching a route that names ch
+func (n *Notifier) routedTo(ch *channel, events []Event) []Event {
+	if n.routes == nil {
+		return events
+	}
+	var routed []Event
+	for _, event := range events {
+		for _, r := range n.routes {
+			if r.channels[ch.cfg.Name] && r.matches(event) {
+				routed = append(routed, event)
+				break
+			}
+		}
+	}
+	return routed
+}
@@ -0,0 +1,190 @@
+// Package output streams every result to external sinks, one line of JSON
+// per result, for log aggregators and offline analysis.
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/config"
+	"github.com/orchard9/watch-now/internal/monitors"
+)
+
+// maxBatch bounds how many queued lines go out in one write or request
+const maxBatch = 100
+
+// sink writes a batch of encoded results, each ending in a newline
+type sink interface {
+	write(lines [][]byte) error
+}
+
+// Writer queues results for one sink and delivers them from its own
+// goroutine. When the queue is full, new results are dropped.
+type Writer struct {
+	name  string
+	sink  sink
+	queue chan []byte
+
+	// mu guards the count of queued and in-flight results, the flushes
+	// waiting for it to reach zero, and the results dropped since the
+	// last report. A WaitGroup won't do: Write may add while Flush waits.
+	mu      sync.Mutex
+	pending int
+	drained []chan struct{}
+	dropped int
+}
+
+func New(cfg config.OutputConfig) (*Writer, error) {
+	w := &Writer{queue: make(chan []byte, cfg.Buffer)}
+	switch cfg.Type {
+	case "file":
+		file, err := os.OpenFile(cfg.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("opening output %s: %w", cfg.Path, err)
+		}
+		w.name, w.sink = cfg.Path, &fileSink{file: file}
+	case "http":
+		w.name = cfg.URL
+		w.sink = &httpSink{url: cfg.URL, headers: cfg.Headers, client: &http.Client{Timeout: 10 * time.Second}}
+	default:
+		return nil, fmt.Errorf("unknown output type %q", cfg.Type)
+	}
+
+	go w.run()
+	return w, nil
+}
+
+// Write queues a result without blocking
+func (w *Writer) Write(result *monitors.Result) {
+	line, err := json.Marshal(result)
+	if err != nil {
+		log.Printf("Output %s: encoding %s: %v", w.name, result.Name, err)
+		return
+	}
+
+	// Counted under the lock, so the run loop can't finish the line and
+	// uncount it first
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	select {
+	case w.queue <- append(line, '\n'):
+		w.pending++
+	default:
+		w.dropped++
+	}
+}
+
+// Flush waits until everything queued so far is delivered, or timeout
+// passes. It reports whether the queue drained.
+func (w *Writer) Flush(timeout time.Duration) bool {
+	w.mu.Lock()
+	if w.pending == 0 {
+		w.mu.Unlock()
+		return true
+	}
+	done := make(chan struct{})
+	w.drained = append(w.drained, done)
+	w.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// run delivers queued lines in batches. Failed batches are logged and
+// dropped, so a broken sink cannot pile up results.
+func (w *Writer) run() {
+	for line := range w.queue {
+		batch := [][]byte{line}
+	collect:
+		for len(batch) < maxBatch {
+			select {
+			case next := <-w.queue:
+				batch = append(batch, next)
+			default:
+				break collect
+			}
+		}
+
+		if err := w.sink.write(batch); err != nil {
+			log.Printf("Output %s: dropped %d results: %v", w.name, len(batch), err)
+		}
+		w.reportDropped()
+		w.delivered(len(batch))
+	}
+}
+
+// delivered uncounts n finished results and, once none are left, wakes
+// every waiting Flush
+func (w *Writer) delivered(n int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending -= n
+	if w.pending > 0 {
+		return
+	}
+	for _, done := range w.drained {
+		close(done)
+	}
+	w.drained = nil
+}
+
+// reportDropped logs results lost to a full queue since the last report
+func (w *Writer) reportDropped() {
+	w.mu.Lock()
+	dropped := w.dropped
+	w.dropped = 0
+	w.mu.Unlock()
+	if dropped > 0 {
+		log.Printf("Output %s is falling behind; dropped %d results", w.name, dropped)
+	}
+}
+
+// fileSink appends NDJSON to a file
+type fileSink struct {
+	file *os.File
+}
+
+func (s *fileSink) write(lines [][]byte) error {
+	_, err := s.file.Write(bytes.Join(lines, nil))
+	return err
+}
+
+// httpSink POSTs each batch as an NDJSON body
+type httpSink struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func (s *httpSink) write(lines [][]byte) error {
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(bytes.Join(lines, nil)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	for key, value := range s.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
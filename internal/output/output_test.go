@@ -0,0 +1,208 @@
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/config"
+	"github.com/orchard9/watch-now/internal/monitors"
+)
+
+// recordingSink keeps every line it is given, optionally holding each
+// batch until release is closed
+type recordingSink struct {
+	release chan struct{}
+
+	mu    sync.Mutex
+	lines int
+}
+
+func (s *recordingSink) write(lines [][]byte) error {
+	if s.release != nil {
+		<-s.release
+	}
+	s.mu.Lock()
+	s.lines += len(lines)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *recordingSink) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lines
+}
+
+func newTestWriter(s sink, buffer int) *Writer {
+	w := &Writer{name: "test", sink: s, queue: make(chan []byte, buffer)}
+	go w.run()
+	return w
+}
+
+func result(i int) *monitors.Result {
+	return &monitors.Result{Name: fmt.Sprintf("check-%d", i), Status: monitors.StatusOK, Timestamp: time.Now()}
+}
+
+// Writes racing with flushes must neither trip the race detector nor
+// leave a Flush waiting on results that were already delivered
+func TestConcurrentWriteAndFlush(t *testing.T) {
+	s := &recordingSink{}
+	w := newTestWriter(s, 10000)
+
+	const writers, perWriter = 8, 200
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < perWriter; j++ {
+				w.Write(result(i*perWriter + j))
+			}
+		}(i)
+	}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				w.Flush(time.Millisecond)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if !w.Flush(5 * time.Second) {
+		t.Fatal("Flush timed out after all writes finished")
+	}
+	if got := s.count(); got != writers*perWriter {
+		t.Errorf("delivered %d results, want %d", got, writers*perWriter)
+	}
+}
+
+// TestFlushWaitsForDelivery holds the sink and checks Flush reports
+// whether the queue drained in time
+func TestFlushWaitsForDelivery(t *testing.T) {
+	if !newTestWriter(&recordingSink{}, 10).Flush(time.Millisecond) {
+		t.Error("Flush of an idle writer timed out")
+	}
+
+	s := &recordingSink{release: make(chan struct{})}
+	w := newTestWriter(s, 10)
+	for i := 0; i < 3; i++ {
+		w.Write(result(i))
+	}
+	if w.Flush(50 * time.Millisecond) {
+		t.Fatal("Flush returned true while the sink was still writing")
+	}
+
+	flushed := make(chan bool)
+	go func() { flushed <- w.Flush(5 * time.Second) }()
+	close(s.release)
+	if !<-flushed {
+		t.Fatal("Flush timed out after the sink was released")
+	}
+	if got := s.count(); got != 3 {
+		t.Errorf("delivered %d results, want 3", got)
+	}
+}
+
+// A full queue drops new results instead of blocking the caller
+func TestWriteDropsWhenFull(t *testing.T) {
+	s := &recordingSink{release: make(chan struct{})}
+	w := newTestWriter(s, 2)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			w.Write(result(i))
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Write blocked on a full queue")
+	}
+
+	close(s.release)
+	if !w.Flush(5 * time.Second) {
+		t.Fatal("Flush timed out")
+	}
+	// At most a batch taken from a full queue, plus the queue refilled
+	// behind it, got through
+	if got := s.count(); got < 1 || got > 5 {
+		t.Errorf("delivered %d of 20 results, want 1 to 5", got)
+	}
+}
+
+// TestFileOutput appends one JSON object per line
+func TestFileOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.ndjson")
+	w, err := New(config.OutputConfig{Type: "file", Path: path, Buffer: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(result(1))
+	w.Write(result(2))
+	if !w.Flush(5 * time.Second) {
+		t.Fatal("Flush timed out")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var names []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var r monitors.Result
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		names = append(names, r.Name)
+	}
+	if len(names) != 2 || names[0] != "check-1" || names[1] != "check-2" {
+		t.Errorf("file holds %v, want [check-1 check-2]", names)
+	}
+}
+
+// TestHTTPOutput posts NDJSON with the configured headers
+func TestHTTPOutput(t *testing.T) {
+	type request struct {
+		contentType, token, body string
+	}
+	requests := make(chan request, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{r.Header.Get("Content-Type"), r.Header.Get("X-Scope-OrgID"), string(body)}
+	}))
+	defer srv.Close()
+
+	w, err := New(config.OutputConfig{Type: "http", URL: srv.URL, Headers: map[string]string{"X-Scope-OrgID": "team"}, Buffer: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(result(1))
+	if !w.Flush(5 * time.Second) {
+		t.Fatal("Flush timed out")
+	}
+
+	got := <-requests
+	if got.contentType != "application/x-ndjson" || got.token != "team" {
+		t.Errorf("headers = %q, %q", got.contentType, got.token)
+	}
+	var r monitors.Result
+	if err := json.Unmarshal([]byte(got.body), &r); err != nil || r.Name != "check-1" || got.body[len(got.body)-1] != '\n' {
+		t.Errorf("body = %q (%v)", got.body, err)
+	}
+}
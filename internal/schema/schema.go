@@ -0,0 +1,613 @@
+// Package schema validates decoded JSON against a JSON Schema. It covers
+// the keywords that describe the shape of a payload:
+//
+//	type, enum, const
+//	properties, required, additionalProperties, minProperties, maxProperties
+//	items, minItems, maxItems, uniqueItems
+//	minimum, maximum, exclusiveMinimum, exclusiveMaximum, multipleOf
+//	minLength, maxLength, pattern
+//	allOf, anyOf, oneOf, not
+//	$ref to a JSON pointer within the same document, e.g. "#/$defs/check"
+//
+// Other keywords, such as format or $schema, are accepted and ignored.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema is a compiled schema, safe for concurrent use
+type Schema struct {
+	root *node
+}
+
+// Load reads and compiles the schema in the file at path
+func Load(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Compile(data)
+}
+
+// Compile parses a JSON Schema document
+func Compile(data []byte) (*Schema, error) {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("schema is not valid JSON: %w", err)
+	}
+	c := &compiler{doc: doc, refs: make(map[string]*node)}
+	root, err := c.compile(doc, "#")
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkLoops(root); err != nil {
+		return nil, err
+	}
+	return &Schema{root: root}, nil
+}
+
+// Validate returns a description of each way doc violates the schema,
+// prefixed with the dotted path of the offending value ("$" is the root)
+func (s *Schema) Validate(doc interface{}) []string {
+	var errs []string
+	s.root.validate(doc, "$", &errs)
+	return errs
+}
+
+// node is one compiled (sub)schema
+type node struct {
+	// always is set for the boolean schemas true and false
+	always *bool
+	ref    *node
+
+	types    []string
+	enum     []interface{}
+	constant interface{}
+	hasConst bool
+
+	properties    map[string]*node
+	required      []string
+	additional    *node
+	minProperties int
+	maxProperties int
+
+	items       *node
+	minItems    int
+	maxItems    int
+	uniqueItems bool
+
+	minimum, maximum                   *float64
+	exclusiveMinimum, exclusiveMaximum *float64
+	multipleOf                         float64
+
+	minLength, maxLength int
+	pattern              *regexp.Regexp
+
+	allOf, anyOf, oneOf []*node
+	not                 *node
+}
+
+type compiler struct {
+	doc  interface{}
+	refs map[string]*node
+}
+
+func (c *compiler) compile(raw interface{}, at string) (*node, error) {
+	n := &node{minProperties: -1, maxProperties: -1, minItems: -1, maxItems: -1, minLength: -1, maxLength: -1}
+	if b, ok := raw.(bool); ok {
+		n.always = &b
+		return n, nil
+	}
+	obj, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: schema must be an object or boolean", at)
+	}
+
+	if ref, ok := obj["$ref"].(string); ok {
+		target, err := c.resolve(ref)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", at, err)
+		}
+		n.ref = target
+	}
+
+	var err error
+	for key, value := range obj {
+		here := at + "/" + key
+		switch key {
+		case "type":
+			n.types, err = stringList(value, here)
+		case "enum":
+			list, ok := value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s: must be an array", here)
+			}
+			n.enum = list
+		case "const":
+			n.constant, n.hasConst = value, true
+		case "properties":
+			props, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s: must be an object", here)
+			}
+			n.properties = make(map[string]*node, len(props))
+			for name, sub := range props {
+				if n.properties[name], err = c.compile(sub, here+"/"+name); err != nil {
+					return nil, err
+				}
+			}
+		case "required":
+			n.required, err = stringList(value, here)
+		case "additionalProperties":
+			n.additional, err = c.compile(value, here)
+		case "items":
+			n.items, err = c.compile(value, here)
+		case "not":
+			n.not, err = c.compile(value, here)
+		case "allOf", "anyOf", "oneOf":
+			var list []*node
+			if list, err = c.compileList(value, here); err == nil {
+				switch key {
+				case "allOf":
+					n.allOf = list
+				case "anyOf":
+					n.anyOf = list
+				default:
+					n.oneOf = list
+				}
+			}
+		case "minProperties":
+			n.minProperties, err = count(value, here)
+		case "maxProperties":
+			n.maxProperties, err = count(value, here)
+		case "minItems":
+			n.minItems, err = count(value, here)
+		case "maxItems":
+			n.maxItems, err = count(value, here)
+		case "minLength":
+			n.minLength, err = count(value, here)
+		case "maxLength":
+			n.maxLength, err = count(value, here)
+		case "uniqueItems":
+			n.uniqueItems, _ = value.(bool)
+		case "minimum":
+			n.minimum, err = number(value, here)
+		case "maximum":
+			n.maximum, err = number(value, here)
+		case "exclusiveMinimum", "exclusiveMaximum":
+			// Draft 4 spells these as booleans modifying minimum/maximum
+			if _, draft4 := value.(bool); draft4 {
+				continue
+			}
+			if key == "exclusiveMinimum" {
+				n.exclusiveMinimum, err = number(value, here)
+			} else {
+				n.exclusiveMaximum, err = number(value, here)
+			}
+		case "multipleOf":
+			var m *float64
+			if m, err = number(value, here); err == nil {
+				if *m <= 0 {
+					return nil, fmt.Errorf("%s: must be positive", here)
+				}
+				n.multipleOf = *m
+			}
+		case "pattern":
+			p, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: must be a string", here)
+			}
+			if n.pattern, err = regexp.Compile(p); err != nil {
+				return nil, fmt.Errorf("%s: %w", here, err)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if obj["exclusiveMinimum"] == true && n.minimum != nil {
+		n.exclusiveMinimum, n.minimum = n.minimum, nil
+	}
+	if obj["exclusiveMaximum"] == true && n.maximum != nil {
+		n.exclusiveMaximum, n.maximum = n.maximum, nil
+	}
+	return n, nil
+}
+
+func (c *compiler) compileList(value interface{}, at string) ([]*node, error) {
+	raw, ok := value.([]interface{})
+	if !ok || len(raw) == 0 {
+		return nil, fmt.Errorf("%s: must be a non-empty array", at)
+	}
+	list := make([]*node, len(raw))
+	for i, sub := range raw {
+		var err error
+		if list[i], err = c.compile(sub, fmt.Sprintf("%s/%d", at, i)); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// resolve compiles the target of a local $ref. The node is registered
+// before compiling so recursive schemas refer back to it.
+func (c *compiler) resolve(ref string) (*node, error) {
+	if n, ok := c.refs[ref]; ok {
+		return n, nil
+	}
+	if ref != "#" && !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("$ref %q: only references within the schema (#/...) are supported", ref)
+	}
+
+	target := c.doc
+	if ref != "#" {
+		for _, token := range strings.Split(ref[2:], "/") {
+			token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+			switch t := target.(type) {
+			case map[string]interface{}:
+				target = t[token]
+			case []interface{}:
+				i, err := strconv.Atoi(token)
+				if err != nil || i < 0 || i >= len(t) {
+					return nil, fmt.Errorf("$ref %q not found", ref)
+				}
+				target = t[i]
+			default:
+				target = nil
+			}
+			if target == nil {
+				return nil, fmt.Errorf("$ref %q not found", ref)
+			}
+		}
+	}
+
+	n := &node{}
+	c.refs[ref] = n
+	compiled, err := c.compile(target, ref)
+	if err != nil {
+		return nil, err
+	}
+	*n = *compiled
+	return n, nil
+}
+
+// checkLoops rejects $refs that lead back to themselves without moving on
+// to a part of the value, like {"$ref": "#"} at the root, which would
+// validate forever. Only $ref and the applicators allOf, anyOf, oneOf and
+// not stay on the same value; properties and items descend into it, so
+// recursion through them ends with the value.
+func (c *compiler) checkLoops(root *node) error {
+	const (
+		onPath = 1
+		done   = 2
+	)
+	state := make(map[*node]int)
+	pending := []*node{root}
+
+	var visit func(n *node) *node
+	visit = func(n *node) *node {
+		switch state[n] {
+		case onPath:
+			return n
+		case done:
+			return nil
+		}
+		state[n] = onPath
+		for _, next := range n.inPlace() {
+			if looped := visit(next); looped != nil {
+				return looped
+			}
+		}
+		state[n] = done
+		// Nested schemas start a path of their own once this one is done
+		pending = append(pending, n.nested()...)
+		return nil
+	}
+
+	for len(pending) > 0 {
+		n := pending[0]
+		pending = pending[1:]
+		looped := visit(n)
+		if looped == nil {
+			continue
+		}
+		// A loop can only be entered through a $ref, so looped has a name
+		for ref, target := range c.refs {
+			if target == looped {
+				return fmt.Errorf("$ref %q refers back to itself without descending into the value", ref)
+			}
+		}
+		return fmt.Errorf("$ref loop without descending into the value")
+	}
+	return nil
+}
+
+// inPlace returns the subschemas n applies to the value it validates
+func (n *node) inPlace() []*node {
+	list := append(append(append([]*node(nil), n.allOf...), n.anyOf...), n.oneOf...)
+	for _, sub := range []*node{n.ref, n.not} {
+		if sub != nil {
+			list = append(list, sub)
+		}
+	}
+	return list
+}
+
+// nested returns the subschemas n applies to parts of the value
+func (n *node) nested() []*node {
+	var list []*node
+	for _, sub := range n.properties {
+		list = append(list, sub)
+	}
+	for _, sub := range []*node{n.additional, n.items} {
+		if sub != nil {
+			list = append(list, sub)
+		}
+	}
+	return list
+}
+
+func stringList(value interface{}, at string) ([]string, error) {
+	if s, ok := value.(string); ok {
+		return []string{s}, nil
+	}
+	raw, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: must be a string or array of strings", at)
+	}
+	list := make([]string, len(raw))
+	for i, item := range raw {
+		if list[i], ok = item.(string); !ok {
+			return nil, fmt.Errorf("%s: must be a string or array of strings", at)
+		}
+	}
+	return list, nil
+}
+
+func count(value interface{}, at string) (int, error) {
+	f, ok := value.(float64)
+	if !ok || f < 0 || f != math.Trunc(f) {
+		return 0, fmt.Errorf("%s: must be a non-negative integer", at)
+	}
+	return int(f), nil
+}
+
+func number(value interface{}, at string) (*float64, error) {
+	f, ok := value.(float64)
+	if !ok {
+		return nil, fmt.Errorf("%s: must be a number", at)
+	}
+	return &f, nil
+}
+
+// Validation
+
+func (n *node) validate(v interface{}, path string, errs *[]string) {
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if n.always != nil {
+		if !*n.always {
+			fail("not allowed")
+		}
+		return
+	}
+	if n.ref != nil {
+		n.ref.validate(v, path, errs)
+	}
+
+	if len(n.types) > 0 && !matchesType(v, n.types) {
+		fail("expected %s, got %s", strings.Join(n.types, " or "), typeOf(v))
+		return
+	}
+	if n.hasConst && !equal(v, n.constant) {
+		fail("must be %s", encode(n.constant))
+	}
+	if n.enum != nil {
+		found := false
+		for _, candidate := range n.enum {
+			if equal(v, candidate) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("%s is not one of %s", encode(v), encode(n.enum))
+		}
+	}
+
+	switch value := v.(type) {
+	case map[string]interface{}:
+		n.validateObject(value, path, fail, errs)
+	case []interface{}:
+		n.validateArray(value, path, fail, errs)
+	case float64:
+		n.validateNumber(value, fail)
+	case string:
+		length := utf8.RuneCountInString(value)
+		if n.minLength >= 0 && length < n.minLength {
+			fail("length %d is under the minimum of %d", length, n.minLength)
+		}
+		if n.maxLength >= 0 && length > n.maxLength {
+			fail("length %d is over the maximum of %d", length, n.maxLength)
+		}
+		if n.pattern != nil && !n.pattern.MatchString(value) {
+			fail("%q does not match pattern %q", value, n.pattern)
+		}
+	}
+
+	for _, sub := range n.allOf {
+		sub.validate(v, path, errs)
+	}
+	if len(n.anyOf) > 0 && countValid(n.anyOf, v) == 0 {
+		fail("matches none of the anyOf schemas")
+	}
+	if len(n.oneOf) > 0 {
+		if matched := countValid(n.oneOf, v); matched != 1 {
+			fail("matches %d of the oneOf schemas, want exactly 1", matched)
+		}
+	}
+	if n.not != nil && countValid([]*node{n.not}, v) == 1 {
+		fail("must not match the schema in not")
+	}
+}
+
+func (n *node) validateObject(obj map[string]interface{}, path string, fail func(string, ...interface{}), errs *[]string) {
+	for _, name := range n.required {
+		if _, ok := obj[name]; !ok {
+			fail("missing required property %q", name)
+		}
+	}
+	if n.minProperties >= 0 && len(obj) < n.minProperties {
+		fail("has %d properties, want at least %d", len(obj), n.minProperties)
+	}
+	if n.maxProperties >= 0 && len(obj) > n.maxProperties {
+		fail("has %d properties, want at most %d", len(obj), n.maxProperties)
+	}
+
+	// Sorted so errors come out in a stable order
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if sub, ok := n.properties[name]; ok {
+			sub.validate(obj[name], path+"."+name, errs)
+		} else if n.additional != nil {
+			n.additional.validate(obj[name], path+"."+name, errs)
+		}
+	}
+}
+
+func (n *node) validateArray(list []interface{}, path string, fail func(string, ...interface{}), errs *[]string) {
+	if n.minItems >= 0 && len(list) < n.minItems {
+		fail("has %d items, want at least %d", len(list), n.minItems)
+	}
+	if n.maxItems >= 0 && len(list) > n.maxItems {
+		fail("has %d items, want at most %d", len(list), n.maxItems)
+	}
+	if n.uniqueItems {
+		for i := range list {
+			for j := i + 1; j < len(list); j++ {
+				if equal(list[i], list[j]) {
+					fail("items %d and %d are equal", i, j)
+				}
+			}
+		}
+	}
+	if n.items != nil {
+		for i, item := range list {
+			n.items.validate(item, fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	}
+}
+
+func (n *node) validateNumber(f float64, fail func(string, ...interface{})) {
+	if n.minimum != nil && f < *n.minimum {
+		fail("%v is under the minimum of %v", f, *n.minimum)
+	}
+	if n.maximum != nil && f > *n.maximum {
+		fail("%v is over the maximum of %v", f, *n.maximum)
+	}
+	if n.exclusiveMinimum != nil && f <= *n.exclusiveMinimum {
+		fail("%v must be greater than %v", f, *n.exclusiveMinimum)
+	}
+	if n.exclusiveMaximum != nil && f >= *n.exclusiveMaximum {
+		fail("%v must be less than %v", f, *n.exclusiveMaximum)
+	}
+	if n.multipleOf > 0 {
+		if q := f / n.multipleOf; math.Abs(q-math.Round(q)) > 1e-9 {
+			fail("%v is not a multiple of %v", f, n.multipleOf)
+		}
+	}
+}
+
+func countValid(list []*node, v interface{}) int {
+	valid := 0
+	for _, sub := range list {
+		var errs []string
+		sub.validate(v, "", &errs)
+		if len(errs) == 0 {
+			valid++
+		}
+	}
+	return valid
+}
+
+func matchesType(v interface{}, types []string) bool {
+	actual := typeOf(v)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func typeOf(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if value == math.Trunc(value) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// equal compares decoded JSON values structurally
+func equal(a, b interface{}) bool {
+	switch x := a.(type) {
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for key, value := range x {
+			other, ok := y[key]
+			if !ok || !equal(value, other) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+func encode(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
@@ -0,0 +1,127 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestValidate checks each keyword against a passing and a failing value
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		doc    string
+		want   []string
+	}{
+		{"type", `{"type": "string"}`, `"ok"`, nil},
+		{"type mismatch", `{"type": "string"}`, `1`, []string{"$: expected string, got integer"}},
+		{"type list", `{"type": ["string", "null"]}`, `null`, nil},
+		{"integer is a number", `{"type": "number"}`, `3`, nil},
+		{"number is not an integer", `{"type": "integer"}`, `3.5`, []string{"$: expected integer, got number"}},
+
+		{"required", `{"required": ["id", "name"]}`, `{"id": 1, "name": "x"}`, nil},
+		{"required missing", `{"required": ["id", "name"]}`, `{"id": 1}`, []string{`$: missing required property "name"`}},
+		{"required ignores non-objects", `{"required": ["id"]}`, `[]`, nil},
+
+		{"enum", `{"enum": ["up", "down", 0]}`, `"down"`, nil},
+		{"enum miss", `{"enum": ["up", "down"]}`, `"sideways"`, []string{`$: "sideways" is not one of ["up","down"]`}},
+		{"enum compares structurally", `{"enum": [{"a": [1]}]}`, `{"a": [1]}`, nil},
+		{"const", `{"const": 3}`, `4`, []string{"$: must be 3"}},
+
+		{"minimum", `{"minimum": 1, "maximum": 5}`, `1`, nil},
+		{"under minimum", `{"minimum": 1}`, `0.5`, []string{"$: 0.5 is under the minimum of 1"}},
+		{"over maximum", `{"maximum": 5}`, `6`, []string{"$: 6 is over the maximum of 5"}},
+		{"exclusive minimum", `{"exclusiveMinimum": 1}`, `1`, []string{"$: 1 must be greater than 1"}},
+		{"draft 4 exclusive maximum", `{"maximum": 5, "exclusiveMaximum": true}`, `5`, []string{"$: 5 must be less than 5"}},
+		{"multipleOf", `{"multipleOf": 0.1}`, `0.3`, nil},
+		{"not a multiple", `{"multipleOf": 2}`, `3`, []string{"$: 3 is not a multiple of 2"}},
+		{"string length", `{"minLength": 2, "maxLength": 3}`, `"héé"`, nil},
+		{"string too long", `{"maxLength": 3}`, `"abcd"`, []string{"$: length 4 is over the maximum of 3"}},
+
+		{"pattern", `{"pattern": "^v[0-9]+$"}`, `"v12"`, nil},
+		{"pattern miss", `{"pattern": "^v[0-9]+$"}`, `"12"`, []string{`$: "12" does not match pattern "^v[0-9]+$"`}},
+		{"pattern is unanchored", `{"pattern": "[0-9]"}`, `"build 7 ok"`, nil},
+
+		{"items", `{"items": {"type": "integer"}, "minItems": 1}`, `[1, 2]`, nil},
+		{"items mismatch", `{"items": {"type": "integer"}}`, `[1, "b", 3, null]`, []string{"$[1]: expected integer, got string", "$[3]: expected integer, got null"}},
+		{"too few items", `{"minItems": 2}`, `[1]`, []string{"$: has 1 items, want at least 2"}},
+		{"unique items", `{"uniqueItems": true}`, `[1, {"a": 1}, {"a": 1}]`, []string{"$: items 1 and 2 are equal"}},
+
+		{"nested properties", `{"properties": {"meta": {"properties": {"v": {"type": "string"}}}}}`, `{"meta": {"v": 1}}`, []string{"$.meta.v: expected string, got integer"}},
+		{"additionalProperties false", `{"properties": {"id": {}}, "additionalProperties": false}`, `{"id": 1, "x": 2, "y": 3}`, []string{"$.x: not allowed", "$.y: not allowed"}},
+		{"additionalProperties schema", `{"additionalProperties": {"type": "number"}}`, `{"a": 1, "b": "2"}`, []string{"$.b: expected number, got string"}},
+		{"additionalProperties unset", `{"properties": {"id": {}}}`, `{"id": 1, "x": 2}`, nil},
+
+		{"$ref", `{"$defs": {"id": {"type": "integer"}}, "properties": {"id": {"$ref": "#/$defs/id"}}}`, `{"id": "x"}`, []string{"$.id: expected integer, got string"}},
+		{"$ref with escaped pointer", `{"definitions": {"a/b": {"const": 1}}, "$ref": "#/definitions/a~1b"}`, `2`, []string{"$: must be 1"}},
+		{"$ref alongside keywords", `{"$defs": {"s": {"type": "string"}}, "$ref": "#/$defs/s", "minLength": 3}`, `"ab"`, []string{"$: length 2 is under the minimum of 3"}},
+		{
+			"recursive $ref through properties",
+			`{"$defs": {"tree": {"type": "object", "properties": {"name": {"type": "string"}, "children": {"type": "array", "items": {"$ref": "#/$defs/tree"}}}}}, "$ref": "#/$defs/tree"}`,
+			`{"name": "a", "children": [{"name": "b", "children": [{"name": 3}]}]}`,
+			[]string{"$.children[0].children[0].name: expected string, got integer"},
+		},
+		{"recursive root $ref", `{"items": {"$ref": "#"}, "type": "array"}`, `[[[]], [1]]`, []string{"$[1][0]: expected array, got integer"}},
+
+		{"anyOf", `{"anyOf": [{"type": "string"}, {"minimum": 0}]}`, `-1`, []string{"$: matches none of the anyOf schemas"}},
+		{"oneOf", `{"oneOf": [{"type": "integer"}, {"minimum": 0}]}`, `2`, []string{"$: matches 2 of the oneOf schemas, want exactly 1"}},
+		{"not", `{"not": {"type": "null"}}`, `null`, []string{"$: must not match the schema in not"}},
+		{"false schema", `false`, `{}`, []string{"$: not allowed"}},
+		{"unknown keywords are ignored", `{"format": "uuid", "$schema": "x"}`, `"not a uuid"`, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Compile([]byte(tt.schema))
+			if err != nil {
+				t.Fatalf("Compile: %v", err)
+			}
+			var doc interface{}
+			if err := json.Unmarshal([]byte(tt.doc), &doc); err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Validate(doc); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate(%s) = %q, want %q", tt.doc, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCompileErrors covers malformed schemas, including $refs that would
+// loop forever during validation
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		want   string
+	}{
+		{"not JSON", `{"type":`, "schema is not valid JSON"},
+		{"not a schema", `[]`, "#: schema must be an object or boolean"},
+		{"bad type", `{"type": 1}`, "#/type: must be a string or array of strings"},
+		{"bad enum", `{"enum": "a"}`, "#/enum: must be an array"},
+		{"bad count", `{"minItems": -1}`, "#/minItems: must be a non-negative integer"},
+		{"bad multipleOf", `{"multipleOf": 0}`, "#/multipleOf: must be positive"},
+		{"bad pattern", `{"properties": {"v": {"pattern": "("}}}`, "#/properties/v/pattern: error parsing regexp"},
+		{"empty anyOf", `{"anyOf": []}`, "#/anyOf: must be a non-empty array"},
+		{"remote $ref", `{"$ref": "https://example.com/s.json"}`, "only references within the schema"},
+		{"missing $ref", `{"$ref": "#/$defs/nope"}`, `$ref "#/$defs/nope" not found`},
+
+		{"cyclic $ref", `{"$defs": {"a": {"$ref": "#/$defs/a"}}, "$ref": "#/$defs/a"}`, `$ref "#/$defs/a" refers back to itself`},
+		{"root refers to itself", `{"$ref": "#"}`, `$ref "#" refers back to itself`},
+		{"mutual $refs", `{"$defs": {"a": {"$ref": "#/$defs/b"}, "b": {"$ref": "#/$defs/a"}}, "$ref": "#/$defs/a"}`, "refers back to itself"},
+		{"cycle through allOf", `{"$defs": {"a": {"allOf": [{"type": "object"}, {"$ref": "#/$defs/a"}]}}, "$ref": "#/$defs/a"}`, `$ref "#/$defs/a" refers back to itself`},
+		{"cycle through not", `{"$defs": {"a": {"not": {"$ref": "#/$defs/a"}}}, "properties": {"x": {"$ref": "#/$defs/a"}}}`, `$ref "#/$defs/a" refers back to itself`},
+		{"cycle below items", `{"items": {"anyOf": [{"$ref": "#/items"}]}}`, `$ref "#/items" refers back to itself`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile([]byte(tt.schema))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Compile error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,288 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/config"
+	"github.com/orchard9/watch-now/internal/monitors"
+)
+
+const scopeName = "github.com/orchard9/watch-now"
+
+// Span status codes from the OTLP trace schema
+const (
+	spanStatusOK    = 1
+	spanStatusError = 2
+)
+
+// statusValues is the watch_now.check.status gauge value for each status
+var statusValues = map[monitors.Status]float64{
+	monitors.StatusOK:   0,
+	monitors.StatusWarn: 1,
+	monitors.StatusFail: 2,
+	monitors.StatusInfo: 3,
+}
+
+// Exporter batches one span per check and the latest duration and status
+// of every monitor, and posts them to the collector each interval. It
+// speaks OTLP/HTTP with JSON encoding, so it needs no SDK or protobuf.
+type Exporter struct {
+	cfg    config.OTelConfig
+	client *http.Client
+
+	mu     sync.Mutex
+	spans  []span
+	latest map[string]sample
+}
+
+// sample is the part of a result the metrics report
+type sample struct {
+	name     string
+	typ      monitors.MonitorType
+	status   monitors.Status
+	duration time.Duration
+}
+
+func New(cfg config.OTelConfig) *Exporter {
+	return &Exporter{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+		latest: make(map[string]sample),
+	}
+}
+
+// Record queues a finished check. It is safe for concurrent use and never
+// blocks on the network.
+func (e *Exporter) Record(result *monitors.Result, start, end time.Time) {
+	attrs := []attribute{
+		stringAttr("watch_now.monitor.name", result.Name),
+		stringAttr("watch_now.monitor.type", string(result.Type)),
+		stringAttr("watch_now.check.status", string(result.Status)),
+		doubleAttr("watch_now.check.duration_ms", durationMs(result.Duration)),
+	}
+	for key, value := range result.Labels {
+		attrs = append(attrs, stringAttr("watch_now.label."+key, value))
+	}
+
+	status := spanStatus{Code: spanStatusOK}
+	if result.Status == monitors.StatusFail {
+		status = spanStatus{Code: spanStatusError, Message: result.Message}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, span{
+		TraceID:    randomID(16),
+		SpanID:     randomID(8),
+		Name:       "check " + result.Name,
+		Kind:       1, // internal
+		Start:      nanos(start),
+		End:        nanos(end),
+		Attributes: attrs,
+		Status:     status,
+	})
+	e.latest[result.Name] = sample{name: result.Name, typ: result.Type, status: result.Status, duration: result.Duration}
+}
+
+// Prune stops exporting gauges for monitors not in current, such as
+// discovered services that went away
+func (e *Exporter) Prune(current map[string]bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for name := range e.latest {
+		if !current[name] {
+			delete(e.latest, name)
+		}
+	}
+}
+
+// Run exports on every interval until ctx is done, then flushes once more
+func (e *Exporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			e.Flush()
+			return
+		case <-ticker.C:
+			e.Flush()
+		}
+	}
+}
+
+// Flush sends everything recorded so far
+func (e *Exporter) Flush() {
+	e.mu.Lock()
+	spans := e.spans
+	e.spans = nil
+	latest := make([]sample, 0, len(e.latest))
+	for _, last := range e.latest {
+		latest = append(latest, last)
+	}
+	e.mu.Unlock()
+
+	if len(spans) > 0 {
+		if err := e.post("/v1/traces", e.tracesPayload(spans)); err != nil {
+			log.Printf("OTel trace export failed: %v", err)
+		}
+	}
+	if len(latest) > 0 {
+		if err := e.post("/v1/metrics", e.metricsPayload(latest)); err != nil {
+			log.Printf("OTel metric export failed: %v", err)
+		}
+	}
+}
+
+func (e *Exporter) post(path string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(e.cfg.Endpoint, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.cfg.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s: unexpected status %d", path, resp.StatusCode)
+	}
+	return nil
+}
+
+func (e *Exporter) resource() resource {
+	return resource{Attributes: []attribute{stringAttr("service.name", e.cfg.ServiceName)}}
+}
+
+func (e *Exporter) tracesPayload(spans []span) interface{} {
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": e.resource(),
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]string{"name": scopeName},
+				"spans": spans,
+			}},
+		}},
+	}
+}
+
+func (e *Exporter) metricsPayload(latest []sample) interface{} {
+	now := nanos(time.Now())
+	var durations, statuses []dataPoint
+	for _, last := range latest {
+		attrs := []attribute{
+			stringAttr("watch_now.monitor.name", last.name),
+			stringAttr("watch_now.monitor.type", string(last.typ)),
+		}
+		durations = append(durations, dataPoint{Time: now, Value: durationMs(last.duration), Attributes: attrs})
+		statusAttrs := append(attrs[:len(attrs):len(attrs)], stringAttr("watch_now.check.status", string(last.status)))
+		statuses = append(statuses, dataPoint{Time: now, Value: statusValues[last.status], Attributes: statusAttrs})
+	}
+
+	return map[string]interface{}{
+		"resourceMetrics": []interface{}{map[string]interface{}{
+			"resource": e.resource(),
+			"scopeMetrics": []interface{}{map[string]interface{}{
+				"scope": map[string]string{"name": scopeName},
+				"metrics": []metric{
+					{Name: "watch_now.check.duration", Unit: "ms", Description: "Duration of the latest check", Gauge: gauge{DataPoints: durations}},
+					{Name: "watch_now.check.status", Description: "Latest status: 0 ok, 1 warn, 2 fail, 3 info", Gauge: gauge{DataPoints: statuses}},
+				},
+			}},
+		}},
+	}
+}
+
+// The types below follow the OTLP JSON encoding: 64-bit integers are
+// strings and trace/span IDs are hex.
+
+type resource struct {
+	Attributes []attribute `json:"attributes"`
+}
+
+type span struct {
+	TraceID    string      `json:"traceId"`
+	SpanID     string      `json:"spanId"`
+	Name       string      `json:"name"`
+	Kind       int         `json:"kind"`
+	Start      string      `json:"startTimeUnixNano"`
+	End        string      `json:"endTimeUnixNano"`
+	Attributes []attribute `json:"attributes"`
+	Status     spanStatus  `json:"status"`
+}
+
+type spanStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type metric struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Unit        string `json:"unit,omitempty"`
+	Gauge       gauge  `json:"gauge"`
+}
+
+type gauge struct {
+	DataPoints []dataPoint `json:"dataPoints"`
+}
+
+type dataPoint struct {
+	Time       string      `json:"timeUnixNano"`
+	Value      float64     `json:"asDouble"`
+	Attributes []attribute `json:"attributes"`
+}
+
+type attribute struct {
+	Key   string         `json:"key"`
+	Value attributeValue `json:"value"`
+}
+
+type attributeValue struct {
+	String *string  `json:"stringValue,omitempty"`
+	Double *float64 `json:"doubleValue,omitempty"`
+}
+
+func stringAttr(key, value string) attribute {
+	return attribute{Key: key, Value: attributeValue{String: &value}}
+}
+
+func doubleAttr(key string, value float64) attribute {
+	return attribute{Key: key, Value: attributeValue{Double: &value}}
+}
+
+func nanos(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func randomID(size int) string {
+	id := make([]byte, size)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,184 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/config"
+	"github.com/orchard9/watch-now/internal/monitors"
+)
+
+// Pusher sends the latest result of every monitor to a Prometheus
+// Pushgateway in the text exposition format.
+type Pusher struct {
+	url    string
+	client *http.Client
+
+	// pending is the newest snapshot Run has yet to push; wake tells Run
+	// there is one. Snapshots queued while a push is under way replace
+	// each other, so a slow gateway gets only the latest.
+	mu      sync.Mutex
+	pending map[string]*monitors.Result
+	wake    chan struct{}
+
+	// pushing keeps Run and a final Push from overtaking each other
+	pushing sync.Mutex
+}
+
+func NewPusher(cfg config.MetricsConfig) *Pusher {
+	return &Pusher{
+		url:    strings.TrimSuffix(cfg.PushgatewayURL, "/") + "/metrics/job/" + url.PathEscape(cfg.Job),
+		client: &http.Client{Timeout: 10 * time.Second},
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Update queues results for Run to push. It never blocks on the network.
+func (p *Pusher) Update(results map[string]*monitors.Result) {
+	p.mu.Lock()
+	p.pending = results
+	p.mu.Unlock()
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run pushes queued snapshots until ctx is done
+func (p *Pusher) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.wake:
+		}
+
+		p.pushing.Lock()
+		p.mu.Lock()
+		results := p.pending
+		p.pending = nil
+		p.mu.Unlock()
+		if results != nil {
+			p.push(results)
+		}
+		p.pushing.Unlock()
+	}
+}
+
+// Push replaces the job's metrics with results right away, superseding
+// anything queued, for runs about to exit
+func (p *Pusher) Push(results map[string]*monitors.Result) {
+	p.pushing.Lock()
+	defer p.pushing.Unlock()
+	p.mu.Lock()
+	p.pending = nil
+	p.mu.Unlock()
+	p.push(results)
+}
+
+// push replaces the job's metrics with the given results. Failures are
+// logged; the next cycle pushes again.
+func (p *Pusher) push(results map[string]*monitors.Result) {
+	// PUT replaces the whole group, so removed monitors disappear too
+	req, err := http.NewRequest(http.MethodPut, p.url, bytes.NewReader(exposition(results)))
+	if err != nil {
+		log.Printf("Pushgateway push failed: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		log.Printf("Pushgateway push failed: %v", err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("Pushgateway push failed: unexpected status %d", resp.StatusCode)
+	}
+}
+
+// exposition renders watch_now_monitor_up (0 only while FAIL) and
+// watch_now_check_duration_seconds for each monitor, sorted by name
+func exposition(results map[string]*monitors.Result) []byte {
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var up, duration bytes.Buffer
+	up.WriteString("# HELP watch_now_monitor_up Whether the monitor's latest check is not failing.\n")
+	up.WriteString("# TYPE watch_now_monitor_up gauge\n")
+	duration.WriteString("# HELP watch_now_check_duration_seconds Duration of the monitor's latest check.\n")
+	duration.WriteString("# TYPE watch_now_check_duration_seconds gauge\n")
+	for _, name := range names {
+		result := results[name]
+		labels := promLabels(name, result)
+		value := 1
+		if result.Status == monitors.StatusFail {
+			value = 0
+		}
+		fmt.Fprintf(&up, "watch_now_monitor_up%s %d\n", labels, value)
+		fmt.Fprintf(&duration, "watch_now_check_duration_seconds%s %g\n", labels, result.Duration.Seconds())
+	}
+	return append(up.Bytes(), duration.Bytes()...)
+}
+
+// promLabels renders the monitor and type labels followed by the
+// monitor's configured labels, sorted, with names sanitized for
+// Prometheus. Labels that would clash with monitor, type, a reserved __
+// name or an earlier label are left out.
+func promLabels(name string, result *monitors.Result) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `{monitor="%s",type="%s"`, escapeLabel(name), escapeLabel(string(result.Type)))
+
+	keys := make([]string, 0, len(result.Labels))
+	for key := range result.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	seen := map[string]bool{"monitor": true, "type": true}
+	for _, key := range keys {
+		label := sanitizeLabelName(key)
+		if seen[label] || strings.HasPrefix(label, "__") {
+			continue
+		}
+		seen[label] = true
+		fmt.Fprintf(&b, `,%s="%s"`, label, escapeLabel(result.Labels[key]))
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// sanitizeLabelName maps a name onto [a-zA-Z_][a-zA-Z0-9_]*, replacing
+// anything else with an underscore
+func sanitizeLabelName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case r == '_', 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', i > 0 && '0' <= r && r <= '9':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
@@ -0,0 +1,144 @@
+package telemetry
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/config"
+	"github.com/orchard9/watch-now/internal/monitors"
+)
+
+// TestExposition renders both gauges for every monitor, sorted by name,
+// with up at 0 only for FAIL
+func TestExposition(t *testing.T) {
+	results := map[string]*monitors.Result{
+		"web":  {Name: "web", Type: monitors.TypeREST, Status: monitors.StatusFail, Duration: 1500 * time.Millisecond, Labels: map[string]string{"team": "core"}},
+		"db":   {Name: "db", Type: monitors.TypeTCP, Status: monitors.StatusWarn, Duration: 20 * time.Millisecond},
+		"lint": {Name: "lint", Type: monitors.TypeQuality, Status: monitors.StatusOK, Duration: 3 * time.Second},
+	}
+
+	want := `# HELP watch_now_monitor_up Whether the monitor's latest check is not failing.
+# TYPE watch_now_monitor_up gauge
+watch_now_monitor_up{monitor="db",type="tcp"} 1
+watch_now_monitor_up{monitor="lint",type="quality"} 1
+watch_now_monitor_up{monitor="web",type="rest",team="core"} 0
+# HELP watch_now_check_duration_seconds Duration of the monitor's latest check.
+# TYPE watch_now_check_duration_seconds gauge
+watch_now_check_duration_seconds{monitor="db",type="tcp"} 0.02
+watch_now_check_duration_seconds{monitor="lint",type="quality"} 3
+watch_now_check_duration_seconds{monitor="web",type="rest",team="core"} 1.5
+`
+	if got := string(exposition(results)); got != want {
+		t.Errorf("exposition =\n%s\nwant\n%s", got, want)
+	}
+}
+
+// TestPromLabels checks escaping of label values and sanitizing of names
+func TestPromLabels(t *testing.T) {
+	tests := []struct {
+		name   string
+		result monitors.Result
+		want   string
+	}{
+		{
+			name:   "no labels",
+			result: monitors.Result{Name: "api", Type: monitors.TypeREST},
+			want:   `{monitor="api",type="rest"}`,
+		},
+		{
+			name:   "labels sorted",
+			result: monitors.Result{Name: "api", Type: monitors.TypeREST, Labels: map[string]string{"team": "core", "env": "prod"}},
+			want:   `{monitor="api",type="rest",env="prod",team="core"}`,
+		},
+		{
+			name:   "escaped monitor name",
+			result: monitors.Result{Name: `say "hi"\now` + "\nthen", Type: monitors.TypeQuality},
+			want:   `{monitor="say \"hi\"\\now\nthen",type="quality"}`,
+		},
+		{
+			name:   "escaped label value",
+			result: monitors.Result{Name: "api", Type: monitors.TypeREST, Labels: map[string]string{"path": `C:\logs`, "note": "a \"b\"\nc"}},
+			want:   `{monitor="api",type="rest",note="a \"b\"\nc",path="C:\\logs"}`,
+		},
+		{
+			name:   "sanitized names",
+			result: monitors.Result{Name: "api", Type: monitors.TypeREST, Labels: map[string]string{"team-name": "a", "1st": "b", "région": "c"}},
+			want:   `{monitor="api",type="rest",_st="b",r_gion="c",team_name="a"}`,
+		},
+		{
+			name:   "names that clash after sanitizing keep the first",
+			result: monitors.Result{Name: "api", Type: monitors.TypeREST, Labels: map[string]string{"team.name": "b", "team-name": "a"}},
+			want:   `{monitor="api",type="rest",team_name="a"}`,
+		},
+		{
+			name:   "built-in and reserved names are left out",
+			result: monitors.Result{Name: "api", Type: monitors.TypeREST, Labels: map[string]string{"monitor": "x", "type": "y", "__name__": "z", "Monitor": "ok"}},
+			want:   `{monitor="api",type="rest",Monitor="ok"}`,
+		},
+		{
+			name:   "empty name",
+			result: monitors.Result{Name: "api", Type: monitors.TypeREST, Labels: map[string]string{"": "v"}},
+			want:   `{monitor="api",type="rest",_="v"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := promLabels(tt.result.Name, &tt.result); got != tt.want {
+				t.Errorf("promLabels = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// Updates queued while a push is stuck on a slow gateway collapse into a
+// single push of the newest snapshot, and Update never waits for it
+func TestPusherCoalesces(t *testing.T) {
+	release := make(chan struct{})
+	bodies := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- string(body)
+		<-release
+	}))
+	defer srv.Close()
+
+	p := NewPusher(config.MetricsConfig{PushgatewayURL: srv.URL, Job: "watch-now"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Run(ctx)
+
+	snapshot := func(name string) map[string]*monitors.Result {
+		return map[string]*monitors.Result{name: {Name: name, Type: monitors.TypeTCP, Status: monitors.StatusOK}}
+	}
+
+	p.Update(snapshot("first"))
+	first := <-bodies
+	if !strings.Contains(first, `monitor="first"`) {
+		t.Fatalf("first push = %q", first)
+	}
+
+	start := time.Now()
+	for _, name := range []string{"second", "third", "fourth"} {
+		p.Update(snapshot(name))
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Update blocked for %v behind a slow push", elapsed)
+	}
+
+	close(release)
+	next := <-bodies
+	if !strings.Contains(next, `monitor="fourth"`) {
+		t.Errorf("push after the slow one = %q, want only the newest snapshot", next)
+	}
+	select {
+	case extra := <-bodies:
+		t.Errorf("unexpected extra push %q", extra)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
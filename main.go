@@ -9,6 +9,9 @@ import (
 	"os/signal"
 	"sort"
 	"strings"
+	"sync/atomic"
+	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/fatih/color"
@@ -26,23 +29,92 @@ var (
 	date    = "unknown"
 )
 
+func buildInfo() api.BuildInfo {
+	return api.BuildInfo{Version: version, Commit: commit, Date: date}
+}
+
 // Color helpers
 var (
 	green  = color.New(color.FgGreen)
 	red    = color.New(color.FgRed)
 	yellow = color.New(color.FgYellow)
 	blue   = color.New(color.FgBlue)
+	purple = color.New(color.FgMagenta)
 	bold   = color.New(color.Bold)
 )
 
+// statusStyle is how a status is rendered in the terminal
+type statusStyle struct {
+	color  *color.Color
+	symbol string
+}
+
+var statusStyles = map[monitors.Status]statusStyle{
+	monitors.StatusOK:   {color: green, symbol: "[OK]"},
+	monitors.StatusWarn: {color: yellow, symbol: "[WARN]"},
+	monitors.StatusFail: {color: red, symbol: "[FAIL]"},
+	monitors.StatusInfo: {color: blue, symbol: "[INFO]"},
+}
+
+var colorsByName = map[string]color.Attribute{
+	"black":   color.FgBlack,
+	"red":     color.FgRed,
+	"green":   color.FgGreen,
+	"yellow":  color.FgYellow,
+	"blue":    color.FgBlue,
+	"magenta": color.FgMagenta,
+	"cyan":    color.FgCyan,
+	"white":   color.FgWhite,
+}
+
+func styleFor(status monitors.Status) statusStyle {
+	if style, ok := statusStyles[status]; ok {
+		return style
+	}
+	return statusStyle{color: bold, symbol: "[" + strings.ToUpper(string(status)) + "]"}
+}
+
+// applyDisplayConfig overrides status symbols and colors from config.
+// In ASCII mode, symbols containing non-ASCII characters are ignored.
+func applyDisplayConfig(display config.DisplayConfig) {
+	for key, style := range statusStyles {
+		if symbol, ok := display.Symbols[string(key)]; ok && !(display.ASCII && !isASCII(symbol)) {
+			style.symbol = symbol
+		}
+		if name, ok := display.Colors[string(key)]; ok {
+			style.color = color.New(colorsByName[name])
+		}
+		statusStyles[key] = style
+	}
+}
+
+func isASCII(s string) bool {
+	for _, r := range s {
+		if r > 127 {
+			return false
+		}
+	}
+	return true
+}
+
 func main() {
 	// Command line flags
 	showVersion := flag.Bool("version", false, "Show version information")
 	runOnce := flag.Bool("once", false, "Run once and exit")
 	configPath := flag.String("config", ".watch-now.yaml", "Path to configuration file")
 	initConfig := flag.Bool("init", false, "Generate a configuration file for the current project")
+	interactive := flag.Bool("interactive", false, "With --init, confirm or edit each detected service and check before writing")
 	port := flag.Int("port", 0, "Port for REST API (0 for ephemeral port)")
 	showExamples := flag.Bool("show-examples", false, "Show example configurations")
+	listMonitors := flag.Bool("list", false, "List configured monitors and exit")
+	watchFiles := flag.Bool("watch", false, "Rerun checks when project files change")
+	changesOnly := flag.Bool("changes-only", false, "Print a line per status change instead of redrawing")
+	daemon := flag.Bool("daemon", false, "Run headless for systemd: API only, structured logs, sd_notify readiness")
+	profile := flag.Bool("profile", false, "Serve Go pprof profiles under /debug/pprof/ on the API (enables API)")
+	quiet := flag.Bool("quiet", false, "Print only when something is wrong; with --once, print nothing on success")
+	viewer := flag.Bool("viewer", false, "Serve the API without monitoring, to browse snapshots loaded via POST /api/import")
+	useProfile := flag.String("use-profile", "", "Run only untagged monitors and those tagged with this profile (default: profile from config)")
+	timeout := flag.Duration("timeout", 0, "With --once, hard cap on the whole run; unfinished monitors fail (0 for the default 60s wait)")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n\n", os.Args[0])
@@ -51,14 +123,23 @@ func main() {
 		flag.PrintDefaults()
 		fmt.Fprintf(os.Stderr, "\nExamples:\n")
 		fmt.Fprintf(os.Stderr, "  %s --init                    Generate configuration for current project\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s --init --interactive      Review each detected monitor before writing\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s --once                    Run monitoring once and exit\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s --once --timeout 2m       Bound the run for CI\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s --list                    Show what would be monitored\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s --once --use-profile ci   Run only the monitors meant for CI\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s --watch                   Rerun checks when files change\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s --changes-only            Log status changes instead of redrawing\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s --quiet                   Stay silent until something breaks\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s --daemon --port 8080      Run as a systemd service\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s --viewer --port 8080      Browse a snapshot exported from another instance\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s --config custom.yaml      Use custom configuration file\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s --port 8080               Set API port (enables API)\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s                           Start continuous monitoring\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "\nConfiguration File Format (.watch-now.yaml):\n")
 		fmt.Fprintf(os.Stderr, "  services:                      # Service health monitoring\n")
 		fmt.Fprintf(os.Stderr, "    - name: api-server           # Service name\n")
-		fmt.Fprintf(os.Stderr, "      type: rest                 # Service type (rest/grpc)\n")
+		fmt.Fprintf(os.Stderr, "      type: rest                 # Service type (rest/grpc/grpc-web/cert/kafka/tcp/k8s/exec/metric/log/sql)\n")
 		fmt.Fprintf(os.Stderr, "      url: http://localhost:8080 # Service URL\n")
 		fmt.Fprintf(os.Stderr, "      health: /health            # Health endpoint path\n")
 		fmt.Fprintf(os.Stderr, "      timeout: 5s                # Request timeout\n")
@@ -74,6 +155,14 @@ func main() {
 		fmt.Fprintf(os.Stderr, "  api:                           # REST API configuration\n")
 		fmt.Fprintf(os.Stderr, "    enabled: true                # Enable/disable API\n")
 		fmt.Fprintf(os.Stderr, "    port: 0                      # API port (0 = ephemeral)\n")
+		fmt.Fprintf(os.Stderr, "    host: 127.0.0.1              # Bind address (0.0.0.0 = all interfaces)\n")
+		fmt.Fprintf(os.Stderr, "    aggregate_health: false      # /api/health returns 503 when status is FAIL\n")
+		fmt.Fprintf(os.Stderr, "  \n")
+		fmt.Fprintf(os.Stderr, "  notifications:                 # Status transition alerts\n")
+		fmt.Fprintf(os.Stderr, "    channels:\n")
+		fmt.Fprintf(os.Stderr, "      - type: slack              # Channel type (slack/webhook)\n")
+		fmt.Fprintf(os.Stderr, "        url: https://hooks...    # Destination URL\n")
+		fmt.Fprintf(os.Stderr, "        template: \"{{.Name}}: {{.Old}} -> {{.New}}\" # Go text/template\n")
 		fmt.Fprintf(os.Stderr, "\nExample Configuration:\n")
 		fmt.Fprintf(os.Stderr, "  # Monitoring a Go microservice project\n")
 		fmt.Fprintf(os.Stderr, "  services:\n")
@@ -118,12 +207,18 @@ func main() {
 	}
 
 	if *initConfig {
-		generateConfig(*configPath)
+		generateConfig(*configPath, *interactive)
 		return
 	}
 
 	// Load configuration and initialize engine
-	engine, cfg := initializeEngine(*configPath)
+	engine, cfg := initializeEngine(*configPath, *useProfile)
+	applyDisplayConfig(cfg.Display)
+
+	if *listMonitors {
+		printMonitorList(engine, cfg)
+		return
+	}
 
 	// Override API port if specified via flag
 	if *port != 0 {
@@ -131,25 +226,79 @@ func main() {
 		cfg.API.Enabled = true
 	}
 
-	// Print header
-	printHeader()
+	if *viewer {
+		cfg.API.Viewer = true
+	}
+	if *profile {
+		cfg.API.PProf = true
+		cfg.API.Enabled = true
+	}
 
 	// Set up context for graceful shutdown
-	ctx := setupGracefulShutdown()
+	ctx := setupGracefulShutdown(!*daemon)
+
+	if cfg.API.Viewer {
+		runViewerMode(ctx, engine, cfg)
+		return
+	}
+
+	restart := new(atomic.Bool)
+	if !*runOnce {
+		ctx, restart = watchConfig(ctx, *configPath, *useProfile, cfg.Reload)
+		defer func() {
+			if restart.Load() {
+				restartSelf()
+			}
+		}()
+	}
+
+	// Without discovery, an empty config would idle forever reporting nothing
+	if engine.MonitorCount() == 0 && cfg.Discovery == nil {
+		if *runOnce {
+			fmt.Fprintf(os.Stderr, "Error: %s configures no services or checks; run watch-now --init to generate a configuration\n", *configPath)
+			os.Exit(1)
+		}
+		fmt.Fprintln(os.Stderr, yellow.Sprintf("Warning: %s configures no services or checks; run watch-now --init to generate a configuration", *configPath))
+	}
+
+	if *daemon {
+		if *watchFiles {
+			go engine.Watch(ctx, ".")
+		}
+		runDaemonMode(ctx, engine, cfg, restart)
+		return
+	}
+
+	if !*quiet {
+		printHeader()
+	}
 
 	if *runOnce {
-		runOnceMode(ctx, engine)
+		runOnceMode(ctx, engine, *timeout, *quiet)
 	} else {
-		runContinuousMode(ctx, engine, cfg)
+		if *watchFiles {
+			go engine.Watch(ctx, ".")
+		}
+		if *quiet {
+			runQuietMode(ctx, engine, cfg)
+		} else if *changesOnly {
+			runChangesOnlyMode(ctx, engine, cfg)
+		} else {
+			runContinuousMode(ctx, engine, cfg)
+		}
 	}
 }
 
-func initializeEngine(configPath string) (*core.Engine, *config.Config) {
+func initializeEngine(configPath, profile string) (*core.Engine, *config.Config) {
 	cfg, err := config.Load(configPath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
 		os.Exit(1)
 	}
+	if err := cfg.ApplyProfile(profile); err != nil {
+		fmt.Fprintf(os.Stderr, "Error selecting profile: %v\n", err)
+		os.Exit(1)
+	}
 
 	engine := core.NewEngine(cfg)
 	if err := engine.Initialize(); err != nil {
@@ -160,63 +309,191 @@ func initializeEngine(configPath string) (*core.Engine, *config.Config) {
 	return engine, cfg
 }
 
+func printMonitorList(engine *core.Engine, cfg *config.Config) {
+	infos := engine.Monitors()
+	if len(infos) == 0 {
+		fmt.Println("No monitors configured")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTYPE\tTARGET\tTIMEOUT\tINTERVAL")
+	for _, info := range infos {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%v\t%v\n", info.Name, info.Type, info.Target, info.Timeout, cfg.Interval)
+	}
+	_ = w.Flush()
+}
+
 func printHeader() {
 	fmt.Println(bold.Sprint("watch-now - Universal Development Monitor"))
 	fmt.Println("================================================================================")
 }
 
-func setupGracefulShutdown() context.Context {
+// setupGracefulShutdown cancels the returned context on SIGINT or SIGTERM,
+// the latter being how systemd and container runtimes stop a service
+func setupGracefulShutdown(announce bool) context.Context {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-sigChan
-		fmt.Println("\nShutting down...")
+		if announce {
+			fmt.Println("\nShutting down...")
+		}
 		cancel()
 	}()
 
 	return ctx
 }
 
-func runOnceMode(ctx context.Context, engine *core.Engine) {
-	// Start engine
+// runOnceMode runs every monitor once, prints the report and exits non-zero
+// on failure. With quiet, the report is only printed when not all is OK.
+func runOnceMode(ctx context.Context, engine *core.Engine, timeout time.Duration, quiet bool) {
+	// The 60s default accommodates sequential golangci-lint execution
+	// (5 services × ~10s per lint check)
+	wait := 60 * time.Second
+	if timeout > 0 {
+		wait = timeout
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	done := make(chan struct{})
 	go func() {
-		if err := engine.Start(ctx); err != nil {
-			fmt.Fprintf(os.Stderr, "Engine error: %v\n", err)
-		}
+		defer close(done)
+		engine.RunOnce(ctx)
 	}()
-	time.Sleep(100 * time.Millisecond) // Give monitors time to start
 
-	// Wait for initial results
-	// Increased timeout to 60s to accommodate sequential golangci-lint execution
-	// (5 services × ~10s per lint check = ~50s + 10s buffer)
-	waitForResults(engine, 60*time.Second)
-	runMonitor(engine)
+	// Render as results arrive. A check that ignores cancellation keeps
+	// the run from finishing, so don't wait past the timeout: report what
+	// we have and fail whatever is still running.
+	redraw := isTerminal() && !quiet
+	waitForRun(ctx, engine, done, wait, redraw)
+	engine.FailUnfinished(fmt.Sprintf("did not complete within %v", wait))
+	if redraw {
+		clearScreen()
+	}
+	status := engine.State().Overall(engine.State().GetAll())
+	if !quiet || status != monitors.StatusOK {
+		runMonitor(engine)
+	}
+	engine.FlushTelemetry()
 
 	// Exit with appropriate code
-	status := getOverallStatus(engine.State().GetAll())
 	if status == monitors.StatusFail {
 		os.Exit(1)
 	}
 }
 
+// runViewerMode serves the API without starting the engine, so the state
+// only changes through POST /api/import
+func runViewerMode(ctx context.Context, engine *core.Engine, cfg *config.Config) {
+	cfg.API.Enabled = true
+	apiServer := startAPIServer(engine, cfg)
+	if apiServer == nil {
+		os.Exit(1)
+	}
+	defer func() { _ = apiServer.Stop() }()
+
+	fmt.Println("Viewer mode: no monitors are running; load a snapshot with POST /api/import")
+	<-ctx.Done()
+}
+
+// startAPIServer starts the API when enabled and prints its endpoints
+func startAPIServer(engine *core.Engine, cfg *config.Config) *api.Server {
+	apiServer := listenAPI(engine, cfg)
+	if apiServer == nil {
+		return nil
+	}
+
+	go func() {
+		if err := apiServer.Start(); err != nil {
+			log.Printf("API server error: %v", err)
+		}
+	}()
+	fmt.Printf("API enabled at http://%s\n", apiServer.Addr())
+	fmt.Printf("  Status: http://%s/api/status\n", apiServer.Addr())
+	fmt.Printf("  Events: http://%s/api/events\n", apiServer.Addr())
+	fmt.Printf("  Pause:  curl -X POST http://%s/api/pause\n", apiServer.Addr())
+	return apiServer
+}
+
+// listenAPI creates the API server when enabled. A taken port falls back
+// to an ephemeral one, and failing that monitoring goes on without the API.
+func listenAPI(engine *core.Engine, cfg *config.Config) *api.Server {
+	if !cfg.API.Enabled {
+		return nil
+	}
+
+	apiServer, err := api.NewServer(engine, cfg.API, buildInfo())
+	if err != nil && cfg.API.Port != 0 {
+		fmt.Fprintf(os.Stderr, "Warning: API port %d unavailable (%v); using an ephemeral port\n", cfg.API.Port, err)
+		fallback := cfg.API
+		fallback.Port = 0
+		apiServer, err = api.NewServer(engine, fallback, buildInfo())
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: API disabled: %v\n", err)
+		return nil
+	}
+	return apiServer
+}
+
+// runChangesOnlyMode prints one line per status transition instead of
+// redrawing, producing an append-only log suitable for tee.
+func runChangesOnlyMode(ctx context.Context, engine *core.Engine, cfg *config.Config) {
+	fmt.Printf("Monitoring every %v, printing status changes. Press Ctrl+C to stop.\n", cfg.Interval)
+	apiServer := startAPIServer(engine, cfg)
+	if apiServer != nil {
+		defer func() { _ = apiServer.Stop() }()
+	}
+	fmt.Println("================================================================================")
+
+	engine.State().OnTransition(printTransition)
+	if err := engine.Start(ctx); err != nil && ctx.Err() == nil {
+		fmt.Fprintf(os.Stderr, "Engine error: %v\n", err)
+	}
+}
+
+// runQuietMode prints nothing while all is well: only a line per monitor
+// that turns WARN or FAIL, in the same format as --changes-only.
+func runQuietMode(ctx context.Context, engine *core.Engine, cfg *config.Config) {
+	if apiServer := listenAPI(engine, cfg); apiServer != nil {
+		go func() { _ = apiServer.Start() }()
+		defer func() { _ = apiServer.Stop() }()
+	}
+
+	engine.State().OnTransition(func(t core.Transition) {
+		if t.New == monitors.StatusWarn || t.New == monitors.StatusFail {
+			printTransition(t)
+		}
+	})
+	if err := engine.Start(ctx); err != nil && ctx.Err() == nil {
+		fmt.Fprintf(os.Stderr, "Engine error: %v\n", err)
+	}
+}
+
+func printTransition(t core.Transition) {
+	style := styleFor(t.New)
+	change := string(t.New)
+	if t.Old != "" {
+		change = fmt.Sprintf("%s -> %s", t.Old, t.New)
+	}
+
+	line := fmt.Sprintf("%s %s %s %s: %s", t.Result.Timestamp.Format("2006-01-02 15:04:05"), style.color.Sprint(style.symbol), bold.Sprint(t.Name), change, t.Result.Message)
+	if t.Flapping {
+		line += " " + purple.Sprint("[FLAPPING]")
+	}
+	fmt.Println(line)
+}
+
 func runContinuousMode(ctx context.Context, engine *core.Engine, cfg *config.Config) {
 	fmt.Printf("Monitoring every %v. Press Ctrl+C to stop.\n", cfg.Interval)
 
 	// Start API server if needed
-	var apiServer *api.Server
-	if cfg.API.Enabled {
-		apiServer = api.NewServer(engine, cfg.API.Port)
-		go func() {
-			if err := apiServer.Start(); err != nil {
-				log.Printf("API server error: %v", err)
-			}
-		}()
-		fmt.Printf("API enabled at http://localhost:%d\n", apiServer.Port())
-		fmt.Printf("  Status: http://localhost:%d/api/status\n", apiServer.Port())
-		fmt.Printf("  Events: http://localhost:%d/api/events\n", apiServer.Port())
-	}
+	apiServer := startAPIServer(engine, cfg)
 	fmt.Println("================================================================================")
 
 	// Start monitoring in background
@@ -226,10 +503,9 @@ func runContinuousMode(ctx context.Context, engine *core.Engine, cfg *config.Con
 		}
 	}()
 
-	// Wait for initial results before first display
-	waitForResults(engine, 10*time.Second)
-
-	// Initial display with results
+	// Show results as they arrive, then the first complete display
+	waitForResults(ctx, engine, 10*time.Second, true)
+	clearScreen()
 	runMonitor(engine)
 
 	// Display results periodically
@@ -258,10 +534,85 @@ func runMonitor(engine *core.Engine) {
 	timestamp := time.Now().Format("15:04:05")
 	fmt.Printf("\n%s System Status\n", bold.Sprintf("[%s]", timestamp))
 	fmt.Println("--------------------------------------------------------------------------------")
+	if engine.Paused() {
+		fmt.Printf("%s Monitoring paused - showing last known results\n", yellow.Sprint("[PAUSED]"))
+	}
 
 	// Get all results from state
-	results := engine.State().GetAll()
+	results := engine.State().WithHistory(engine.State().GetAll())
+	fmt.Println(summaryLine(results, engine.MonitorCount()))
+
+	pending := pendingMonitors(engine, results)
+	if projects := engine.Projects(); len(projects) > 0 {
+		grouped := engine.ResultsByProject(results)
+		for _, project := range projects {
+			if project != "" {
+				fmt.Printf("\n%s %s\n", bold.Sprint("PROJECT"), project)
+			}
+			displayResults(engine, grouped[project], pending[project])
+		}
+	} else {
+		displayResults(engine, results, pending[""])
+	}
 
+	// Overall status
+	status := engine.State().Overall(results)
+	statusText := "All systems operational"
+
+	switch status {
+	case monitors.StatusWarn:
+		statusText = "Some checks need attention"
+	case monitors.StatusFail:
+		statusText = "Some checks are failing"
+	case monitors.StatusInfo:
+		// Overall is INFO until there is a result to judge
+		statusText = "Waiting for first results"
+		if engine.MonitorCount() == 0 {
+			statusText = "No monitors configured (run watch-now --init)"
+		}
+	}
+
+	fmt.Printf("\n%s %s\n", styleFor(status).color.Sprintf("[%s]", strings.ToUpper(string(status))), bold.Sprint("STATUS: "+statusText))
+	fmt.Println("================================================================================")
+}
+
+// summaryLine counts results by status, e.g. "42 monitors: 38 ok, 2 warn, 2 fail"
+func summaryLine(results map[string]*monitors.Result, total int) string {
+	counts := make(map[monitors.Status]int)
+	for _, result := range results {
+		counts[result.Status]++
+	}
+
+	parts := []string{
+		styleFor(monitors.StatusOK).color.Sprintf("%d ok", counts[monitors.StatusOK]),
+		styleFor(monitors.StatusWarn).color.Sprintf("%d warn", counts[monitors.StatusWarn]),
+		styleFor(monitors.StatusFail).color.Sprintf("%d fail", counts[monitors.StatusFail]),
+	}
+	if n := counts[monitors.StatusInfo]; n > 0 {
+		parts = append(parts, styleFor(monitors.StatusInfo).color.Sprintf("%d info", n))
+	}
+	if pending := total - len(results); pending > 0 {
+		parts = append(parts, fmt.Sprintf("%d pending", pending))
+	}
+
+	return fmt.Sprintf("%s %s", bold.Sprintf("%d monitors:", total), strings.Join(parts, ", "))
+}
+
+// pendingMonitors returns monitors with no result yet, keyed by project
+func pendingMonitors(engine *core.Engine, results map[string]*monitors.Result) map[string][]monitors.Info {
+	pending := make(map[string][]monitors.Info)
+	for _, info := range engine.Monitors() {
+		if _, ok := results[info.Name]; !ok {
+			project := engine.ProjectOf(info.Name)
+			pending[project] = append(pending[project], info)
+		}
+	}
+	return pending
+}
+
+// displayResults prints the SERVICES and CHECKS sections for a set of
+// results, with placeholders for monitors that are still running
+func displayResults(engine *core.Engine, results map[string]*monitors.Result, pending []monitors.Info) {
 	// Group results by type
 	var qualityResults []*monitors.Result
 	var serviceResults []*monitors.Result
@@ -270,141 +621,167 @@ func runMonitor(engine *core.Engine) {
 		switch result.Type {
 		case monitors.TypeQuality:
 			qualityResults = append(qualityResults, result)
-		case monitors.TypeREST, monitors.TypeGRPC:
+		default:
 			serviceResults = append(serviceResults, result)
 		}
 	}
 
-	sort.Slice(serviceResults, func(i, j int) bool {
-		return strings.ToLower(serviceResults[i].Name) < strings.ToLower(serviceResults[j].Name)
-	})
-	sort.Slice(qualityResults, func(i, j int) bool {
-		return strings.ToLower(qualityResults[i].Name) < strings.ToLower(qualityResults[j].Name)
-	})
-
-	// Display services
-	if len(serviceResults) > 0 {
-		fmt.Printf("\n%s Services:\n", blue.Sprint("SERVICES"))
-		for _, result := range serviceResults {
-			displayResult(result)
+	var qualityPending []monitors.Info
+	var servicePending []monitors.Info
+	for _, info := range pending {
+		switch info.Type {
+		case monitors.TypeQuality:
+			qualityPending = append(qualityPending, info)
+		default:
+			servicePending = append(servicePending, info)
 		}
-	} else {
-		fmt.Printf("\n%s Services:\n", blue.Sprint("SERVICES"))
-		fmt.Printf("  %s No services configured\n", yellow.Sprint("[INFO]"))
 	}
 
-	// Display code quality
-	if len(qualityResults) > 0 {
-		fmt.Printf("\n%s Code Quality:\n", blue.Sprint("CHECKS"))
-		for _, result := range qualityResults {
-			displayResult(result)
-		}
-	} else {
-		fmt.Printf("\n%s Code Quality:\n", blue.Sprint("CHECKS"))
-		fmt.Printf("  %s No checks configured\n", yellow.Sprint("[INFO]"))
-	}
+	displaySection(engine, blue.Sprint("SERVICES")+" Services:", serviceResults, servicePending, "No services configured")
+	displaySection(engine, blue.Sprint("CHECKS")+" Code Quality:", qualityResults, qualityPending, "No checks configured")
+}
 
-	// Overall status
-	status := getOverallStatus(results)
-	statusColor := green
-	statusText := "All systems operational"
+func displaySection(engine *core.Engine, title string, results []*monitors.Result, pending []monitors.Info, empty string) {
+	sort.Slice(results, func(i, j int) bool {
+		return engine.DisplayLess(results[i].Name, results[j].Name)
+	})
+	sort.Slice(pending, func(i, j int) bool {
+		return engine.DisplayLess(pending[i].Name, pending[j].Name)
+	})
 
-	switch status {
-	case monitors.StatusWarn:
-		statusColor = yellow
-		statusText = "Some checks need attention"
-	case monitors.StatusFail:
-		statusColor = red
-		statusText = "Some checks are failing"
+	fmt.Printf("\n%s\n", title)
+	if len(results) == 0 && len(pending) == 0 {
+		fmt.Printf("  %s %s\n", yellow.Sprint("[INFO]"), empty)
+		return
+	}
+	for _, result := range results {
+		displayResult(result)
+	}
+	for _, info := range pending {
+		fmt.Printf("  %s %s - running...\n", bold.Sprint("[....]"), info.Name)
 	}
-
-	fmt.Printf("\n%s %s\n", statusColor.Sprintf("[%s]", strings.ToUpper(string(status))), bold.Sprint("STATUS: "+statusText))
-	fmt.Println("================================================================================")
 }
 
 func displayResult(result *monitors.Result) {
-	var statusColor *color.Color
-	var statusText string
-
-	switch result.Status {
-	case monitors.StatusOK:
-		statusColor = green
-		statusText = "OK"
-	case monitors.StatusWarn:
-		statusColor = yellow
-		statusText = "WARN"
-	case monitors.StatusFail:
-		statusColor = red
-		statusText = "FAIL"
-	case monitors.StatusInfo:
-		statusColor = blue
-		statusText = "INFO"
-	}
+	style := styleFor(result.Status)
 
 	message := result.Message
-	if (result.Type == monitors.TypeREST || result.Type == monitors.TypeGRPC) && result.Metadata != nil {
-		if urlValue, ok := result.Metadata["url"].(string); ok && urlValue != "" {
-			message = fmt.Sprintf("%s @ %s", message, urlValue)
+	// A pass that needed retries points at a marginal service
+	switch {
+	case result.Retries == 1:
+		message += " (after 1 retry)"
+	case result.Retries > 1:
+		message += fmt.Sprintf(" (after %d retries)", result.Retries)
+	}
+	if result.Status == monitors.StatusFail && result.Streak > 1 {
+		message += fmt.Sprintf(" (%d in a row)", result.Streak)
+	}
+	if result.Type != monitors.TypeQuality && result.Metadata != nil {
+		if target := resultTarget(result); target != "" {
+			message = fmt.Sprintf("%s @ %s", message, target)
 		}
 	}
 
+	marker := style.color.Sprint(style.symbol)
+	if flapping, _ := result.Metadata["flapping"].(bool); flapping {
+		marker += purple.Sprint("[FLAPPING]")
+	}
+	if result.Acked {
+		marker += purple.Sprint("[ACKED]")
+	}
+
 	fmt.Printf("  %s %s - %s\n",
-		statusColor.Sprintf("[%s]", statusText),
+		marker,
 		result.Name,
 		message)
 }
 
-func getOverallStatus(results map[string]*monitors.Result) monitors.Status {
-	if len(results) == 0 {
-		return monitors.StatusInfo
+// resultTarget returns the URL or address a service result probed
+func resultTarget(result *monitors.Result) string {
+	if urlValue, ok := result.Metadata["url"].(string); ok && urlValue != "" {
+		return urlValue
 	}
-
-	hasWarn := false
-	for _, result := range results {
-		if result.Status == monitors.StatusFail {
-			return monitors.StatusFail
-		}
-		if result.Status == monitors.StatusWarn {
-			hasWarn = true
-		}
-	}
-
-	if hasWarn {
-		return monitors.StatusWarn
-	}
-	return monitors.StatusOK
+	address, _ := result.Metadata["address"].(string)
+	return address
 }
 
 func clearScreen() {
 	fmt.Print("\033[H\033[2J")
 }
 
-func waitForResults(engine *core.Engine, timeout time.Duration) {
-	expectedCount := engine.MonitorCount()
-	if expectedCount == 0 {
-		return // No monitors to wait for
+// waitForRun waits for a --once run to finish, or the timeout to pass,
+// redrawing the status as each result arrives when redraw is set
+func waitForRun(ctx context.Context, engine *core.Engine, done <-chan struct{}, timeout time.Duration, redraw bool) {
+	updates := engine.State().SubscribeUpdates()
+	defer engine.State().UnsubscribeUpdates(updates)
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		if redraw {
+			clearScreen()
+			runMonitor(engine)
+		}
+
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-deadline.C:
+			return
+		case <-updates:
+		}
+		drainUpdates(updates)
 	}
+}
 
-	deadline := time.Now().Add(timeout)
-	ticker := time.NewTicker(100 * time.Millisecond)
-	defer ticker.Stop()
+// waitForResults redraws the status as each result arrives until every
+// monitor has reported or the timeout passes. With redraw off it only
+// waits, so piped --once output holds a single final report.
+func waitForResults(ctx context.Context, engine *core.Engine, timeout time.Duration, redraw bool) {
+	updates := engine.State().SubscribeUpdates()
+	defer engine.State().UnsubscribeUpdates(updates)
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
 
-	for range ticker.C {
-		if time.Now().After(deadline) {
+	for len(engine.State().GetAll()) < engine.MonitorCount() {
+		if redraw {
+			clearScreen()
+			runMonitor(engine)
+		}
+
+		select {
+		case <-ctx.Done():
 			return
+		case <-deadline.C:
+			return
+		case <-updates:
 		}
+		drainUpdates(updates)
+	}
+}
 
-		// Check if we have results from all monitors
-		results := engine.State().GetAll()
-		if len(results) >= expectedCount {
-			// Wait a bit more to ensure all monitors complete
-			time.Sleep(200 * time.Millisecond)
+// drainUpdates discards queued updates so a burst causes a single redraw
+func drainUpdates(updates <-chan core.StateUpdate) {
+	for {
+		select {
+		case <-updates:
+		default:
 			return
 		}
 	}
 }
 
-func generateConfig(configPath string) {
+// isTerminal reports whether stdout is an interactive terminal
+func isTerminal() bool {
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func generateConfig(configPath string, interactive bool) {
 	fmt.Println(bold.Sprint("watch-now Configuration Generator"))
 	fmt.Println("================================================================================")
 
@@ -433,6 +810,11 @@ func generateConfig(configPath string) {
 
 	// Generate configuration
 	cfg := d.GenerateConfig()
+	if interactive {
+		newWizard(os.Stdin, os.Stdout).review(cfg)
+		// The summary below reports what was kept
+		projectInfo.Services, projectInfo.QualityChecks = cfg.Services, cfg.Checks
+	}
 
 	// Create YAML content with comments
 	yamlContent := createYAMLWithComments(projectInfo, cfg)
@@ -449,6 +831,12 @@ func generateConfig(configPath string) {
 	fmt.Printf("Services detected: %d\n", len(projectInfo.Services))
 	fmt.Printf("Quality checks: %d\n", len(projectInfo.QualityChecks))
 
+	printDetected(projectInfo)
+
+	fmt.Printf("\n%s Run 'watch-now --once' to test your configuration\n", blue.Sprint("TIP:"))
+}
+
+func printDetected(projectInfo *detector.ProjectInfo) {
 	if len(projectInfo.Services) > 0 {
 		fmt.Printf("\nDetected services:\n")
 		for _, service := range projectInfo.Services {
@@ -460,10 +848,11 @@ func generateConfig(configPath string) {
 		fmt.Printf("\nQuality checks:\n")
 		for _, check := range projectInfo.QualityChecks {
 			fmt.Printf("  - %s: %s %s\n", check.Name, check.Command, strings.Join(check.Args, " "))
+			if check.Dir != "" {
+				fmt.Printf("      (in %s)\n", check.Dir)
+			}
 		}
 	}
-
-	fmt.Printf("\n%s Run 'watch-now --once' to test your configuration\n", blue.Sprint("TIP:"))
 }
 
 func createYAMLWithComments(projectInfo *detector.ProjectInfo, cfg *config.Config) string {
@@ -494,6 +883,9 @@ func createYAMLWithComments(projectInfo *detector.ProjectInfo, cfg *config.Confi
 		for _, check := range cfg.Checks {
 			sb.WriteString(fmt.Sprintf("  - name: %s\n", check.Name))
 			sb.WriteString(fmt.Sprintf("    command: %s\n", check.Command))
+			if check.Dir != "" {
+				sb.WriteString(fmt.Sprintf("    dir: %s\n", check.Dir))
+			}
 			if len(check.Args) > 0 {
 				sb.WriteString("    args: [")
 				for i, arg := range check.Args {
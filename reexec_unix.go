@@ -0,0 +1,14 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// reexec replaces the current process image, keeping the PID so service
+// managers don't see the restart as an exit.
+func reexec(exe string) error {
+	return syscall.Exec(exe, os.Args, os.Environ())
+}
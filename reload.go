@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/config"
+)
+
+// configPollInterval is how often watchConfig rereads the config file
+var configPollInterval = time.Second
+
+// watchConfig applies the config's reload policy. With "exit" or "reload" it
+// returns a context that is cancelled once the file at path changes, so the
+// running mode winds down as it would on SIGTERM; with "reload" the returned
+// flag is also set, asking the caller to restart with the new config. Under
+// either policy a changed file that fails to load is reported and otherwise
+// ignored, so a half-saved edit doesn't stop monitoring.
+func watchConfig(ctx context.Context, path, profile, policy string) (context.Context, *atomic.Bool) {
+	restart := new(atomic.Bool)
+	if policy != "exit" && policy != "reload" {
+		return ctx, restart
+	}
+	initial, err := os.ReadFile(path)
+	if err != nil {
+		return ctx, restart
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		ticker := time.NewTicker(configPollInterval)
+		defer ticker.Stop()
+
+		last := initial
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil || bytes.Equal(data, last) {
+				continue
+			}
+			last = data
+
+			cfg, err := config.Load(path)
+			if err == nil {
+				err = cfg.ApplyProfile(profile)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s changed but is not valid, keeping the running config: %v\n", path, err)
+				continue
+			}
+			if policy == "exit" {
+				fmt.Fprintf(os.Stderr, "%s changed; exiting\n", path)
+			} else {
+				fmt.Fprintf(os.Stderr, "%s changed; reloading\n", path)
+				restart.Store(true)
+			}
+			cancel()
+			return
+		}
+	}()
+
+	return ctx, restart
+}
+
+// restartSelf starts watch-now again with the same arguments and
+// environment, so the new process loads the config from scratch
+func restartSelf() {
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error restarting: %v\n", err)
+		os.Exit(1)
+	}
+	if err := reexec(exe); err != nil {
+		fmt.Fprintf(os.Stderr, "Error restarting: %v\n", err)
+		os.Exit(1)
+	}
+}
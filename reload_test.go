@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// An edit that doesn't load must not end the run under either policy; the
+// next valid edit then does
+func TestWatchConfigIgnoresInvalidEdits(t *testing.T) {
+	defer func(d time.Duration) { configPollInterval = d }(configPollInterval)
+	configPollInterval = 10 * time.Millisecond
+
+	for _, policy := range []string{"exit", "reload"} {
+		t.Run(policy, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "watch-now.yaml")
+			write := func(content string) {
+				if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			write("interval: 30s\n")
+
+			ctx, restart := watchConfig(context.Background(), path, "", policy)
+			write("interval: [30s\n")
+			select {
+			case <-ctx.Done():
+				t.Fatal("invalid edit ended the run")
+			case <-time.After(200 * time.Millisecond):
+			}
+
+			write("interval: 1m\n")
+			select {
+			case <-ctx.Done():
+			case <-time.After(5 * time.Second):
+				t.Fatal("valid edit did not end the run")
+			}
+			if got := restart.Load(); got != (policy == "reload") {
+				t.Errorf("restart = %v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/orchard9/watch-now/internal/config"
+)
+
+// wizard walks through a generated config on the terminal, letting the user
+// keep, drop or edit each detected monitor. Once input ends, every
+// remaining answer takes its default.
+type wizard struct {
+	in  *bufio.Reader
+	out io.Writer
+	eof bool
+}
+
+func newWizard(in io.Reader, out io.Writer) *wizard {
+	return &wizard{in: bufio.NewReader(in), out: out}
+}
+
+// review edits cfg's services and checks in place
+func (w *wizard) review(cfg *config.Config) {
+	fmt.Fprintln(w.out, "\nReview the detected monitors. Press Enter to accept the value in brackets.")
+
+	var services []config.ServiceConfig
+	for _, svc := range cfg.Services {
+		fmt.Fprintf(w.out, "\n%s %s\n", blue.Sprint("Service"), bold.Sprint(svc.Name))
+		if !w.confirm("Keep this service?", true) {
+			continue
+		}
+		svc.URL = w.ask("URL", svc.URL)
+		svc.Health = w.ask("Health path", svc.Health)
+		svc.Timeout = w.askDuration("Timeout", svc.Timeout)
+		services = append(services, svc)
+	}
+	cfg.Services = services
+
+	var checks []config.CheckConfig
+	for _, check := range cfg.Checks {
+		fmt.Fprintf(w.out, "\n%s %s\n", blue.Sprint("Check"), bold.Sprint(check.Name))
+		if !w.confirm("Keep this check?", true) {
+			continue
+		}
+		// Arguments are split on spaces; edit the file for quoting
+		line := strings.TrimSpace(check.Command + " " + strings.Join(check.Args, " "))
+		if fields := strings.Fields(w.ask("Command", line)); len(fields) > 0 {
+			check.Command, check.Args = fields[0], fields[1:]
+		}
+		check.Timeout = w.askDuration("Timeout", check.Timeout)
+		checks = append(checks, check)
+	}
+	cfg.Checks = checks
+}
+
+// ask prompts for a value, returning def on an empty answer
+func (w *wizard) ask(prompt, def string) string {
+	fmt.Fprintf(w.out, "  %s [%s]: ", prompt, def)
+	if answer := w.readLine(); answer != "" {
+		return answer
+	}
+	return def
+}
+
+// askDuration prompts until the answer is empty or a valid duration
+func (w *wizard) askDuration(prompt string, def time.Duration) time.Duration {
+	for {
+		answer := w.ask(prompt, def.String())
+		d, err := time.ParseDuration(answer)
+		if err == nil && d > 0 {
+			return d
+		}
+		if w.eof {
+			return def
+		}
+		fmt.Fprintf(w.out, "  %q is not a duration like 5s or 2m\n", answer)
+	}
+}
+
+// confirm asks a yes/no question
+func (w *wizard) confirm(prompt string, def bool) bool {
+	choices := "y/N"
+	if def {
+		choices = "Y/n"
+	}
+	fmt.Fprintf(w.out, "  %s (%s): ", prompt, choices)
+	switch strings.ToLower(w.readLine()) {
+	case "y", "yes":
+		return true
+	case "n", "no":
+		return false
+	default:
+		return def
+	}
+}
+
+func (w *wizard) readLine() string {
+	if w.eof {
+		fmt.Fprintln(w.out)
+		return ""
+	}
+	line, err := w.in.ReadString('\n')
+	if err != nil {
+		w.eof = true
+		fmt.Fprintln(w.out)
+	}
+	return strings.TrimSpace(line)
+}